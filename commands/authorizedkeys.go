@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/docker/machine/log"
+	"github.com/docker/machine/ssh"
+)
+
+var (
+	ErrExtraUserConflict = errors.New("Error: --user and --remove-user cannot be used together")
+)
+
+// readAuthorizedKey reads a public key file, such as id_rsa.pub.
+func readAuthorizedKey(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := ssh.NormalizeAuthorizedKey(data)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a public key: %s", path, err)
+	}
+
+	return key, nil
+}
+
+// readAuthorizedKeys reads public key files with readAuthorizedKey.
+func readAuthorizedKeys(paths []string) ([]string, error) {
+	keys := []string{}
+	for _, path := range paths {
+		key, err := readAuthorizedKey(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// updateAuthorizedKeys adds keys which are not authorized yet and then
+// removes keys, given as public key files or fingerprints.
+func updateAuthorizedKeys(keys, add, remove []string) ([]string, error) {
+	fingerprints := map[string]bool{}
+	for _, key := range keys {
+		fingerprint, err := ssh.FingerprintSHA256([]byte(key))
+		if err != nil {
+			return nil, err
+		}
+		fingerprints[fingerprint] = true
+	}
+
+	updated := append([]string{}, keys...)
+
+	for _, key := range add {
+		fingerprint, err := ssh.FingerprintSHA256([]byte(key))
+		if err != nil {
+			return nil, err
+		}
+
+		if !fingerprints[fingerprint] {
+			fingerprints[fingerprint] = true
+			updated = append(updated, key)
+		}
+	}
+
+	for _, r := range remove {
+		fingerprint := r
+		if _, err := os.Stat(r); err == nil {
+			key, err := readAuthorizedKey(r)
+			if err != nil {
+				return nil, err
+			}
+
+			if fingerprint, err = ssh.FingerprintSHA256([]byte(key)); err != nil {
+				return nil, err
+			}
+		}
+
+		if !fingerprints[fingerprint] {
+			return nil, fmt.Errorf("Error: %s is not an authorized key of the machine", r)
+		}
+
+		kept := []string{}
+		for _, key := range updated {
+			if f, _ := ssh.FingerprintSHA256([]byte(key)); f != fingerprint {
+				kept = append(kept, key)
+			}
+		}
+		updated = kept
+	}
+
+	return updated, nil
+}
+
+func cmdAuthorizedKeys(c *cli.Context) {
+	if len(c.Args()) != 1 {
+		log.Fatal(ErrExpectedOneMachine)
+	}
+
+	if c.String("user") != "" && c.Bool("remove-user") {
+		log.Fatal(ErrExtraUserConflict)
+	}
+
+	host, err := loadMachine(c.Args().First(), c)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	keys, user := host.HostOptions.AuthorizedKeys, host.HostOptions.ExtraUser
+
+	add, err := readAuthorizedKeys(c.StringSlice("add"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	remove := c.StringSlice("remove")
+
+	if len(add)+len(remove) != 0 || c.String("user") != "" || c.Bool("remove-user") {
+		if keys, err = updateAuthorizedKeys(keys, add, remove); err != nil {
+			log.Fatal(err)
+		}
+
+		if c.String("user") != "" {
+			user = c.String("user")
+		}
+
+		if c.Bool("remove-user") {
+			user = ""
+		}
+
+		if err := host.SetAuthorizedKeys(keys, user); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	for _, key := range keys {
+		fingerprint, err := ssh.FingerprintSHA256([]byte(key))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// the comment usually says whose key it is
+		fmt.Println(strings.Join(append([]string{fingerprint}, strings.Fields(key)[2:]...), " "))
+	}
+
+	if user != "" {
+		fmt.Printf("Extra user: %s\n", user)
+	}
+}
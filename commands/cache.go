@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/log"
+	"github.com/docker/machine/utils"
+)
+
+var (
+	ErrCacheNoVersion = errors.New("Error: Please specify the engine version to fetch with --engine-version")
+)
+
+// cacheImage pulls the image with the local docker client and saves it into
+// the offline cache.
+func cacheImage(image string) error {
+	archive := provision.GetImageArchive(image)
+	if err := os.MkdirAll(filepath.Dir(archive), 0700); err != nil {
+		return err
+	}
+
+	log.Infof("Saving %s to %s...", image, archive)
+
+	for _, args := range [][]string{
+		{"pull", image},
+		{"save", "-o", archive, image},
+	} {
+		cmd := exec.Command("docker", args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// downloadBoot2DockerISO caches the boot2docker ISO of the docker version
+// where the drivers look for the default one, as boot2docker releases
+// follow the docker versions.
+var downloadBoot2DockerISO = func(version string) error {
+	b2dutils := utils.NewB2dUtils("", "")
+	return b2dutils.DownloadISOFromURL(b2dutils.GetBoot2DockerReleaseURL(version))
+}
+
+// fetchOfflinePackage downloads the engine package of a target.
+var fetchOfflinePackage = provision.FetchOfflinePackage
+
+// cacheEngine fetches docker version for each target, which may be
+// boot2docker, or for all the package targets of the offline cache if none
+// is given.  The targets are checked before anything is downloaded.
+func cacheEngine(version string, targets []string) error {
+	if version == "" {
+		return ErrCacheNoVersion
+	}
+
+	known := provision.GetOfflineTargets()
+	if len(targets) == 0 {
+		targets = known
+	}
+
+	valid := map[string]bool{"boot2docker": true}
+	for _, target := range known {
+		valid[target] = true
+	}
+
+	for _, target := range targets {
+		if !valid[target] {
+			return fmt.Errorf("unknown target %s, expected boot2docker or one of %s", target, strings.Join(known, ", "))
+		}
+	}
+
+	for _, target := range targets {
+		var err error
+		if target == "boot2docker" {
+			err = downloadBoot2DockerISO(version)
+		} else {
+			err = fetchOfflinePackage(target, version)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func cmdCache(c *cli.Context) {
+	if err := cacheEngine(c.String("engine-version"), c.StringSlice("target")); err != nil {
+		log.Fatal(err)
+	}
+
+	images := c.StringSlice("image")
+	if image := c.String("swarm-image"); image != "" {
+		images = append([]string{image}, images...)
+	}
+
+	for _, image := range images {
+		if err := cacheImage(image); err != nil {
+			log.Fatalf("Error saving %s: %s", image, err)
+		}
+	}
+
+	log.Infof("The cache in %s is ready for create --engine-offline", provision.GetOfflineCacheDir())
+}
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+// stubCacheDownloads records the downloads of cacheEngine instead of
+// fetching anything.
+func stubCacheDownloads() (*[]string, func()) {
+	previousISO, previousPackage := downloadBoot2DockerISO, fetchOfflinePackage
+
+	downloads := []string{}
+	downloadBoot2DockerISO = func(version string) error {
+		downloads = append(downloads, "boot2docker "+version)
+		return nil
+	}
+	fetchOfflinePackage = func(target, version string) error {
+		downloads = append(downloads, target+" "+version)
+		return nil
+	}
+
+	return &downloads, func() {
+		downloadBoot2DockerISO, fetchOfflinePackage = previousISO, previousPackage
+	}
+}
+
+func TestCacheEngineNoVersion(t *testing.T) {
+	downloads, restore := stubCacheDownloads()
+	defer restore()
+
+	if err := cacheEngine("", []string{"ubuntu-trusty"}); err != ErrCacheNoVersion {
+		t.Fatalf("expected an error without --engine-version; received %v", err)
+	}
+	if len(*downloads) != 0 {
+		t.Fatalf("expected nothing to be downloaded; received %v", *downloads)
+	}
+}
+
+func TestCacheEngineUnknownTarget(t *testing.T) {
+	downloads, restore := stubCacheDownloads()
+	defer restore()
+
+	err := cacheEngine("1.9.0", []string{"ubuntu-trusty", "windows-95"})
+	if err == nil || !strings.Contains(err.Error(), "unknown target windows-95") {
+		t.Fatalf("expected an error for the unknown target; received %v", err)
+	}
+	if len(*downloads) != 0 {
+		t.Fatalf("expected nothing to be downloaded; received %v", *downloads)
+	}
+}
+
+func TestCacheEngineBoot2Docker(t *testing.T) {
+	downloads, restore := stubCacheDownloads()
+	defer restore()
+
+	if err := cacheEngine("1.9.0", []string{"boot2docker", "ubuntu-trusty"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "boot2docker 1.9.0,ubuntu-trusty 1.9.0"
+	if strings.Join(*downloads, ",") != expected {
+		t.Fatalf("expected downloads %s; received %v", expected, *downloads)
+	}
+}
+
+func TestCacheEngineAllTargets(t *testing.T) {
+	downloads, restore := stubCacheDownloads()
+	defer restore()
+
+	if err := cacheEngine("1.9.0", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, download := range *downloads {
+		if strings.HasPrefix(download, "boot2docker ") {
+			t.Fatalf("expected boot2docker to be cached only when asked for; received %v", *downloads)
+		}
+	}
+	if len(*downloads) == 0 {
+		t.Fatal("expected the packages of all the targets to be downloaded")
+	}
+}
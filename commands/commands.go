@@ -8,6 +8,7 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/codegangsta/cli"
 	"github.com/skarademir/naturalsort"
@@ -31,6 +32,7 @@ import (
 
 	"github.com/docker/machine/libmachine"
 	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/provision"
 	"github.com/docker/machine/libmachine/swarm"
 	"github.com/docker/machine/log"
 	"github.com/docker/machine/utils"
@@ -192,10 +194,148 @@ var sharedCreateFlags = []cli.Flag{
 		Usage: "Specify labels for the created engine",
 		Value: &cli.StringSlice{},
 	},
+	cli.StringSliceFlag{
+		Name:  "engine-registry-ca",
+		Usage: "Install the CA certificate of a registry on the engine, in the form registry=path, e.g. registry.example.com:5000=ca.pem",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringFlag{
+		Name:  "engine-registry-config",
+		Usage: "Docker config.json file with the registry credentials to install for root and the SSH user",
+	},
+	cli.StringFlag{
+		Name:  "engine-version",
+		Usage: "Specify the version of the engine to install, e.g. 1.8.2 (default: latest)",
+	},
+	cli.BoolFlag{
+		Name:  "engine-offline",
+		Usage: "Install the engine and the swarm image from the local cache filled by the cache command, for machines without network access",
+	},
 	cli.StringFlag{
 		Name:  "engine-storage-driver",
 		Usage: "Specify a storage driver to use with the engine",
 	},
+	cli.StringSliceFlag{
+		Name:  "engine-env",
+		Usage: "Specify environment variables for the created engine in the form KEY=VALUE; proxy settings are also used when installing packages",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringSliceFlag{
+		Name:  "engine-dns",
+		Usage: "Specify DNS servers for the containers of the created engine",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringFlag{
+		Name:  "engine-graph-dir",
+		Usage: "Specify the root of the Docker runtime on the engine",
+	},
+	cli.BoolFlag{
+		Name:  "engine-ipv6",
+		Usage: "Enable IPv6 networking on the engine",
+	},
+	cli.StringFlag{
+		Name:  "engine-log-level",
+		Usage: "Specify the logging level of the engine: debug, info, warn, error or fatal",
+	},
+	cli.StringFlag{
+		Name:  "engine-log-driver",
+		Usage: "Specify the default logging driver for the containers of the created engine",
+	},
+	cli.StringSliceFlag{
+		Name:  "engine-log-opt",
+		Usage: "Specify logging driver options for the created engine in the form key=value",
+		Value: &cli.StringSlice{},
+	},
+	cli.BoolFlag{
+		Name:  "engine-selinux-enabled",
+		Usage: "Enable SELinux support on the engine",
+	},
+	cli.StringFlag{
+		Name:  "engine-exec-driver",
+		Usage: "Specify the exec driver to use with the engine",
+	},
+	cli.StringSliceFlag{
+		Name:  "engine-exec-opt",
+		Usage: "Specify exec driver options for the created engine in the form key=value, e.g. native.cgroupdriver=systemd",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringSliceFlag{
+		Name:  "engine-sysctl",
+		Usage: "Specify kernel parameters to set on the machine in the form name=value, e.g. vm.max_map_count=262144",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringSliceFlag{
+		Name:  "engine-ulimit",
+		Usage: "Specify default ulimits for the containers of the created engine in the form name=soft[:hard], e.g. nofile=65536:65536",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringSliceFlag{
+		Name:  "engine-kernel-module",
+		Usage: "Specify kernel modules to load on the machine",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringFlag{
+		Name:  "hostname",
+		Usage: "Hostname to give the machine instead of its name",
+		Value: "",
+	},
+	cli.StringFlag{
+		Name:  "domain",
+		Usage: "Domain of the machine, which qualifies its hostname, e.g. prod.example.com",
+		Value: "",
+	},
+	cli.StringFlag{
+		Name: "provisioner",
+		Usage: fmt.Sprintf(
+			"Provisioner to use instead of detecting one from the OS of the machine. Available provisioners: %s",
+			strings.Join(provision.GetProvisionerNames(), ", "),
+		),
+		Value: "",
+	},
+	cli.StringSliceFlag{
+		Name:  "post-provision-file",
+		Usage: "Upload a file to the machine once it is provisioned, in the form local:/remote/path",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringSliceFlag{
+		Name:  "post-provision-script",
+		Usage: "Run a shell script as root on the machine once it is provisioned, after the files are uploaded",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringFlag{
+		Name:  "bootstrap-containers",
+		Usage: "JSON file listing containers to start on the machine once it is provisioned",
+		Value: "",
+	},
+	cli.StringSliceFlag{
+		Name:  "preload-image",
+		Usage: "Image of the local Docker daemon to load into the machine once it is created",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringSliceFlag{
+		Name:  "preload-input",
+		Usage: "Archive of images to load into the machine once it is created",
+		Value: &cli.StringSlice{},
+	},
+	cli.BoolFlag{
+		Name:  "cloud-init",
+		Usage: "Install the engine with cloud-init on the first boot, for the drivers which support user-data",
+	},
+	cli.StringSliceFlag{
+		Name:  "ssh-authorized-key",
+		Usage: "Public key file to authorize on the machine in addition to its own key, e.g. ~/.ssh/id_rsa.pub",
+		Value: &cli.StringSlice{},
+	},
+	cli.StringFlag{
+		Name:  "ssh-extra-user",
+		Usage: "Name of a user to create in the docker group, who may log in with the keys of --ssh-authorized-key",
+	},
+	cli.StringFlag{
+		Name:   "ssh-key-type",
+		Usage:  "Type of SSH key to generate for the machine: rsa, ecdsa or ed25519",
+		Value:  "rsa",
+		EnvVar: "MACHINE_SSH_KEY_TYPE",
+	},
 	cli.BoolFlag{
 		Name:  "swarm",
 		Usage: "Configure Machine with Swarm",
@@ -243,6 +383,60 @@ var Commands = []cli.Command{
 		Usage:  "Print which machine is active",
 		Action: cmdActive,
 	},
+	{
+		Name:        "authorized-keys",
+		Usage:       "Manage the extra SSH keys which may log in to a machine",
+		Description: "Argument is a machine name. Without flags, lists the extra keys.",
+		Action:      cmdAuthorizedKeys,
+		Flags: []cli.Flag{
+			cli.StringSliceFlag{
+				Name:  "add",
+				Usage: "Public key file to authorize, e.g. ~/.ssh/id_rsa.pub",
+				Value: &cli.StringSlice{},
+			},
+			cli.StringSliceFlag{
+				Name:  "remove",
+				Usage: "Public key file or SHA256 fingerprint of a key to remove",
+				Value: &cli.StringSlice{},
+			},
+			cli.StringFlag{
+				Name:  "user",
+				Usage: "Name of a user to create in the docker group, who may log in with the extra keys",
+			},
+			cli.BoolFlag{
+				Name:  "remove-user",
+				Usage: "Stop the extra keys from logging in as the extra user, who is left on the machine",
+			},
+		},
+	},
+	{
+		Name:        "cache",
+		Usage:       "Fetch engine packages and images for offline provisioning",
+		Description: "Fills the cache used by create --engine-offline.",
+		Action:      cmdCache,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "engine-version",
+				Usage: "Version of the engine to fetch, e.g. 1.8.2",
+			},
+			cli.StringSliceFlag{
+				Name:  "target",
+				Usage: fmt.Sprintf("Release to fetch the engine for, one of %s, or boot2docker to replace the default ISO (default: all but boot2docker)", strings.Join(provision.GetOfflineTargets(), ", ")),
+				Value: &cli.StringSlice{},
+			},
+			cli.StringFlag{
+				Name:   "swarm-image",
+				Usage:  "Swarm image to save into the cache, empty to skip it",
+				Value:  "swarm:latest",
+				EnvVar: "MACHINE_SWARM_IMAGE",
+			},
+			cli.StringSliceFlag{
+				Name:  "image",
+				Usage: "Other image to save into the cache, such as the image of a bootstrap container",
+				Value: &cli.StringSlice{},
+			},
+		},
+	},
 	{
 		Name:        "config",
 		Usage:       "Print the connection config for machine",
@@ -282,6 +476,37 @@ var Commands = []cli.Command{
 				Name:  "unset, u",
 				Usage: "Unset variables instead of setting them",
 			},
+			cli.BoolFlag{
+				Name:  "tunnel",
+				Usage: "Point DOCKER_HOST at the Docker socket forwarded by the tunnel command",
+			},
+		},
+	},
+	{
+		Name:        "exec",
+		Usage:       "Run a command on several machines with SSH",
+		Description: "Arguments are the command to run. Machines are selected with --machine and --filter, all machines are used if neither is given.",
+		Action:      cmdExec,
+		Flags: []cli.Flag{
+			cli.StringSliceFlag{
+				Name:  "machine, m",
+				Usage: "Name of a machine to run the command on",
+				Value: &cli.StringSlice{},
+			},
+			cli.StringSliceFlag{
+				Name:  "filter",
+				Usage: "Select machines based on conditions provided, as with ls",
+				Value: &cli.StringSlice{},
+			},
+			cli.IntFlag{
+				Name:  "parallel, p",
+				Usage: "Number of machines to run the command on at the same time",
+				Value: 5,
+			},
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the results of all machines as JSON",
+			},
 		},
 	},
 	{
@@ -309,6 +534,40 @@ var Commands = []cli.Command{
 		Description: "Argument(s) are one or more machine names.",
 		Action:      cmdKill,
 	},
+	{
+		Name:        "load",
+		Usage:       "Load images from the local Docker daemon or archives into machines",
+		Description: "Argument(s) are one or more machine names.",
+		Action:      cmdLoad,
+		Flags: []cli.Flag{
+			cli.StringSliceFlag{
+				Name:  "image",
+				Usage: "Image of the local Docker daemon to load, saved with docker save",
+				Value: &cli.StringSlice{},
+			},
+			cli.StringSliceFlag{
+				Name:  "input, i",
+				Usage: "Archive of images to load, such as one written by docker save",
+				Value: &cli.StringSlice{},
+			},
+		},
+	},
+	{
+		Name:        "logs",
+		Usage:       "Show the provisioning log of a machine, or the log of its Docker daemon",
+		Description: "Argument is a machine name.",
+		Action:      cmdLogs,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "daemon",
+				Usage: "Show the log of the Docker daemon, as kept by the init system of the machine",
+			},
+			cli.IntFlag{
+				Name:  "lines, n",
+				Usage: "Number of lines to show from the end of the log, all of the provisioning log and 100 lines of the daemon log by default",
+			},
+		},
+	},
 	{
 		Flags: []cli.Flag{
 			cli.BoolFlag{
@@ -385,11 +644,81 @@ var Commands = []cli.Command{
 		Description: "Argument(s) are one or more machine names.",
 		Action:      cmdStop,
 	},
+	{
+		Name:        "sync",
+		Usage:       "Mirror a local directory to a machine",
+		Description: "Arguments are [local-dir] [machine:path].",
+		Action:      cmdSync,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "delete",
+				Usage: "Delete files on the machine which do not exist locally",
+			},
+			cli.StringSliceFlag{
+				Name:  "ignore",
+				Usage: "Pattern of files and directories to skip, e.g. .git or *.log",
+				Value: &cli.StringSlice{},
+			},
+			cli.BoolFlag{
+				Name:  "watch, w",
+				Usage: "Keep running and sync changes as they happen",
+			},
+			cli.DurationFlag{
+				Name:  "interval",
+				Usage: "How often to check for changes in watch mode",
+				Value: time.Second,
+			},
+		},
+	},
+	{
+		Name:        "tunnel",
+		Usage:       "Forward ports and the Docker socket over SSH",
+		Description: "Argument is a machine name.",
+		Action:      cmdTunnel,
+		Flags: []cli.Flag{
+			cli.StringSliceFlag{
+				Name:  "forward, L",
+				Usage: "Forward a local port or socket to the machine: [bind_address:]port:host:hostport or local:/remote/socket",
+				Value: &cli.StringSlice{},
+			},
+			cli.BoolFlag{
+				Name:  "docker",
+				Usage: "Forward the remote Docker socket",
+			},
+			cli.StringFlag{
+				Name:  "docker-listen",
+				Usage: "Local socket path or [bind_address:]port for the Docker socket (default: docker.sock in the machine directory)",
+				Value: "",
+			},
+			cli.BoolFlag{
+				Name:  "background, b",
+				Usage: "Run the tunnel in the background",
+			},
+			cli.BoolFlag{
+				Name:  "stop",
+				Usage: "Stop the tunnel running in the background",
+			},
+		},
+	},
 	{
 		Name:        "upgrade",
 		Usage:       "Upgrade a machine to the latest version of Docker",
 		Description: "Argument(s) are one or more machine names.",
 		Action:      cmdUpgrade,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "version",
+				Usage: "Version of Docker to install instead of the latest one, e.g. 1.8.2",
+			},
+			cli.BoolFlag{
+				Name:  "unpin",
+				Usage: "Install the latest version of Docker on machines pinned to a version with --version",
+			},
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Show what the upgrade would change without upgrading",
+			},
+		},
 	},
 	{
 		Name:        "url",
@@ -2,7 +2,9 @@ package commands
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/docker/machine/log"
 
@@ -11,7 +13,9 @@ import (
 	"github.com/docker/machine/libmachine"
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision"
 	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/ssh"
 	"github.com/docker/machine/utils"
 )
 
@@ -35,6 +39,70 @@ func cmdCreate(c *cli.Context) {
 		log.Fatal("You must specify a machine name")
 	}
 
+	keyType, err := ssh.ParseKeyType(c.String("ssh-key-type"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if provisionerName := c.String("provisioner"); provisionerName != "" {
+		if err := provision.ValidateProvisionerName(provisionerName); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	for _, flag := range []string{"hostname", "domain"} {
+		if value := c.String(flag); value != "" && !libmachine.ValidateDNSName(value) {
+			log.Fatalf("Error: invalid --%s %q, expected DNS labels of letters, digits and hyphens separated by dots", flag, value)
+		}
+	}
+
+	if err := engine.ValidateEnv(c.StringSlice("engine-env")); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := engine.ValidateKernelOptions(c.StringSlice("engine-sysctl"), c.StringSlice("engine-ulimit"), c.StringSlice("engine-kernel-module")); err != nil {
+		log.Fatal(err)
+	}
+
+	if c.Bool("cloud-init") && c.Bool("engine-offline") {
+		log.Fatal("Error: --cloud-init installs the engine from the network and cannot be used with --engine-offline")
+	}
+
+	postProvisionFiles, postProvisionScripts, err := getPostProvision(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	registryCA, registryConfig, err := getRegistries(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var bootstrapContainers []provision.BootstrapContainer
+	if spec := c.String("bootstrap-containers"); spec != "" {
+		bootstrapContainers, err = provision.ReadBootstrapContainers(spec)
+		if err != nil {
+			log.Fatalf("Error: invalid bootstrap containers: %s", err)
+		}
+	}
+
+	preloadImages := c.StringSlice("preload-image")
+	preloadInputs := c.StringSlice("preload-input")
+	for _, input := range preloadInputs {
+		if _, err := os.Stat(input); err != nil {
+			log.Fatalf("Error: invalid image archive: %s", err)
+		}
+	}
+
+	authorizedKeys, err := readAuthorizedKeys(c.StringSlice("ssh-authorized-key"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := provision.ValidateAuthorizedKeys(authorizedKeys, c.String("ssh-extra-user")); err != nil {
+		log.Fatal(err)
+	}
+
 	certInfo := getCertPathInfo(c)
 
 	if err := setupCertificates(
@@ -60,6 +128,14 @@ func cmdCreate(c *cli.Context) {
 	}
 
 	hostOptions := &libmachine.HostOptions{
+		Provisioner:          c.String("provisioner"),
+		Hostname:             c.String("hostname"),
+		Domain:               c.String("domain"),
+		PostProvisionFiles:   postProvisionFiles,
+		PostProvisionScripts: postProvisionScripts,
+		AuthorizedKeys:       authorizedKeys,
+		ExtraUser:            c.String("ssh-extra-user"),
+		BootstrapContainers:  bootstrapContainers,
 		AuthOptions: &auth.AuthOptions{
 			CaCertPath:     certInfo.CaCertPath,
 			PrivateKeyPath: certInfo.CaKeyPath,
@@ -70,12 +146,30 @@ func cmdCreate(c *cli.Context) {
 		},
 		EngineOptions: &engine.EngineOptions{
 			ArbitraryFlags:   c.StringSlice("engine-opt"),
+			Dns:              c.StringSlice("engine-dns"),
+			Env:              c.StringSlice("engine-env"),
+			ExecDriver:       c.String("engine-exec-driver"),
+			ExecOpt:          c.StringSlice("engine-exec-opt"),
+			GraphDir:         c.String("engine-graph-dir"),
+			Ipv6:             c.Bool("engine-ipv6"),
 			InsecureRegistry: c.StringSlice("engine-insecure-registry"),
 			Labels:           c.StringSlice("engine-label"),
+			LogDriver:        c.String("engine-log-driver"),
+			LogLevel:         c.String("engine-log-level"),
+			LogOpt:           c.StringSlice("engine-log-opt"),
 			RegistryMirror:   c.StringSlice("engine-registry-mirror"),
+			RegistryCA:       registryCA,
+			RegistryConfig:   registryConfig,
+			SelinuxEnabled:   c.Bool("engine-selinux-enabled"),
 			StorageDriver:    c.String("engine-storage-driver"),
+			Version:          c.String("engine-version"),
+			Offline:          c.Bool("engine-offline"),
+			CloudInit:        c.Bool("cloud-init"),
 			TlsVerify:        true,
 			InstallURL:       c.String("engine-install-url"),
+			Sysctl:           c.StringSlice("engine-sysctl"),
+			Ulimits:          c.StringSlice("engine-ulimit"),
+			KernelModules:    c.StringSlice("engine-kernel-module"),
 		},
 		SwarmOptions: &swarm.SwarmOptions{
 			IsSwarm:        c.Bool("swarm"),
@@ -89,12 +183,25 @@ func cmdCreate(c *cli.Context) {
 		},
 	}
 
-	_, err = mcn.Create(name, driver, hostOptions, c)
+	host, err := mcn.Create(name, driver, hostOptions, keyTypeOptions{c, keyType})
 	if err != nil {
 		log.Errorf("Error creating machine: %s", err)
+		if host != nil && host.StageSummary() != "" {
+			log.Errorf("To see what was done to the machine, run: %s logs %s", c.App.Name, name)
+		}
 		log.Fatal("You will want to check the provider to make sure the machine and associated resources were properly removed.")
 	}
 
+	if summary := host.StageSummary(); summary != "" {
+		log.Infof("Time taken by each stage:\n%s", summary)
+	}
+
+	if len(preloadImages)+len(preloadInputs) > 0 {
+		if err := loadImages([]*libmachine.Host{host}, preloadImages, preloadInputs); err != nil {
+			log.Fatalf("Error loading images: %s", err)
+		}
+	}
+
 	info := fmt.Sprintf("%s env %s", c.App.Name, name)
 	log.Infof("To see how to connect Docker to this machine, run: %s", info)
 }
@@ -117,3 +224,82 @@ func trimDriverFlags(driver string, cmds []cli.Command) ([]cli.Command, error) {
 
 	return filteredCmds, nil
 }
+
+// getPostProvision returns the post-provision files and scripts with
+// absolute local paths, so that they are found again when the machine is
+// provisioned from another directory.
+func getPostProvision(c *cli.Context) ([]string, []string, error) {
+	files := c.StringSlice("post-provision-file")
+	scripts := c.StringSlice("post-provision-script")
+
+	if err := provision.ValidatePostProvision(files, scripts); err != nil {
+		return nil, nil, err
+	}
+
+	absFiles := []string{}
+	for _, file := range files {
+		i := strings.LastIndex(file, ":")
+		local, err := filepath.Abs(file[:i])
+		if err != nil {
+			return nil, nil, err
+		}
+		absFiles = append(absFiles, local+file[i:])
+	}
+
+	absScripts := []string{}
+	for _, script := range scripts {
+		local, err := filepath.Abs(script)
+		if err != nil {
+			return nil, nil, err
+		}
+		absScripts = append(absScripts, local)
+	}
+
+	return absFiles, absScripts, nil
+}
+
+// getRegistries returns the registry CA certificates and config.json file
+// with absolute local paths, as getPostProvision does.
+func getRegistries(c *cli.Context) ([]string, string, error) {
+	cas := c.StringSlice("engine-registry-ca")
+	config := c.String("engine-registry-config")
+
+	if err := provision.ValidateRegistries(cas, config); err != nil {
+		return nil, "", err
+	}
+
+	absCAs := []string{}
+	for _, ca := range cas {
+		i := strings.Index(ca, "=")
+		local, err := filepath.Abs(ca[i+1:])
+		if err != nil {
+			return nil, "", err
+		}
+		absCAs = append(absCAs, ca[:i+1]+local)
+	}
+
+	if config == "" {
+		return absCAs, "", nil
+	}
+
+	absConfig, err := filepath.Abs(config)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return absCAs, absConfig, nil
+}
+
+// keyTypeOptions passes the create flags to the driver with the SSH key type
+// normalized by ParseKeyType, e.g. "rsa" for "RSA".
+type keyTypeOptions struct {
+	drivers.DriverOptions
+	keyType ssh.KeyType
+}
+
+func (o keyTypeOptions) String(key string) string {
+	if key == "ssh-key-type" {
+		return string(o.keyType)
+	}
+	return o.DriverOptions.String(key)
+}
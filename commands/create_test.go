@@ -1 +1,26 @@
 package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/ssh"
+)
+
+func TestKeyTypeOptions(t *testing.T) {
+	flags := &DriverOptionsMock{
+		Data: map[string]interface{}{
+			"ssh-key-type": "ED25519",
+			"swarm-host":   "tcp://0.0.0.0:3376",
+		},
+	}
+
+	options := keyTypeOptions{flags, ssh.ED25519}
+
+	if keyType := options.String("ssh-key-type"); keyType != "ed25519" {
+		t.Fatalf("expected the normalized key type ed25519; received %q", keyType)
+	}
+
+	if host := options.String("swarm-host"); host != "tcp://0.0.0.0:3376" {
+		t.Fatalf("expected the other flags to be passed through; received %q", host)
+	}
+}
@@ -16,6 +16,11 @@ import (
 
 const (
 	envTmpl = `{{ .Prefix }}DOCKER_TLS_VERIFY{{ .Delimiter }}{{ .DockerTLSVerify }}{{ .Suffix }}{{ .Prefix }}DOCKER_HOST{{ .Delimiter }}{{ .DockerHost }}{{ .Suffix }}{{ .Prefix }}DOCKER_CERT_PATH{{ .Delimiter }}{{ .DockerCertPath }}{{ .Suffix }}{{ .Prefix }}DOCKER_MACHINE_NAME{{ .Delimiter }}{{ .MachineName }}{{ .Suffix }}{{ .UsageHint }}`
+
+	// the forwarded socket of a tunnel is the daemon's plain unix socket,
+	// so the TLS variables are unset with unsetTLSEnvTmpl instead
+	tunnelEnvTmpl   = `{{ .Prefix }}DOCKER_HOST{{ .Delimiter }}{{ .DockerHost }}{{ .Suffix }}{{ .Prefix }}DOCKER_MACHINE_NAME{{ .Delimiter }}{{ .MachineName }}{{ .Suffix }}{{ .UsageHint }}`
+	unsetTLSEnvTmpl = `{{ .Prefix }}DOCKER_TLS_VERIFY{{ .Delimiter }}{{ .Suffix }}{{ .Prefix }}DOCKER_CERT_PATH{{ .Delimiter }}{{ .Suffix }}`
 )
 
 var (
@@ -50,44 +55,14 @@ func cmdEnv(c *cli.Context) {
 
 	usageHint := generateUsageHint(c.App.Name, c.Args().First(), userShell)
 
-	shellCfg := ShellConfig{
-		DockerCertPath:  "",
-		DockerHost:      "",
-		DockerTLSVerify: "",
-		MachineName:     "",
-	}
-
 	// unset vars
 	if c.Bool("unset") {
-		switch userShell {
-		case "fish":
-			shellCfg.Prefix = "set -e "
-			shellCfg.Delimiter = ""
-			shellCfg.Suffix = ";\n"
-		case "powershell":
-			shellCfg.Prefix = "Remove-Item Env:\\\\"
-			shellCfg.Delimiter = ""
-			shellCfg.Suffix = "\n"
-		case "cmd":
-			// since there is no way to unset vars in cmd just reset to empty
-			shellCfg.DockerCertPath = ""
-			shellCfg.DockerHost = ""
-			shellCfg.DockerTLSVerify = ""
-			shellCfg.Prefix = "set "
-			shellCfg.Delimiter = "="
-			shellCfg.Suffix = "\n"
-		default:
-			shellCfg.Prefix = "unset "
-			shellCfg.Delimiter = " "
-			shellCfg.Suffix = "\n"
-		}
-
 		tmpl, err := t.Parse(envTmpl)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		if err := tmpl.Execute(os.Stdout, shellCfg); err != nil {
+		if err := tmpl.Execute(os.Stdout, unsetShellConfig(userShell)); err != nil {
 			log.Fatal(err)
 		}
 		return
@@ -103,55 +78,67 @@ func cmdEnv(c *cli.Context) {
 	}
 
 	dockerHost := cfg.machineUrl
-	if c.Bool("swarm") {
-		if !cfg.SwarmOptions.Master {
-			log.Fatalf("%s is not a swarm master", cfg.machineName)
-		}
-		u, err := url.Parse(cfg.SwarmOptions.Host)
-		if err != nil {
-			log.Fatal(err)
+
+	if c.Bool("tunnel") {
+		if c.Bool("swarm") {
+			log.Fatal("Error: The --swarm and --tunnel options cannot be used together")
 		}
-		parts := strings.Split(u.Host, ":")
-		swarmPort := parts[1]
 
-		// get IP of machine to replace in case swarm host is 0.0.0.0
-		mUrl, err := url.Parse(cfg.machineUrl)
+		dockerHost, err = getTunnelDockerHost(cfg.machineDir)
 		if err != nil {
-			log.Fatal(err)
+			log.Fatalf("Error getting tunnel for %s: %s. Start one with: %s tunnel --docker -b %s", cfg.machineName, err, c.App.Name, cfg.machineName)
 		}
-		mParts := strings.Split(mUrl.Host, ":")
-		machineIp := mParts[0]
+	} else {
+		if c.Bool("swarm") {
+			if !cfg.SwarmOptions.Master {
+				log.Fatalf("%s is not a swarm master", cfg.machineName)
+			}
+			u, err := url.Parse(cfg.SwarmOptions.Host)
+			if err != nil {
+				log.Fatal(err)
+			}
+			parts := strings.Split(u.Host, ":")
+			swarmPort := parts[1]
 
-		dockerHost = fmt.Sprintf("tcp://%s:%s", machineIp, swarmPort)
-	}
+			// get IP of machine to replace in case swarm host is 0.0.0.0
+			mUrl, err := url.Parse(cfg.machineUrl)
+			if err != nil {
+				log.Fatal(err)
+			}
+			mParts := strings.Split(mUrl.Host, ":")
+			machineIp := mParts[0]
 
-	u, err := url.Parse(cfg.machineUrl)
-	if err != nil {
-		log.Fatal(err)
-	}
+			dockerHost = fmt.Sprintf("tcp://%s:%s", machineIp, swarmPort)
+		}
 
-	if u.Scheme != "unix" {
-		// validate cert and regenerate if needed
-		valid, err := utils.ValidateCertificate(
-			u.Host,
-			cfg.caCertPath,
-			cfg.serverCertPath,
-			cfg.serverKeyPath,
-		)
+		u, err := url.Parse(cfg.machineUrl)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		if !valid {
-			log.Debugf("invalid certs detected; regenerating for %s", u.Host)
-
-			if err := runActionWithContext("configureAuth", c); err != nil {
+		if u.Scheme != "unix" {
+			// validate cert and regenerate if needed
+			valid, err := utils.ValidateCertificate(
+				u.Host,
+				cfg.caCertPath,
+				cfg.serverCertPath,
+				cfg.serverKeyPath,
+			)
+			if err != nil {
 				log.Fatal(err)
 			}
+
+			if !valid {
+				log.Debugf("invalid certs detected; regenerating for %s", u.Host)
+
+				if err := runActionWithContext("configureAuth", c); err != nil {
+					log.Fatal(err)
+				}
+			}
 		}
 	}
 
-	shellCfg = ShellConfig{
+	shellCfg := ShellConfig{
 		DockerCertPath:  cfg.machineDir,
 		DockerHost:      dockerHost,
 		DockerTLSVerify: "1",
@@ -178,7 +165,21 @@ func cmdEnv(c *cli.Context) {
 		shellCfg.Delimiter = "=\""
 	}
 
-	tmpl, err := t.Parse(envTmpl)
+	text := envTmpl
+	if c.Bool("tunnel") {
+		unsetTmpl, err := template.New("unsetTLSEnvConfig").Parse(unsetTLSEnvTmpl)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := unsetTmpl.Execute(os.Stdout, unsetShellConfig(userShell)); err != nil {
+			log.Fatal(err)
+		}
+
+		text = tunnelEnvTmpl
+	}
+
+	tmpl, err := t.Parse(text)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -188,6 +189,34 @@ func cmdEnv(c *cli.Context) {
 	}
 }
 
+// unsetShellConfig returns the ShellConfig which unsets the variables in
+// the given shell.
+func unsetShellConfig(userShell string) ShellConfig {
+	shellCfg := ShellConfig{}
+
+	switch userShell {
+	case "fish":
+		shellCfg.Prefix = "set -e "
+		shellCfg.Delimiter = ""
+		shellCfg.Suffix = ";\n"
+	case "powershell":
+		shellCfg.Prefix = "Remove-Item Env:\\\\"
+		shellCfg.Delimiter = ""
+		shellCfg.Suffix = "\n"
+	case "cmd":
+		// since there is no way to unset vars in cmd just reset to empty
+		shellCfg.Prefix = "set "
+		shellCfg.Delimiter = "="
+		shellCfg.Suffix = "\n"
+	default:
+		shellCfg.Prefix = "unset "
+		shellCfg.Delimiter = " "
+		shellCfg.Suffix = "\n"
+	}
+
+	return shellCfg
+}
+
 func generateUsageHint(appName, machineName, userShell string) string {
 	cmd := ""
 	switch userShell {
@@ -215,3 +244,16 @@ func generateUsageHint(appName, machineName, userShell string) string {
 
 	return fmt.Sprintf("# Run this command to configure your shell: \n# %s\n", cmd)
 }
+
+func getTunnelDockerHost(machineDir string) (string, error) {
+	st, err := readTunnelState(machineDir)
+	if err != nil {
+		return "", err
+	}
+
+	if st.DockerHost == "" {
+		return "", errors.New("the running tunnel does not forward the Docker socket")
+	}
+
+	return st.DockerHost, nil
+}
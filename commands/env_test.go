@@ -314,3 +314,92 @@ func TestCmdEnvPowerShell(t *testing.T) {
 		}
 	}
 }
+
+func TestCmdEnvTunnel(t *testing.T) {
+	stdout := os.Stdout
+	shell := os.Getenv("SHELL")
+	r, w, _ := os.Pipe()
+
+	os.Stdout = w
+	os.Setenv("MACHINE_STORAGE_PATH", TestStoreDir)
+	os.Setenv("SHELL", "/bin/bash")
+
+	defer func() {
+		os.Setenv("MACHINE_STORAGE_PATH", "")
+		os.Setenv("SHELL", shell)
+		os.Stdout = stdout
+	}()
+
+	if err := clearHosts(); err != nil {
+		t.Fatal(err)
+	}
+
+	flags := getTestDriverFlags()
+
+	store, sErr := getTestStore()
+	if sErr != nil {
+		t.Fatal(sErr)
+	}
+
+	mcn, err := libmachine.New(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostOptions := &libmachine.HostOptions{
+		EngineOptions: &engine.EngineOptions{},
+		SwarmOptions:  &swarm.SwarmOptions{},
+		AuthOptions:   &auth.AuthOptions{},
+	}
+
+	host, err := mcn.Create("test-a", "none", hostOptions, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testMachineDir := filepath.Join(store.GetPath(), "machines", host.Name)
+	tunnelHost := "unix://" + filepath.Join(testMachineDir, "docker.sock")
+
+	if err := writeTunnelState(testMachineDir, &tunnelState{Pid: os.Getpid(), DockerHost: tunnelHost}); err != nil {
+		t.Fatal(err)
+	}
+
+	outStr := make(chan string)
+
+	go func() {
+		var testOutput bytes.Buffer
+		io.Copy(&testOutput, r)
+		outStr <- testOutput.String()
+	}()
+
+	set := flag.NewFlagSet("config", 0)
+	set.Bool("tunnel", true, "")
+	set.Parse([]string{"test-a"})
+	c := cli.NewContext(nil, set, set)
+	c.App = &cli.App{
+		Name: "docker-machine-test",
+	}
+	cmdEnv(c)
+
+	w.Close()
+
+	out := <-outStr
+
+	expected := []string{
+		"unset DOCKER_TLS_VERIFY",
+		"unset DOCKER_CERT_PATH",
+		fmt.Sprintf("export DOCKER_HOST=\"%s\"", tunnelHost),
+		`export DOCKER_MACHINE_NAME="test-a"`,
+	}
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < len(expected) {
+		t.Fatalf("expected %q; received %q", expected, out)
+	}
+
+	for i, line := range expected {
+		if strings.TrimSpace(lines[i]) != line {
+			t.Fatalf("expected %q; received %q", line, lines[i])
+		}
+	}
+}
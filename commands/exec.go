@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/log"
+	"github.com/docker/machine/ssh"
+	"github.com/docker/machine/state"
+)
+
+var (
+	ErrNoCommandSpecified = errors.New("Error: Please specify a command to run.")
+	ErrNoMachinesMatched  = errors.New("Error: No machines matched the given names and filters.")
+)
+
+type execResult struct {
+	Name     string
+	Output   string
+	ExitCode int
+	Error    string `json:",omitempty"`
+}
+
+func (r execResult) failed() bool {
+	return r.Error != "" || r.ExitCode != 0
+}
+
+func cmdExec(c *cli.Context) {
+	args := []string(c.Args())
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+
+	command := strings.Join(args, " ")
+	if command == "" {
+		log.Fatal(ErrNoCommandSpecified)
+	}
+
+	filters, err := parseFilters(c.StringSlice("filter"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mcn := getDefaultMcn(c)
+	hostList, err := mcn.List()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	hostList = filterHostsByName(filterHosts(hostList, filters), c.StringSlice("machine"))
+	if len(hostList) == 0 {
+		log.Fatal(ErrNoMachinesMatched)
+	}
+
+	asJSON := c.Bool("json")
+
+	results := execOnHosts(hostList, c.Int("parallel"), func(host *libmachine.Host) execResult {
+		result := runExecCommand(host, command)
+		if !asJSON {
+			printExecResult(result)
+		}
+		return result
+	})
+
+	if asJSON {
+		data, err := json.MarshalIndent(results, "", "    ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(data))
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.failed() {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		log.Errorf("Command failed on %d of %d machines", failed, len(results))
+		os.Exit(1)
+	}
+}
+
+func filterHostsByName(hosts []*libmachine.Host, names []string) []*libmachine.Host {
+	if len(names) == 0 {
+		return hosts
+	}
+
+	filteredHosts := []*libmachine.Host{}
+	for _, h := range hosts {
+		for _, n := range names {
+			if h.Name == n {
+				filteredHosts = append(filteredHosts, h)
+				break
+			}
+		}
+	}
+	return filteredHosts
+}
+
+// execOnHosts calls run for every host with at most parallel calls in
+// flight at once, and returns the results sorted by machine name.
+func execOnHosts(hosts []*libmachine.Host, parallel int, run func(*libmachine.Host) execResult) []execResult {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	var (
+		sem        = make(chan struct{}, parallel)
+		resultChan = make(chan execResult)
+		results    = []execResult{}
+	)
+
+	for _, host := range hosts {
+		go func(host *libmachine.Host) {
+			sem <- struct{}{}
+			result := run(host)
+			<-sem
+			resultChan <- result
+		}(host)
+	}
+
+	for _ = range hosts {
+		results = append(results, <-resultChan)
+	}
+
+	close(resultChan)
+
+	sort.Sort(execResultsByName(results))
+
+	return results
+}
+
+func runExecCommand(host *libmachine.Host, command string) execResult {
+	result := execResult{
+		Name:     host.Name,
+		ExitCode: -1,
+	}
+
+	currentState, err := host.Driver.GetState()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	if currentState != state.Running {
+		result.Error = fmt.Sprintf("machine is %s", currentState)
+		return result
+	}
+
+	output, err := host.RunSSHCommand(command)
+	result.Output = output
+	result.ExitCode = ssh.ExitStatus(err)
+	if err != nil && result.ExitCode == -1 {
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// printExecResult prints the output of a single machine with every line
+// prefixed with the machine name, so that interleaved output stays readable.
+func printExecResult(result execResult) {
+	var lines []string
+	if output := strings.TrimRight(result.Output, "\n"); output != "" {
+		lines = strings.Split(output, "\n")
+	}
+
+	switch {
+	case result.Error != "":
+		lines = append(lines, fmt.Sprintf("error: %s", result.Error))
+	case result.ExitCode != 0:
+		lines = append(lines, fmt.Sprintf("exit status %d", result.ExitCode))
+	}
+
+	for i, line := range lines {
+		lines[i] = fmt.Sprintf("%s: %s\n", result.Name, line)
+	}
+
+	fmt.Print(strings.Join(lines, ""))
+}
+
+type execResultsByName []execResult
+
+func (r execResultsByName) Len() int           { return len(r) }
+func (r execResultsByName) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r execResultsByName) Less(i, j int) bool { return r[i].Name < r[j].Name }
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterHostsByName(t *testing.T) {
+	hosts := []*libmachine.Host{{Name: "foo"}, {Name: "bar"}, {Name: "baz"}}
+
+	assert.Equal(t, hosts, filterHostsByName(hosts, []string{}))
+
+	actual := filterHostsByName(hosts, []string{"baz", "foo", "qux"})
+	assert.Equal(t, []*libmachine.Host{hosts[0], hosts[2]}, actual)
+}
+
+func TestExecOnHostsBoundsConcurrency(t *testing.T) {
+	var (
+		hosts   = []*libmachine.Host{}
+		mu      sync.Mutex
+		running = 0
+		maxSeen = 0
+	)
+
+	for i := 0; i < 20; i++ {
+		hosts = append(hosts, &libmachine.Host{Name: fmt.Sprintf("host-%02d", i)})
+	}
+
+	results := execOnHosts(hosts, 3, func(host *libmachine.Host) execResult {
+		mu.Lock()
+		running++
+		if running > maxSeen {
+			maxSeen = running
+		}
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+
+		exitCode := 0
+		if host.Name == "host-07" {
+			exitCode = 2
+		}
+
+		return execResult{Name: host.Name, Output: "ok\n", ExitCode: exitCode}
+	})
+
+	if maxSeen > 3 {
+		t.Fatalf("expected at most 3 concurrent commands; saw %d", maxSeen)
+	}
+
+	assert.Len(t, results, 20)
+
+	for i, result := range results {
+		assert.Equal(t, fmt.Sprintf("host-%02d", i), result.Name)
+		assert.Equal(t, result.Name == "host-07", result.failed())
+	}
+}
@@ -0,0 +1,172 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/docker/docker/pkg/units"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/log"
+)
+
+var (
+	ErrLoadNoImages = errors.New("Error: Please specify images with --image or archives with --input")
+)
+
+// progressInterval is how often the progress of an upload is printed.
+const progressInterval = 500 * time.Millisecond
+
+// progressReader prints how much of a reader was read to out, on one line
+// rewritten as it goes.
+type progressReader struct {
+	r     io.Reader
+	out   io.Writer
+	label string
+
+	// total is the size of the reader, or 0 if it is not known.
+	total int64
+	read  int64
+	last  time.Time
+}
+
+func newProgressReader(r io.Reader, out io.Writer, label string, total int64) *progressReader {
+	return &progressReader{
+		r:     r,
+		out:   out,
+		label: label,
+		total: total,
+	}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+
+	if err == io.EOF {
+		fmt.Fprintf(p.out, "\r%s\n", p.status())
+	} else if time.Since(p.last) >= progressInterval {
+		fmt.Fprintf(p.out, "\r%s", p.status())
+		p.last = time.Now()
+	}
+
+	return n, err
+}
+
+func (p *progressReader) status() string {
+	status := fmt.Sprintf("%s: %s", p.label, units.HumanSize(float64(p.read)))
+	if p.total > 0 {
+		status += fmt.Sprintf(" / %s (%d%%)", units.HumanSize(float64(p.total)), p.read*100/p.total)
+	}
+
+	return status
+}
+
+// saveCommand returns the command writing the images of the local docker
+// daemon to its stdout.
+var saveCommand = func(images []string) *exec.Cmd {
+	return exec.Command("docker", append([]string{"save"}, images...)...)
+}
+
+// loadSavedImages streams the images of the local docker daemon, as written
+// by docker save, into the engine of the host, with the progress printed to
+// stderr.
+func loadSavedImages(host *libmachine.Host, images []string) error {
+	cmd := saveCommand(images)
+	cmd.Stderr = os.Stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error saving the images: %s", err)
+	}
+
+	progress := newProgressReader(stdout, os.Stderr, fmt.Sprintf("%s: %s", host.Name, strings.Join(images, ", ")), 0)
+
+	if err := host.LoadImages("the images", progress); err != nil {
+		// docker save would block on writing the rest otherwise
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("error saving the images: %s", err)
+	}
+
+	return nil
+}
+
+// loadArchive streams an archive of images to the host and loads it, with
+// the progress printed to stderr.
+func loadArchive(host *libmachine.Host, archive string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Base(archive)
+	progress := newProgressReader(f, os.Stderr, fmt.Sprintf("%s: %s", host.Name, name), info.Size())
+
+	return host.LoadImages(name, progress)
+}
+
+// loadImages loads the images of the local docker daemon and the archives
+// of images, such as ones written by docker save, into the engines of the
+// hosts.  The images are saved again for each host and streamed into docker
+// load, so that they are written to neither the local nor the remote disk.
+func loadImages(hosts []*libmachine.Host, images, inputs []string) error {
+	if len(images)+len(inputs) == 0 {
+		return ErrLoadNoImages
+	}
+
+	for _, host := range hosts {
+		if len(images) > 0 {
+			log.Infof("Saving %s into %s...", strings.Join(images, ", "), host.Name)
+
+			if err := loadSavedImages(host, images); err != nil {
+				return fmt.Errorf("%s: %s", host.Name, err)
+			}
+		}
+
+		for _, archive := range inputs {
+			if err := loadArchive(host, archive); err != nil {
+				return fmt.Errorf("%s: %s", host.Name, err)
+			}
+		}
+
+		log.Infof("Loaded the images into %s", host.Name)
+	}
+
+	return nil
+}
+
+func cmdLoad(c *cli.Context) {
+	hosts, err := getHosts(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(hosts) == 0 {
+		log.Fatal(ErrNoMachineSpecified)
+	}
+
+	if err := loadImages(hosts, c.StringSlice("image"), c.StringSlice("input")); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/ssh/sshtest"
+)
+
+// newTestLoadHost returns a host backed by an SSH test server.
+func newTestLoadHost(t *testing.T, name string) (*libmachine.Host, *sshtest.Server) {
+	server, err := sshtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.Handle(`^cat /etc/os-release$`, "ID=debian\n", 0)
+
+	host := &libmachine.Host{
+		Name:   name,
+		Driver: sshtest.NewDriver(server, name),
+	}
+
+	return host, server
+}
+
+// stubSaveCommand makes docker save run the command instead.
+func stubSaveCommand(name string, args ...string) (*[][]string, func()) {
+	previous := saveCommand
+
+	saved := [][]string{}
+	saveCommand = func(images []string) *exec.Cmd {
+		saved = append(saved, images)
+		return exec.Command(name, args...)
+	}
+
+	return &saved, func() {
+		saveCommand = previous
+	}
+}
+
+func TestProgressReader(t *testing.T) {
+	var out bytes.Buffer
+
+	progress := newProgressReader(strings.NewReader(strings.Repeat("x", 2048)), &out, "dev: images.tar", 4096)
+	if _, err := ioutil.ReadAll(progress); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(out.String(), "\rdev: images.tar: 2.048 kB / 4.096 kB (50%)\n") {
+		t.Fatalf("unexpected progress %q", out.String())
+	}
+}
+
+func TestProgressReaderUnknownSize(t *testing.T) {
+	var out bytes.Buffer
+
+	progress := newProgressReader(strings.NewReader("xx"), &out, "dev: images.tar", 0)
+	if _, err := ioutil.ReadAll(progress); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(out.String(), "\rdev: images.tar: 2 B\n") {
+		t.Fatalf("unexpected progress %q", out.String())
+	}
+}
+
+func TestLoadImagesNothingToLoad(t *testing.T) {
+	if err := loadImages(nil, nil, nil); err != ErrLoadNoImages {
+		t.Fatalf("expected an error without images; received %v", err)
+	}
+}
+
+func TestLoadImagesStreamsSave(t *testing.T) {
+	saved, restore := stubSaveCommand("printf", "archive")
+	defer restore()
+
+	dev, devServer := newTestLoadHost(t, "dev")
+	defer devServer.Close()
+
+	staging, stagingServer := newTestLoadHost(t, "staging")
+	defer stagingServer.Close()
+
+	if err := loadImages([]*libmachine.Host{dev, staging}, []string{"redis:3.0", "postgres:9.4"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*saved) != 2 || strings.Join((*saved)[0], " ") != "redis:3.0 postgres:9.4" {
+		t.Fatalf("expected the images to be saved for each host; received %v", *saved)
+	}
+
+	for _, server := range []*sshtest.Server{devServer, stagingServer} {
+		if found := server.Find(`^sudo docker load$`); len(found) != 1 || string(found[0].Stdin) != "archive" {
+			t.Fatalf("expected the saved images to be streamed into docker load; received:\n%s", server)
+		}
+
+		if found := server.Find(`^cat > `); len(found) != 0 {
+			t.Fatalf("expected no temporary file; received:\n%s", server)
+		}
+	}
+}
+
+func TestLoadImagesSaveFails(t *testing.T) {
+	_, restore := stubSaveCommand("false")
+	defer restore()
+
+	dev, server := newTestLoadHost(t, "dev")
+	defer server.Close()
+
+	err := loadImages([]*libmachine.Host{dev}, []string{"redis:3.0"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "error saving the images") {
+		t.Fatalf("expected the error of docker save; received %v", err)
+	}
+}
+
+func TestLoadImagesInput(t *testing.T) {
+	f, err := ioutil.TempFile("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	f.WriteString("input archive")
+	f.Close()
+
+	dev, server := newTestLoadHost(t, "dev")
+	defer server.Close()
+
+	if err := loadImages([]*libmachine.Host{dev}, nil, []string{f.Name()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if found := server.Find(`^sudo docker load$`); len(found) != 1 || string(found[0].Stdin) != "input archive" {
+		t.Fatalf("expected the archive to be streamed into docker load; received:\n%s", server)
+	}
+}
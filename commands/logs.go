@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/docker/machine/log"
+)
+
+var (
+	ErrInvalidLines = errors.New("Error: --lines must not be negative")
+)
+
+// defaultDaemonLogLines is how many lines of the daemon log are shown if
+// --lines is not given, since it is not kept in full.
+const defaultDaemonLogLines = 100
+
+func cmdLogs(c *cli.Context) {
+	if len(c.Args()) != 1 {
+		log.Fatal(ErrExpectedOneMachine)
+	}
+
+	lines := c.Int("lines")
+	if lines < 0 {
+		log.Fatal(ErrInvalidLines)
+	}
+
+	host := getHost(c)
+
+	if c.Bool("daemon") {
+		if lines == 0 {
+			lines = defaultDaemonLogLines
+		}
+
+		output, err := host.DaemonLog(lines)
+		if err != nil {
+			log.Fatalf("Error getting the daemon log of %s: %s", host.Name, err)
+		}
+
+		fmt.Print(output)
+		return
+	}
+
+	data, err := ioutil.ReadFile(host.ProvisionLogPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Fatalf("%s has no provisioning log", host.Name)
+		}
+		log.Fatal(err)
+	}
+
+	fmt.Print(lastLines(string(data), lines))
+}
+
+// lastLines returns the last n lines of text, or all of it if n is 0.
+func lastLines(text string, n int) string {
+	if n == 0 || text == "" {
+		return text
+	}
+
+	lines := strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return strings.Join(lines, "") + "\n"
+}
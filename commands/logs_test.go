@@ -0,0 +1,23 @@
+package commands
+
+import "testing"
+
+func TestLastLines(t *testing.T) {
+	text := "one\ntwo\nthree\n"
+
+	cases := []struct {
+		n        int
+		expected string
+	}{
+		{0, text},
+		{1, "three\n"},
+		{2, "two\nthree\n"},
+		{5, text},
+	}
+
+	for _, c := range cases {
+		if out := lastLines(text, c.n); out != c.expected {
+			t.Fatalf("%d lines: expected %q; received %q", c.n, c.expected, out)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/codegangsta/cli"
+	"github.com/docker/machine/libmachine/dirsync"
+	"github.com/docker/machine/log"
+	"github.com/docker/machine/state"
+)
+
+var (
+	ErrSyncUsage = errors.New("Error: Please specify a local directory and a destination, e.g. ./src machine:/home/docker/src")
+)
+
+// parseSyncDestination splits a destination of the form machine:path.
+func parseSyncDestination(dest string) (string, string, error) {
+	parts := strings.SplitN(dest, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", ErrSyncUsage
+	}
+
+	return parts[0], parts[1], nil
+}
+
+func cmdSync(c *cli.Context) {
+	if len(c.Args()) != 2 {
+		log.Fatal(ErrSyncUsage)
+	}
+
+	name, dest, err := parseSyncDestination(c.Args()[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	host, err := loadMachine(name, c)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	currentState, err := host.Driver.GetState()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if currentState != state.Running {
+		log.Fatalf("Error: Cannot sync: Host %q is not running", host.Name)
+	}
+
+	client, err := host.CreateSSHClient()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	syncer := dirsync.NewSyncer(client, dirsync.Options{
+		Source:      c.Args()[0],
+		Destination: dest,
+		Delete:      c.Bool("delete"),
+		Ignore:      c.StringSlice("ignore"),
+	})
+
+	result, err := syncer.Sync()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	printSyncResult(result)
+
+	if !c.Bool("watch") {
+		return
+	}
+
+	stop := make(chan struct{})
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		close(stop)
+	}()
+
+	log.Infof("Watching %s for changes, press Ctrl-C to stop", c.Args()[0])
+
+	syncer.Watch(c.Duration("interval"), stop, printSyncResult)
+}
+
+func printSyncResult(result *dirsync.Result) {
+	for _, name := range result.Uploaded {
+		log.Debugf("Uploaded %s", name)
+	}
+
+	for _, name := range result.Deleted {
+		log.Debugf("Deleted %s", name)
+	}
+
+	log.Info(syncSummary(result))
+}
+
+func syncSummary(result *dirsync.Result) string {
+	if result.Empty() {
+		return "Already up to date"
+	}
+
+	summary := fmt.Sprintf("Uploaded %d files (%d bytes)", len(result.Uploaded), result.Bytes)
+	if len(result.Deleted) > 0 {
+		summary += fmt.Sprintf(", deleted %d files", len(result.Deleted))
+	}
+
+	return summary
+}
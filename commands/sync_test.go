@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/dirsync"
+)
+
+func TestParseSyncDestination(t *testing.T) {
+	name, path, err := parseSyncDestination("dev:/home/docker/src")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name != "dev" || path != "/home/docker/src" {
+		t.Fatalf("expected dev and /home/docker/src; received %q and %q", name, path)
+	}
+
+	for _, dest := range []string{"dev", "dev:", ":/tmp"} {
+		if _, _, err := parseSyncDestination(dest); err != ErrSyncUsage {
+			t.Fatalf("%s: expected usage error; received %v", dest, err)
+		}
+	}
+}
+
+func TestSyncSummary(t *testing.T) {
+	if summary := syncSummary(&dirsync.Result{}); summary != "Already up to date" {
+		t.Fatalf("unexpected summary %q", summary)
+	}
+
+	result := &dirsync.Result{
+		Uploaded: []string{"a", "b"},
+		Deleted:  []string{"c"},
+		Bytes:    42,
+	}
+
+	expected := "Uploaded 2 files (42 bytes), deleted 1 files"
+	if summary := syncSummary(result); summary != expected {
+		t.Fatalf("expected %q; received %q", expected, summary)
+	}
+}
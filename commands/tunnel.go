@@ -0,0 +1,291 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/log"
+	"github.com/docker/machine/ssh"
+	"github.com/docker/machine/state"
+	"github.com/docker/machine/utils"
+)
+
+var (
+	ErrNoForwards      = errors.New("Error: Please specify a port to forward with --forward or --docker.")
+	ErrTunnelNotActive = errors.New("no tunnel is running")
+)
+
+// tunnelState is written to the machine directory by a running tunnel so
+// that it can be stopped later on and so that env can point at it.
+type tunnelState struct {
+	Pid        int
+	DockerHost string
+	Forwards   []ssh.Forward
+}
+
+func tunnelStatePath(machineDir string) string {
+	return filepath.Join(machineDir, "tunnel.json")
+}
+
+func readTunnelState(machineDir string) (*tunnelState, error) {
+	data, err := ioutil.ReadFile(tunnelStatePath(machineDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrTunnelNotActive
+		}
+		return nil, err
+	}
+
+	var st tunnelState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, err
+	}
+
+	if !processRunning(st.Pid) {
+		// the tunnel went away without cleaning up after itself
+		removeTunnelState(machineDir, &st)
+		return nil, ErrTunnelNotActive
+	}
+
+	return &st, nil
+}
+
+func writeTunnelState(machineDir string, st *tunnelState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(tunnelStatePath(machineDir), data, 0600)
+}
+
+func removeTunnelState(machineDir string, st *tunnelState) {
+	for _, f := range st.Forwards {
+		if f.LocalNetwork == "unix" {
+			os.Remove(f.LocalAddr)
+		}
+	}
+
+	os.Remove(tunnelStatePath(machineDir))
+}
+
+func getTunnelForwards(c *cli.Context, machineDir string) ([]ssh.Forward, string, error) {
+	var (
+		forwards   = []ssh.Forward{}
+		dockerHost = ""
+	)
+
+	for _, spec := range c.StringSlice("forward") {
+		f, err := ssh.ParseForward(spec)
+		if err != nil {
+			return nil, "", err
+		}
+		forwards = append(forwards, f)
+	}
+
+	if c.Bool("docker") {
+		local := c.String("docker-listen")
+		if local == "" {
+			local = filepath.Join(machineDir, "docker.sock")
+		}
+
+		f, err := ssh.NewDockerForward(local)
+		if err != nil {
+			return nil, "", err
+		}
+		forwards = append(forwards, f)
+		dockerHost = fmt.Sprintf("%s://%s", f.LocalNetwork, f.LocalAddr)
+	}
+
+	if len(forwards) == 0 {
+		return nil, "", ErrNoForwards
+	}
+
+	return forwards, dockerHost, nil
+}
+
+func cmdTunnel(c *cli.Context) {
+	if len(c.Args()) != 1 {
+		log.Fatal(ErrExpectedOneMachine)
+	}
+
+	host, err := loadMachine(c.Args().First(), c)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	machineDir := filepath.Join(utils.GetMachineDir(), host.Name)
+
+	if c.Bool("stop") {
+		if err := stopTunnel(machineDir); err != nil {
+			log.Fatalf("Error stopping tunnel for %s: %s", host.Name, err)
+		}
+		return
+	}
+
+	forwards, dockerHost, err := getTunnelForwards(c, machineDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if st, err := readTunnelState(machineDir); err == nil {
+		log.Fatalf("A tunnel is already running for %s (pid %d), stop it with: %s tunnel --stop %s", host.Name, st.Pid, c.App.Name, host.Name)
+	}
+
+	currentState, err := host.Driver.GetState()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if currentState != state.Running {
+		log.Fatalf("Error: Cannot open tunnel: Host %q is not running", host.Name)
+	}
+
+	if c.Bool("background") {
+		if err := startBackgroundTunnel(machineDir); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := runTunnel(host, machineDir, forwards, dockerHost); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runTunnel(host *libmachine.Host, machineDir string, forwards []ssh.Forward, dockerHost string) error {
+	tunnel, err := host.CreateSSHTunnel(forwards)
+	if err != nil {
+		return err
+	}
+
+	if err := tunnel.Listen(); err != nil {
+		return err
+	}
+
+	st := &tunnelState{
+		Pid:        os.Getpid(),
+		DockerHost: dockerHost,
+		Forwards:   forwards,
+	}
+
+	if err := writeTunnelState(machineDir, st); err != nil {
+		tunnel.Close()
+		return err
+	}
+	defer removeTunnelState(machineDir, st)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		tunnel.Close()
+	}()
+
+	for _, f := range forwards {
+		log.Infof("Forwarding %s", f)
+	}
+
+	if dockerHost != "" {
+		log.Infof("Docker is available at %s", dockerHost)
+	}
+
+	return tunnel.Serve()
+}
+
+// withoutBackgroundFlag returns the command line arguments without the
+// background flag, in any of its forms.  Arguments which are not flags, such
+// as a machine named b, are kept.
+func withoutBackgroundFlag(args []string) []string {
+	filtered := []string{}
+	for i, arg := range args {
+		if arg == "--" {
+			return append(filtered, args[i:]...)
+		}
+
+		if strings.HasPrefix(arg, "-") {
+			switch strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-") {
+			case "b", "background", "b=true", "background=true":
+				continue
+			}
+		}
+
+		filtered = append(filtered, arg)
+	}
+
+	return filtered
+}
+
+// startBackgroundTunnel runs the same tunnel command again without the
+// background flag as a detached process, and waits for it to come up.
+func startBackgroundTunnel(machineDir string) error {
+	args := withoutBackgroundFlag(os.Args[1:])
+
+	logPath := filepath.Join(machineDir, "tunnel.log")
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detachProcess(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	for i := 0; i < 100; i++ {
+		select {
+		case <-exited:
+			return fmt.Errorf("Tunnel exited, see %s for details", logPath)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		if st, err := readTunnelState(machineDir); err == nil && st.Pid == cmd.Process.Pid {
+			log.Infof("Tunnel running in the background (pid %d), logging to %s", st.Pid, logPath)
+			if st.DockerHost != "" {
+				log.Infof("Docker is available at %s", st.DockerHost)
+			}
+			return nil
+		}
+	}
+
+	stopProcess(cmd.Process.Pid)
+	return fmt.Errorf("Timed out waiting for tunnel, see %s for details", logPath)
+}
+
+func stopTunnel(machineDir string) error {
+	st, err := readTunnelState(machineDir)
+	if err != nil {
+		return err
+	}
+
+	if err := stopProcess(st.Pid); err != nil {
+		return err
+	}
+
+	removeTunnelState(machineDir, st)
+
+	log.Infof("Stopped tunnel (pid %d)", st.Pid)
+
+	return nil
+}
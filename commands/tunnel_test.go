@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/ssh"
+)
+
+func TestTunnelState(t *testing.T) {
+	machineDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(machineDir)
+
+	if _, err := readTunnelState(machineDir); err != ErrTunnelNotActive {
+		t.Fatalf("expected %s; received %v", ErrTunnelNotActive, err)
+	}
+
+	forward, err := ssh.NewDockerForward("2375")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st := &tunnelState{
+		Pid:        os.Getpid(),
+		DockerHost: "tcp://127.0.0.1:2375",
+		Forwards:   []ssh.Forward{forward},
+	}
+
+	if err := writeTunnelState(machineDir, st); err != nil {
+		t.Fatal(err)
+	}
+
+	dockerHost, err := getTunnelDockerHost(machineDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dockerHost != st.DockerHost {
+		t.Fatalf("expected docker host %s; received %s", st.DockerHost, dockerHost)
+	}
+
+	removeTunnelState(machineDir, st)
+
+	if _, err := getTunnelDockerHost(machineDir); err != ErrTunnelNotActive {
+		t.Fatalf("expected %s; received %v", ErrTunnelNotActive, err)
+	}
+}
+
+func TestWithoutBackgroundFlag(t *testing.T) {
+	cases := map[string][]string{
+		"tunnel -b dev":                        {"tunnel", "dev"},
+		"tunnel --background --forward 80 dev": {"tunnel", "--forward", "80", "dev"},
+		"tunnel --background=true b":           {"tunnel", "b"},
+		"tunnel -b background":                 {"tunnel", "background"},
+		"tunnel --docker -- -b":                {"tunnel", "--docker", "--", "-b"},
+	}
+
+	for line, expected := range cases {
+		args := withoutBackgroundFlag(strings.Fields(line))
+		if strings.Join(args, " ") != strings.Join(expected, " ") {
+			t.Fatalf("%s: expected %v; received %v", line, expected, args)
+		}
+	}
+}
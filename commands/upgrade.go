@@ -1,13 +1,63 @@
 package commands
 
 import (
+	"errors"
+	"fmt"
+
+	"github.com/docker/machine/libmachine"
 	"github.com/docker/machine/log"
 
 	"github.com/codegangsta/cli"
 )
 
+var (
+	ErrUpgradeVersionAndUnpin = errors.New("Error: --version and --unpin cannot be used together")
+)
+
+// pinEngineVersion pins the machines to the version, or unpins them to
+// upgrade to the latest one.  Machines already pinned stay so when neither
+// is asked for.
+func pinEngineVersion(machines []*libmachine.Host, version string, unpin bool) error {
+	if version != "" && unpin {
+		return ErrUpgradeVersionAndUnpin
+	}
+
+	if version == "" && !unpin {
+		return nil
+	}
+
+	for _, machine := range machines {
+		machine.PinEngineVersion(version)
+	}
+
+	return nil
+}
+
 func cmdUpgrade(c *cli.Context) {
-	if err := runActionWithContext("upgrade", c); err != nil {
+	machines, err := getHosts(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(machines) == 0 {
+		log.Fatal(ErrNoMachineSpecified)
+	}
+
+	if err := pinEngineVersion(machines, c.String("version"), c.Bool("unpin")); err != nil {
 		log.Fatal(err)
 	}
+
+	if c.Bool("dry-run") {
+		for _, machine := range machines {
+			plan, err := machine.PlanUpgrade()
+			if err != nil {
+				log.Fatalf("Error planning the upgrade of %s: %s", machine.Name, err)
+			}
+
+			fmt.Println(plan)
+		}
+		return
+	}
+
+	runActionForeachMachine("upgrade", machines)
 }
@@ -1 +1,55 @@
 package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/engine"
+)
+
+func newTestPinnedHost(version string) *libmachine.Host {
+	return &libmachine.Host{
+		Name: "test",
+		HostOptions: &libmachine.HostOptions{
+			EngineOptions: &engine.EngineOptions{Version: version},
+		},
+	}
+}
+
+func TestPinEngineVersion(t *testing.T) {
+	cases := []struct {
+		pinned, version string
+		unpin           bool
+		expected        string
+	}{
+		{"1.8.2", "", false, "1.8.2"},
+		{"1.8.2", "1.9.0", false, "1.9.0"},
+		{"1.8.2", "", true, ""},
+		{"", "", false, ""},
+		{"", "1.9.0", false, "1.9.0"},
+	}
+
+	for _, c := range cases {
+		host := newTestPinnedHost(c.pinned)
+
+		if err := pinEngineVersion([]*libmachine.Host{host}, c.version, c.unpin); err != nil {
+			t.Fatal(err)
+		}
+
+		if version := host.HostOptions.EngineOptions.Version; version != c.expected {
+			t.Errorf("pinned to %q, --version %q, --unpin %v: expected %q; received %q", c.pinned, c.version, c.unpin, c.expected, version)
+		}
+	}
+}
+
+func TestPinEngineVersionAndUnpin(t *testing.T) {
+	host := newTestPinnedHost("1.8.2")
+
+	if err := pinEngineVersion([]*libmachine.Host{host}, "1.9.0", true); err != ErrUpgradeVersionAndUnpin {
+		t.Fatalf("expected an error with both --version and --unpin; received %v", err)
+	}
+
+	if version := host.HostOptions.EngineOptions.Version; version != "1.8.2" {
+		t.Fatalf("expected the machine to stay pinned; received %q", version)
+	}
+}
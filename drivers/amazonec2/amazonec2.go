@@ -44,6 +44,7 @@ type Driver struct {
 	AMI                 string
 	SSHKeyID            int
 	SSHUser             string
+	SSHKeyType          ssh.KeyType
 	SSHPort             int
 	KeyName             string
 	InstanceId          string
@@ -70,6 +71,7 @@ type Driver struct {
 	SpotPrice           string
 	PrivateIPOnly       bool
 	Monitoring          bool
+	UserData            string
 }
 
 func init() {
@@ -226,6 +228,10 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
 	d.SSHUser = flags.String("amazonec2-ssh-user")
+	d.SSHKeyType = ssh.KeyType(flags.String("ssh-key-type"))
+	if d.SSHKeyType != "" && d.SSHKeyType != ssh.RSA {
+		return fmt.Errorf("amazonec2 driver only supports rsa SSH keys, received %q", d.SSHKeyType)
+	}
 	d.SSHPort = 22
 	d.PrivateIPOnly = flags.Bool("amazonec2-private-address-only")
 	d.Monitoring = flags.Bool("amazonec2-monitoring")
@@ -380,7 +386,7 @@ func (d *Driver) Create() error {
 			return fmt.Errorf("Error get instance: %s", err)
 		}
 	} else {
-		inst, err := d.getClient().RunInstance(d.AMI, d.InstanceType, d.Zone, 1, 1, d.SecurityGroupId, d.KeyName, d.SubnetId, bdm, d.IamInstanceProfile, d.PrivateIPOnly, d.Monitoring)
+		inst, err := d.getClient().RunInstance(d.AMI, d.InstanceType, d.Zone, 1, 1, d.SecurityGroupId, d.KeyName, d.SubnetId, bdm, d.IamInstanceProfile, d.PrivateIPOnly, d.Monitoring, d.UserData)
 		if err != nil {
 			return fmt.Errorf("Error launching instance: %s", err)
 		}
@@ -571,7 +577,7 @@ func (d *Driver) waitForInstance() error {
 
 func (d *Driver) createKeyPair() error {
 
-	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
 		return err
 	}
 
@@ -580,9 +586,14 @@ func (d *Driver) createKeyPair() error {
 		return err
 	}
 
+	fingerprint, err := ssh.FingerprintSHA256(publicKey)
+	if err != nil {
+		return err
+	}
+
 	keyName := d.MachineName
 
-	log.Debugf("creating key pair: %s", keyName)
+	log.Debugf("creating key pair: %s fingerprint=%s", keyName, fingerprint)
 
 	if err := d.getClient().ImportKeyPair(keyName, string(publicKey)); err != nil {
 		return err
@@ -748,3 +759,8 @@ func generateId() string {
 	io.WriteString(h, string(rb))
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
+
+// SetUserData sets the user-data passed to the instance on creation.
+func (d *Driver) SetUserData(userData string) {
+	d.UserData = userData
+}
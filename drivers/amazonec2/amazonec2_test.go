@@ -65,6 +65,7 @@ func getDefaultTestDriverFlags() *DriverOptionsMock {
 			"swarm-host":                      "",
 			"swarm-master":                    false,
 			"swarm-discovery":                 "",
+			"ssh-key-type":                    "rsa",
 			"amazonec2-ami":                   "ami-12345",
 			"amazonec2-access-key":            "abcdefg",
 			"amazonec2-secret-key":            "12345",
@@ -200,6 +201,26 @@ func TestConfigureSecurityGroupPermissionsDockerAndSsh(t *testing.T) {
 	}
 }
 
+func TestSetConfigFromFlagsRejectsNonRSAKeys(t *testing.T) {
+	storePath, err := getTestStorePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	d, err := NewDriver(machineTestName, storePath, machineTestCaCert, machineTestPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flags := getDefaultTestDriverFlags()
+	flags.Data["ssh-key-type"] = "ed25519"
+
+	if err := d.SetConfigFromFlags(flags); err == nil {
+		t.Fatal("expected an ed25519 key to be rejected")
+	}
+}
+
 func TestAwsRegionList(t *testing.T) {
 }
 
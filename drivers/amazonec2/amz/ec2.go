@@ -177,7 +177,7 @@ func (e *EC2) awsApiCall(v url.Values) (*http.Response, error) {
 	return resp, nil
 }
 
-func (e *EC2) RunInstance(amiId string, instanceType string, zone string, minCount int, maxCount int, securityGroup string, keyName string, subnetId string, bdm *BlockDeviceMapping, role string, privateIPOnly bool, monitoring bool) (EC2Instance, error) {
+func (e *EC2) RunInstance(amiId string, instanceType string, zone string, minCount int, maxCount int, securityGroup string, keyName string, subnetId string, bdm *BlockDeviceMapping, role string, privateIPOnly bool, monitoring bool, userData string) (EC2Instance, error) {
 	instance := Instance{}
 	v := url.Values{}
 	v.Set("Action", "RunInstances")
@@ -205,6 +205,10 @@ func (e *EC2) RunInstance(amiId string, instanceType string, zone string, minCou
 		v.Set("IamInstanceProfile.Name", role)
 	}
 
+	if len(userData) > 0 {
+		v.Set("UserData", base64.StdEncoding.EncodeToString([]byte(userData)))
+	}
+
 	if bdm != nil {
 		v.Set("BlockDeviceMapping.0.DeviceName", bdm.DeviceName)
 		v.Set("BlockDeviceMapping.0.VirtualName", bdm.VirtualName)
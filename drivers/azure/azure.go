@@ -30,6 +30,7 @@ type Driver struct {
 	UserPassword            string
 	Image                   string
 	SSHUser                 string
+	SSHKeyType              ssh.KeyType
 	SSHPort                 int
 	DockerPort              int
 	CaCertPath              string
@@ -191,6 +192,10 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	}
 
 	d.SSHUser = username
+	d.SSHKeyType = ssh.KeyType(flags.String("ssh-key-type"))
+	if d.SSHKeyType != "" && d.SSHKeyType != ssh.RSA {
+		return fmt.Errorf("azure driver only supports rsa SSH keys, received %q", d.SSHKeyType)
+	}
 	d.UserPassword = flags.String("azure-password")
 	d.DockerPort = flags.Int("azure-docker-port")
 	d.SSHPort = flags.Int("azure-ssh-port")
@@ -426,7 +431,7 @@ func (d *Driver) addDockerEndpoint(vmConfig *vmClient.Role) error {
 }
 
 func (d *Driver) generateCertForAzure() error {
-	if err := ssh.GenerateSSHKey(d.sshKeyPath()); err != nil {
+	if err := ssh.GenerateSSHKey(d.sshKeyPath(), d.SSHKeyType); err != nil {
 		return err
 	}
 
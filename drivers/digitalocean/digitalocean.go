@@ -25,6 +25,7 @@ type Driver struct {
 	Region            string
 	SSHKeyID          int
 	SSHUser           string
+	SSHKeyType        ssh.KeyType
 	SSHPort           int
 	Size              string
 	IPv6              bool
@@ -37,6 +38,7 @@ type Driver struct {
 	SwarmHost         string
 	SwarmDiscovery    string
 	storePath         string
+	UserData          string
 }
 
 func init() {
@@ -147,6 +149,7 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
 	d.SSHUser = "root"
+	d.SSHKeyType = ssh.KeyType(flags.String("ssh-key-type"))
 	d.SSHPort = 22
 
 	if d.AccessToken == "" {
@@ -194,6 +197,7 @@ func (d *Driver) Create() error {
 		PrivateNetworking: d.PrivateNetworking,
 		Backups:           d.Backups,
 		SSHKeys:           []interface{}{d.SSHKeyID},
+		UserData:          d.UserData,
 	}
 
 	newDroplet, _, err := client.Droplets.Create(createRequest)
@@ -229,7 +233,7 @@ func (d *Driver) Create() error {
 }
 
 func (d *Driver) createSSHKey() (*godo.Key, error) {
-	if err := ssh.GenerateSSHKey(d.sshKeyPath()); err != nil {
+	if err := ssh.GenerateSSHKey(d.sshKeyPath(), d.SSHKeyType); err != nil {
 		return nil, err
 	}
 
@@ -238,6 +242,13 @@ func (d *Driver) createSSHKey() (*godo.Key, error) {
 		return nil, err
 	}
 
+	fingerprint, err := ssh.FingerprintSHA256(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Uploading SSH key with fingerprint %s", fingerprint)
+
 	createRequest := &godo.KeyCreateRequest{
 		Name:      d.MachineName,
 		PublicKey: string(publicKey),
@@ -336,3 +347,8 @@ func (d *Driver) sshKeyPath() string {
 func (d *Driver) publicSSHKeyPath() string {
 	return d.sshKeyPath() + ".pub"
 }
+
+// SetUserData sets the user-data passed to the instance on creation.
+func (d *Driver) SetUserData(userData string) {
+	d.UserData = userData
+}
@@ -81,6 +81,27 @@ type Driver interface {
 	Stop() error
 }
 
+// UserDataDriver is implemented by the drivers whose provider passes
+// user-data, such as a cloud-config document, to the host on its first boot.
+type UserDataDriver interface {
+	Driver
+
+	// SetUserData sets the user-data passed to the host by Create
+	SetUserData(userData string)
+}
+
+// HostnameDriver is implemented by the drivers whose provider names the
+// host, which is then provisioned with that hostname and domain.
+type HostnameDriver interface {
+	Driver
+
+	// GetHostname returns the hostname the host is created with
+	GetHostname() string
+
+	// GetDomain returns the domain of the host, which may be empty
+	GetDomain() string
+}
+
 // RegisteredDriver is used to register a driver with the Register function.
 // It has two attributes:
 // - New: a function that returns a new driver given a path to store host
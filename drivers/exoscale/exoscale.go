@@ -38,6 +38,7 @@ type Driver struct {
 	SwarmHost        string
 	SwarmDiscovery   string
 	storePath        string
+	UserData         string
 }
 
 func init() {
@@ -458,11 +459,12 @@ func (d *Driver) waitForVM(client *egoscale.Client, jobid string) (*egoscale.Dep
 }
 
 // Build a cloud-init user data string that will install and run
-// docker.
+// docker.  The cloud-config set with SetUserData already manages
+// /etc/hosts, so only the exoscale settings are added to it.
 func (d *Driver) getCloudInit() (string, error) {
-	const tpl = `#cloud-config
+	const tpl = `{{ if .UserData }}{{ .UserData }}{{ else }}#cloud-config
 manage_etc_hosts: true
-fqdn: {{ .MachineName }}
+{{ end }}fqdn: {{ .MachineName }}
 resize_rootfs: true
 `
 	var buffer bytes.Buffer
@@ -477,3 +479,8 @@ resize_rootfs: true
 	}
 	return buffer.String(), nil
 }
+
+// SetUserData sets the user-data passed to the instance on creation.
+func (d *Driver) SetUserData(userData string) {
+	d.UserData = userData
+}
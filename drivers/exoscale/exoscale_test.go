@@ -1 +1,38 @@
 package exoscale
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetCloudInit(t *testing.T) {
+	d := &Driver{MachineName: "test"}
+
+	userdata, err := d.getCloudInit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "#cloud-config\nmanage_etc_hosts: true\nfqdn: test\nresize_rootfs: true\n"
+	if userdata != expected {
+		t.Fatalf("expected %q; received %q", expected, userdata)
+	}
+}
+
+func TestGetCloudInitMergesUserData(t *testing.T) {
+	d := &Driver{MachineName: "test"}
+	d.SetUserData("#cloud-config\nhostname: \"dev\"\nruncmd:\n  - [sh, -c, \"true\"]\n")
+
+	userdata, err := d.getCloudInit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(userdata, "#cloud-config\nhostname: \"dev\"\n") {
+		t.Fatalf("expected the user-data to be kept; received %q", userdata)
+	}
+
+	if !strings.HasSuffix(userdata, "\nfqdn: test\nresize_rootfs: true\n") {
+		t.Fatalf("expected the exoscale settings to be added; received %q", userdata)
+	}
+}
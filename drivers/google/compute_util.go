@@ -179,6 +179,11 @@ func (c *ComputeUtil) createInstance(d *Driver) error {
 			},
 		},
 	}
+	if d.UserData != "" {
+		instance.Metadata = &raw.Metadata{
+			Items: []*raw.MetadataItems{c.userDataItem(d)},
+		}
+	}
 	disk, err := c.disk()
 	if disk == nil || err != nil {
 		instance.Disks[0].InitializeParams = &raw.AttachedDiskInitializeParams{
@@ -213,14 +218,19 @@ func (c *ComputeUtil) createInstance(d *Driver) error {
 		return err
 	}
 	log.Infof("Uploading SSH Key")
+	items := []*raw.MetadataItems{
+		{
+			Key:   "sshKeys",
+			Value: c.userName + ":" + string(sshKey) + "\n",
+		},
+	}
+	// setting the metadata replaces all of it, keep the user-data
+	if d.UserData != "" {
+		items = append(items, c.userDataItem(d))
+	}
 	op, err = c.service.Instances.SetMetadata(c.project, c.zone, c.instanceName, &raw.Metadata{
 		Fingerprint: instance.Metadata.Fingerprint,
-		Items: []*raw.MetadataItems{
-			{
-				Key:   "sshKeys",
-				Value: c.userName + ":" + string(sshKey) + "\n",
-			},
-		},
+		Items:       items,
 	}).Do()
 	if err != nil {
 		return err
@@ -305,3 +315,11 @@ func (c *ComputeUtil) ip() (string, error) {
 	}
 	return c.ipAddress, nil
 }
+
+// userDataItem returns the metadata item read by cloud-init on the instance.
+func (c *ComputeUtil) userDataItem(d *Driver) *raw.MetadataItems {
+	return &raw.MetadataItems{
+		Key:   "user-data",
+		Value: d.UserData,
+	}
+}
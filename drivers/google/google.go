@@ -16,6 +16,7 @@ type Driver struct {
 	IPAddress      string
 	MachineName    string
 	SSHUser        string
+	SSHKeyType     ssh.KeyType
 	SSHPort        int
 	Zone           string
 	MachineType    string
@@ -30,6 +31,7 @@ type Driver struct {
 	SwarmMaster    bool
 	SwarmHost      string
 	SwarmDiscovery string
+	UserData       string
 }
 
 func init() {
@@ -159,6 +161,7 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 		return fmt.Errorf("Please specify the Google Cloud Project name using the option --google-project.")
 	}
 	d.SSHUser = flags.String("google-username")
+	d.SSHKeyType = ssh.KeyType(flags.String("ssh-key-type"))
 	d.SSHPort = 22
 	return nil
 }
@@ -185,7 +188,7 @@ func (d *Driver) Create() error {
 	}
 
 	log.Infof("Generating SSH Key")
-	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
 		return err
 	}
 
@@ -301,3 +304,8 @@ func (d *Driver) Restart() error {
 func (d *Driver) Kill() error {
 	return d.Stop()
 }
+
+// SetUserData sets the user-data passed to the instance on creation.
+func (d *Driver) SetUserData(userData string) {
+	d.UserData = userData
+}
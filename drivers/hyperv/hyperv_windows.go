@@ -20,6 +20,7 @@ import (
 type Driver struct {
 	IPAddress      string
 	SSHUser        string
+	SSHKeyType     ssh.KeyType
 	SSHPort        int
 	storePath      string
 	boot2DockerURL string
@@ -82,6 +83,7 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
 	d.SSHUser = "docker"
+	d.SSHKeyType = ssh.KeyType(flags.String("ssh-key-type"))
 	d.SSHPort = 22
 	return nil
 }
@@ -231,7 +233,7 @@ func (d *Driver) Create() error {
 
 	log.Infof("Creating SSH key...")
 
-	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
 		return err
 	}
 
@@ -60,6 +60,9 @@ func (c *GenericClient) CreateInstance(d *Driver) (string, error) {
 		SecurityGroups:   d.SecurityGroups,
 		AvailabilityZone: d.AvailabilityZone,
 	}
+	if d.UserData != "" {
+		serverOpts.UserData = []byte(d.UserData)
+	}
 	if d.NetworkId != "" {
 		serverOpts.Networks = []servers.Network{
 			{
@@ -40,6 +40,7 @@ type Driver struct {
 	FloatingIpPool   string
 	FloatingIpPoolId string
 	SSHUser          string
+	SSHKeyType       ssh.KeyType
 	SSHPort          int
 	IPAddress        string
 	CaCertPath       string
@@ -49,6 +50,7 @@ type Driver struct {
 	SwarmHost        string
 	SwarmDiscovery   string
 	client           Client
+	UserData         string
 }
 
 func init() {
@@ -261,6 +263,7 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	}
 	d.FloatingIpPool = flags.String("openstack-floatingip-pool")
 	d.SSHUser = flags.String("openstack-ssh-user")
+	d.SSHKeyType = ssh.KeyType(flags.String("ssh-key-type"))
 	d.SSHPort = flags.Int("openstack-ssh-port")
 	d.SwarmMaster = flags.Bool("swarm-master")
 	d.SwarmHost = flags.String("swarm-host")
@@ -588,7 +591,7 @@ func (d *Driver) initNetwork() error {
 
 func (d *Driver) createSSHKey() error {
 	log.WithField("Name", d.KeyPairName).Debug("Creating Key Pair...")
-	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
 		return err
 	}
 	publicKey, err := ioutil.ReadFile(d.publicSSHKeyPath())
@@ -596,6 +599,12 @@ func (d *Driver) createSSHKey() error {
 		return err
 	}
 
+	fingerprint, err := ssh.FingerprintSHA256(publicKey)
+	if err != nil {
+		return err
+	}
+	log.WithField("Fingerprint", fingerprint).Debug("Uploading SSH key...")
+
 	if err := d.initCompute(); err != nil {
 		return err
 	}
@@ -694,3 +703,8 @@ func (d *Driver) lookForIpAddress() error {
 func (d *Driver) publicSSHKeyPath() string {
 	return d.GetSSHKeyPath() + ".pub"
 }
+
+// SetUserData sets the user-data passed to the instance on creation.
+func (d *Driver) SetUserData(userData string) {
+	d.UserData = userData
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/docker/machine/drivers"
 	"github.com/docker/machine/drivers/openstack"
 	"github.com/docker/machine/log"
+	"github.com/docker/machine/ssh"
 )
 
 // Driver is a machine driver for Rackspace. It's a specialization of the generic OpenStack one.
@@ -122,6 +123,7 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.ImageId = flags.String("rackspace-image-id")
 	d.FlavorId = flags.String("rackspace-flavor-id")
 	d.SSHUser = flags.String("rackspace-ssh-user")
+	d.SSHKeyType = ssh.KeyType(flags.String("ssh-key-type"))
 	d.SSHPort = flags.Int("rackspace-ssh-port")
 	d.SwarmMaster = flags.Bool("swarm-master")
 	d.SwarmHost = flags.String("swarm-host")
@@ -26,6 +26,7 @@ type Driver struct {
 	Id             int
 	Client         *Client
 	SSHUser        string
+	SSHKeyType     ssh.KeyType
 	SSHPort        int
 	MachineName    string
 	CaCertPath     string
@@ -34,6 +35,7 @@ type Driver struct {
 	SwarmMaster    bool
 	SwarmHost      string
 	SwarmDiscovery string
+	UserData       string
 }
 
 type deviceConfig struct {
@@ -74,6 +76,24 @@ func (d *Driver) GetMachineName() string {
 	return d.MachineName
 }
 
+// GetHostname returns the hostname of the device, which is only known when
+// the machine is created, as the device config is not saved.
+func (d *Driver) GetHostname() string {
+	if d.deviceConfig == nil {
+		return ""
+	}
+
+	return d.deviceConfig.Hostname
+}
+
+func (d *Driver) GetDomain() string {
+	if d.deviceConfig == nil {
+		return ""
+	}
+
+	return d.deviceConfig.Domain
+}
+
 func (d *Driver) GetSSHHostname() (string, error) {
 	return d.GetIP()
 }
@@ -247,6 +267,7 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
 	d.SSHUser = "root"
+	d.SSHKeyType = ssh.KeyType(flags.String("ssh-key-type"))
 	d.SSHPort = 22
 
 	if err := validateClientConfig(d.Client); err != nil {
@@ -443,6 +464,9 @@ func (d *Driver) buildHostSpec() *HostSpec {
 		PrivateNetOnly: d.deviceConfig.PrivateNet,
 		LocalDisk:      d.deviceConfig.LocalDisk,
 	}
+	if d.UserData != "" {
+		spec.UserData = []UserData{{Value: d.UserData}}
+	}
 	if d.deviceConfig.DiskSize > 0 {
 		spec.BlockDevices = []BlockDevice{{Device: "0", DiskImage: DiskImage{Capacity: d.deviceConfig.DiskSize}}}
 	}
@@ -465,7 +489,7 @@ func (d *Driver) buildHostSpec() *HostSpec {
 }
 
 func (d *Driver) createSSHKey() (*SshKey, error) {
-	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
 		return nil, err
 	}
 
@@ -474,6 +498,13 @@ func (d *Driver) createSSHKey() (*SshKey, error) {
 		return nil, err
 	}
 
+	fingerprint, err := ssh.FingerprintSHA256(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Uploading SSH key with fingerprint %s", fingerprint)
+
 	key, err := d.getClient().SshKey().Create(d.deviceConfig.Hostname, string(publicKey))
 	if err != nil {
 		return nil, err
@@ -520,3 +551,8 @@ func (d *Driver) Start() error {
 func (d *Driver) Stop() error {
 	return d.getClient().VirtualGuest().PowerOff(d.Id)
 }
+
+// SetUserData sets the user-data passed to the instance on creation.
+func (d *Driver) SetUserData(userData string) {
+	d.UserData = userData
+}
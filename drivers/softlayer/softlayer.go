@@ -30,6 +30,11 @@ type HostSpec struct {
 	LocalDisk                      bool              `json:"localDiskFlag"`
 	PrimaryNetworkComponent        *NetworkComponent `json:"primaryNetworkComponent,omitempty"`
 	PrimaryBackendNetworkComponent *NetworkComponent `json:"primaryBackendNetworkComponent,omitempty"`
+	UserData                       []UserData        `json:"userData,omitempty"`
+}
+
+type UserData struct {
+	Value string `json:"value"`
 }
 
 type NetworkComponent struct {
@@ -40,6 +40,7 @@ type Driver struct {
 	CPU                 int
 	MachineName         string
 	SSHUser             string
+	SSHKeyType          ssh.KeyType
 	SSHPort             int
 	Memory              int
 	DiskSize            int
@@ -163,6 +164,7 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.SwarmHost = flags.String("swarm-host")
 	d.SwarmDiscovery = flags.String("swarm-discovery")
 	d.SSHUser = "docker"
+	d.SSHKeyType = ssh.KeyType(flags.String("ssh-key-type"))
 	d.Boot2DockerImportVM = flags.String("virtualbox-import-boot2docker-vm")
 	d.HostOnlyCIDR = flags.String("virtualbox-hostonly-cidr")
 
@@ -226,7 +228,7 @@ func (d *Driver) Create() error {
 		}
 	} else {
 		log.Infof("Creating SSH key...")
-		if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+		if err := ssh.GenerateSSHKey(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
 			return err
 		}
 
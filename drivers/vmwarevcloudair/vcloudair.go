@@ -33,6 +33,7 @@ type Driver struct {
 	CatalogItem    string
 	MachineName    string
 	SSHUser        string
+	SSHKeyType     ssh.KeyType
 	SSHPort        int
 	DockerPort     int
 	Provision      bool
@@ -227,6 +228,7 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 
 	d.DockerPort = flags.Int("vmwarevcloudair-docker-port")
 	d.SSHUser = "root"
+	d.SSHKeyType = ssh.KeyType(flags.String("ssh-key-type"))
 	d.SSHPort = flags.Int("vmwarevcloudair-ssh-port")
 	d.Provision = flags.Bool("vmwarevcloudair-provision")
 	d.CPUCount = flags.Int("vmwarevcloudair-cpu-count")
@@ -692,7 +694,7 @@ func generateVMName() string {
 }
 
 func (d *Driver) createSSHKey() (string, error) {
-	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath(), d.SSHKeyType); err != nil {
 		return "", err
 	}
 
@@ -11,4 +11,8 @@ type AuthOptions struct {
 	ServerKeyRemotePath  string
 	PrivateKeyPath       string
 	ClientCertPath       string
+
+	// ServerCertSANs are the names the server certificate is valid for,
+	// besides the IP of the machine.
+	ServerCertSANs []string
 }
@@ -0,0 +1,443 @@
+package dirsync
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/log"
+	"github.com/docker/machine/ssh"
+)
+
+var (
+	ErrNotDirectory = errors.New("source is not a directory")
+)
+
+type Options struct {
+	// Source is the local directory to mirror.
+	Source string
+
+	// Destination is the directory on the machine.  It is created if
+	// it does not exist yet.
+	Destination string
+
+	// Delete removes files from the destination which do not exist in
+	// the source.
+	Delete bool
+
+	// Ignore holds patterns in filepath.Match syntax for files and
+	// directories to leave alone.  Patterns without a slash match the
+	// name of a file at any depth, others match its path relative to
+	// the source.
+	Ignore []string
+}
+
+// Result describes the changes made by a single sync.
+type Result struct {
+	Uploaded []string
+	Deleted  []string
+	Bytes    int64
+}
+
+func (r *Result) Empty() bool {
+	return len(r.Uploaded) == 0 && len(r.Deleted) == 0
+}
+
+type localFile struct {
+	Size    int64
+	ModTime time.Time
+	Mode    os.FileMode
+	Sum     string
+}
+
+// Syncer mirrors a local directory to a machine.  It remembers the state
+// of both sides between calls to Sync, so that only the local tree needs to
+// be rescanned on repeated syncs.
+type Syncer struct {
+	client  ssh.Client
+	options Options
+	local   map[string]localFile
+	remote  map[string]string
+}
+
+func NewSyncer(client ssh.Client, options Options) *Syncer {
+	return &Syncer{
+		client:  client,
+		options: options,
+	}
+}
+
+// Sync transfers every file which differs between the source and the
+// destination.  The destination is only listed on the first call.
+func (s *Syncer) Sync() (*Result, error) {
+	if s.remote == nil {
+		remote, err := s.remoteSums()
+		if err != nil {
+			return nil, err
+		}
+		s.remote = remote
+	}
+
+	local, err := s.scan()
+	if err != nil {
+		return nil, err
+	}
+	s.local = local
+
+	upload, remove := diff(local, s.remote, s.options.Delete)
+	result := &Result{}
+
+	if len(upload) > 0 {
+		n, err := s.upload(upload)
+		if err != nil {
+			// we don't know what made it across, so list the
+			// destination again next time
+			s.remote = nil
+			return nil, err
+		}
+		result.Uploaded = upload
+		result.Bytes = n
+
+		for _, name := range upload {
+			s.remote[name] = local[name].Sum
+		}
+	}
+
+	if len(remove) > 0 {
+		if err := s.remove(remove); err != nil {
+			s.remote = nil
+			return nil, err
+		}
+		result.Deleted = remove
+
+		for _, name := range remove {
+			delete(s.remote, name)
+		}
+	}
+
+	return result, nil
+}
+
+// Watch syncs every interval until stop is closed.  Errors are logged and
+// the sync is retried on the next tick.
+func (s *Syncer) Watch(interval time.Duration, stop <-chan struct{}, changed func(*Result)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		result, err := s.Sync()
+		if err != nil {
+			log.Errorf("Error syncing %s: %s", s.options.Source, err)
+			continue
+		}
+
+		if !result.Empty() && changed != nil {
+			changed(result)
+		}
+	}
+}
+
+// ignored reports whether the file with the given slash separated path
+// relative to the source matches one of the ignore patterns.
+func (s *Syncer) ignored(name string) bool {
+	for _, pattern := range s.options.Ignore {
+		pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+
+		subject := name
+		if !strings.Contains(pattern, "/") {
+			subject = path.Base(name)
+		}
+
+		if ok, _ := path.Match(pattern, subject); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ignoredParent reports whether any of the directories leading up to the
+// given path is ignored.
+func (s *Syncer) ignoredParent(name string) bool {
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if s.ignored(dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scan walks the source and returns its regular files keyed by their slash
+// separated relative path.  Files which look unchanged since the last scan
+// are not read again.
+func (s *Syncer) scan() (map[string]localFile, error) {
+	fi, err := os.Stat(s.options.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s: %s", s.options.Source, ErrNotDirectory)
+	}
+
+	files := map[string]localFile{}
+
+	err = filepath.Walk(s.options.Source, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(s.options.Source, p)
+		if err != nil {
+			return err
+		}
+
+		if rel == "." {
+			return nil
+		}
+
+		name := filepath.ToSlash(rel)
+
+		if s.ignored(name) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !fi.Mode().IsRegular() {
+			if !fi.IsDir() {
+				log.Debugf("Skipping %s, not a regular file", p)
+			}
+			return nil
+		}
+
+		file := localFile{
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+			Mode:    fi.Mode(),
+		}
+
+		if prev, ok := s.local[name]; ok && prev.Size == file.Size && prev.ModTime.Equal(file.ModTime) {
+			file.Sum = prev.Sum
+		} else {
+			if file.Sum, err = checksum(p); err != nil {
+				return err
+			}
+		}
+
+		files[name] = file
+
+		return nil
+	})
+
+	return files, err
+}
+
+func checksum(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// remoteSums creates the destination if needed and returns the checksums of
+// the files in it, keyed by their path relative to the destination.
+func (s *Syncer) remoteSums() (map[string]string, error) {
+	dest := quotePath(s.options.Destination)
+	command := fmt.Sprintf("mkdir -p %s && cd %s && find . -type f -print0 | xargs -0 -r sha256sum", dest, dest)
+
+	output, err := s.client.Output(command)
+	if err != nil {
+		return nil, fmt.Errorf("Error listing %s: %s: %s", s.options.Destination, err, output)
+	}
+
+	sums, err := parseSums(output)
+	if err != nil {
+		return nil, err
+	}
+
+	// ignored files are left alone on both sides
+	for name := range sums {
+		if s.ignored(name) || s.ignoredParent(name) {
+			delete(sums, name)
+		}
+	}
+
+	return sums, nil
+}
+
+// parseSums parses the output of sha256sum for files named ./<path>.
+func parseSums(output string) (map[string]string, error) {
+	sums := map[string]string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		fields := strings.SplitN(line, "  ", 2)
+		if len(fields) != 2 || len(fields[0]) != sha256.Size*2 {
+			return nil, fmt.Errorf("Unexpected checksum line %q", line)
+		}
+
+		sums[strings.TrimPrefix(fields[1], "./")] = fields[0]
+	}
+
+	return sums, scanner.Err()
+}
+
+// diff returns the files which need to be uploaded and, if remove is set,
+// the files which need to be deleted, both sorted by name.
+func diff(local map[string]localFile, remote map[string]string, remove bool) ([]string, []string) {
+	upload := []string{}
+	for name, file := range local {
+		if remote[name] != file.Sum {
+			upload = append(upload, name)
+		}
+	}
+
+	deleted := []string{}
+	if remove {
+		for name := range remote {
+			if _, ok := local[name]; !ok {
+				deleted = append(deleted, name)
+			}
+		}
+	}
+
+	sort.Strings(upload)
+	sort.Strings(deleted)
+
+	return upload, deleted
+}
+
+// upload streams a tar archive of the given files to the destination and
+// returns the number of file bytes sent.
+func (s *Syncer) upload(names []string) (int64, error) {
+	pr, pw := io.Pipe()
+
+	written := make(chan int64, 1)
+	go func() {
+		n, err := writeArchive(pw, s.options.Source, names, s.local)
+		pw.CloseWithError(err)
+		written <- n
+	}()
+
+	command := fmt.Sprintf("tar -C %s -xf -", quotePath(s.options.Destination))
+
+	output, err := s.client.OutputWithStdin(command, pr)
+
+	// unblock the archive writer in case the remote end stopped reading
+	pr.Close()
+	n := <-written
+
+	if err != nil {
+		return 0, fmt.Errorf("Error uploading files: %s: %s", err, output)
+	}
+
+	return n, nil
+}
+
+func writeArchive(w io.Writer, source string, names []string, files map[string]localFile) (int64, error) {
+	var written int64
+
+	tw := tar.NewWriter(w)
+
+	for _, name := range names {
+		file := files[name]
+
+		f, err := os.Open(filepath.Join(source, filepath.FromSlash(name)))
+		if err != nil {
+			return written, err
+		}
+
+		hdr := &tar.Header{
+			Name:     name,
+			Mode:     int64(file.Mode.Perm()),
+			Size:     file.Size,
+			ModTime:  file.ModTime,
+			Typeflag: tar.TypeReg,
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			f.Close()
+			return written, err
+		}
+
+		// the file may have grown since it was scanned, so only send
+		// the size we announced in the header
+		n, err := io.CopyN(tw, f, file.Size)
+		written += n
+		f.Close()
+		if err != nil {
+			return written, fmt.Errorf("Error reading %s: %s", name, err)
+		}
+	}
+
+	return written, tw.Close()
+}
+
+// remove deletes the given files from the destination.  The names are
+// passed on stdin so that they are not subject to the remote shell.
+func (s *Syncer) remove(names []string) error {
+	var buf bytes.Buffer
+	for _, name := range names {
+		buf.WriteString("./" + name + "\x00")
+	}
+
+	command := fmt.Sprintf("cd %s && xargs -0 -r rm -f", quotePath(s.options.Destination))
+
+	output, err := s.client.OutputWithStdin(command, &buf)
+	if err != nil {
+		return fmt.Errorf("Error deleting files: %s: %s", err, output)
+	}
+
+	return nil
+}
+
+// quote quotes a string for the remote shell.
+func quote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// quotePath quotes a path for the remote shell like quote, but leaves a
+// leading ~ to the home directory of the SSH user, which quoting would
+// turn into a directory named ~.
+func quotePath(s string) string {
+	switch {
+	case s == "~":
+		return `"$HOME"`
+	case strings.HasPrefix(s, "~/"):
+		return `"$HOME"/` + quote(s[2:])
+	}
+
+	return quote(s)
+}
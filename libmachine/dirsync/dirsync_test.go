@@ -0,0 +1,241 @@
+package dirsync
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// localClient runs commands with the local shell instead of over SSH.
+type localClient struct {
+	commands []string
+}
+
+func (c *localClient) Output(command string) (string, error) {
+	return c.OutputWithStdin(command, nil)
+}
+
+func (c *localClient) OutputWithStdin(command string, stdin io.Reader) (string, error) {
+	c.commands = append(c.commands, command)
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = stdin
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+func (c *localClient) Shell() error {
+	return nil
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, p string) string {
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestIgnored(t *testing.T) {
+	s := NewSyncer(nil, Options{
+		Ignore: []string{".git", "*.log", "build/", "docs/*.tmp"},
+	})
+
+	cases := map[string]bool{
+		".git":            true,
+		"vendor/.git":     true,
+		"app.log":         true,
+		"logs/app.log":    true,
+		"build":           true,
+		"src/build":       true,
+		"docs/a.tmp":      true,
+		"src/docs/a.tmp":  false,
+		"main.go":         false,
+		"src/app.logfile": false,
+	}
+
+	for name, expected := range cases {
+		if ignored := s.ignored(name); ignored != expected {
+			t.Errorf("%s: expected ignored=%v; received %v", name, expected, ignored)
+		}
+	}
+
+	if !s.ignoredParent(".git/objects/ab") {
+		t.Error("expected files in an ignored directory to be ignored")
+	}
+}
+
+func TestParseSums(t *testing.T) {
+	output := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  ./a b.txt\n" +
+		"2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae  ./dir/foo\n"
+
+	sums, err := parseSums(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"a b.txt": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		"dir/foo": "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+	}
+
+	if !reflect.DeepEqual(sums, expected) {
+		t.Fatalf("expected %v; received %v", expected, sums)
+	}
+
+	if _, err := parseSums("sha256sum: not found\n"); err == nil {
+		t.Fatal("expected error for unexpected output")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	local := map[string]localFile{
+		"same":    {Sum: "1"},
+		"changed": {Sum: "2"},
+		"new":     {Sum: "3"},
+	}
+	remote := map[string]string{
+		"same":    "1",
+		"changed": "x",
+		"old":     "4",
+	}
+
+	upload, deleted := diff(local, remote, false)
+	if !reflect.DeepEqual(upload, []string{"changed", "new"}) {
+		t.Fatalf("unexpected uploads %v", upload)
+	}
+	if len(deleted) != 0 {
+		t.Fatalf("expected no deletions without remove; received %v", deleted)
+	}
+
+	_, deleted = diff(local, remote, true)
+	if !reflect.DeepEqual(deleted, []string{"old"}) {
+		t.Fatalf("unexpected deletions %v", deleted)
+	}
+}
+
+func TestQuotePath(t *testing.T) {
+	expected := map[string]string{
+		"/home/docker/app": `'/home/docker/app'`,
+		"it's here":        `'it'\''s here'`,
+		"~":                `"$HOME"`,
+		"~/app dir":        `"$HOME"/'app dir'`,
+		"~docker/app":      `'~docker/app'`,
+	}
+
+	for path, quoted := range expected {
+		if actual := quotePath(path); actual != quoted {
+			t.Fatalf("expected %s for %q; received %s", quoted, path, actual)
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	for _, bin := range []string{"sh", "tar", "sha256sum", "xargs"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("%s is not available", bin)
+		}
+	}
+
+	tmp, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	dest := filepath.Join(tmp, "it's here")
+
+	writeFiles(t, src, map[string]string{
+		"main.go":        "package main",
+		"lib/util.go":    "package lib",
+		"with space.txt": "hello",
+		"debug.log":      "ignored",
+	})
+	writeFiles(t, dest, map[string]string{
+		"stale.txt":  "remove me",
+		"server.log": "keep me",
+	})
+
+	client := &localClient{}
+	s := NewSyncer(client, Options{
+		Source:      src,
+		Destination: dest,
+		Delete:      true,
+		Ignore:      []string{"*.log"},
+	})
+
+	result, err := s.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result.Uploaded, []string{"lib/util.go", "main.go", "with space.txt"}) {
+		t.Fatalf("unexpected uploads %v", result.Uploaded)
+	}
+	if !reflect.DeepEqual(result.Deleted, []string{"stale.txt"}) {
+		t.Fatalf("unexpected deletions %v", result.Deleted)
+	}
+
+	if content := readFile(t, filepath.Join(dest, "lib", "util.go")); content != "package lib" {
+		t.Fatalf("unexpected content %q", content)
+	}
+	if content := readFile(t, filepath.Join(dest, "server.log")); content != "keep me" {
+		t.Fatalf("ignored file was changed: %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "debug.log")); !os.IsNotExist(err) {
+		t.Fatal("ignored file was uploaded")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "stale.txt")); !os.IsNotExist(err) {
+		t.Fatal("stale file was not deleted")
+	}
+
+	// a second sync only sends what changed, without listing the
+	// destination again
+	writeFiles(t, src, map[string]string{
+		"main.go": "package main // changed",
+	})
+	os.Remove(filepath.Join(src, "with space.txt"))
+
+	commands := len(client.commands)
+
+	result, err = s.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result.Uploaded, []string{"main.go"}) {
+		t.Fatalf("unexpected uploads %v", result.Uploaded)
+	}
+	if !reflect.DeepEqual(result.Deleted, []string{"with space.txt"}) {
+		t.Fatalf("unexpected deletions %v", result.Deleted)
+	}
+	if len(client.commands) != commands+2 {
+		t.Fatalf("expected an upload and a delete; received %v", client.commands[commands:])
+	}
+
+	result, err = s.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Empty() {
+		t.Fatalf("expected nothing to sync; received %+v", result)
+	}
+}
@@ -1,13 +1,30 @@
 package engine
 
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	validSysctlPattern       = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.\-/]*=[^'"\\\n]+$`)
+	validUlimitPattern       = regexp.MustCompile(`^(core|cpu|data|fsize|locks|memlock|msgqueue|nice|nofile|nproc|rss|rtprio|rttime|sigpending|stack)=-?[0-9]+(:-?[0-9]+)?$`)
+	validKernelModulePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+)
+
 type EngineOptions struct {
 	ArbitraryFlags   []string
 	Dns              []string
+	Env              []string
+	ExecDriver       string
+	ExecOpt          []string
 	GraphDir         string
 	Ipv6             bool
 	InsecureRegistry []string
 	Labels           []string
+	LogDriver        string
 	LogLevel         string
+	LogOpt           []string
 	StorageDriver    string
 	SelinuxEnabled   bool
 	TlsCaCert        string
@@ -16,4 +33,64 @@ type EngineOptions struct {
 	TlsVerify        bool
 	RegistryMirror   []string
 	InstallURL       string
+	Version          string
+	Offline          bool
+	CloudInit        bool
+
+	// RegistryCA are the CA certificates of registries in the form
+	// registry=path, and RegistryConfig is the path of a docker config.json
+	// with their credentials.  The files are read each time the machine is
+	// provisioned.
+	RegistryCA     []string
+	RegistryConfig string
+
+	// Sysctl are kernel parameters in the form name=value and KernelModules
+	// kernel modules, set on the machine in a way which lasts across
+	// reboots.  Ulimits are the default ulimits of the containers in the
+	// form name=soft[:hard].
+	Sysctl        []string
+	Ulimits       []string
+	KernelModules []string
+}
+
+// ValidateEnv checks that the engine environment is made of KEY=VALUE
+// pairs.  Values are written to the daemon config in single quotes, so
+// they may not contain any.
+func ValidateEnv(env []string) error {
+	for _, e := range env {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid engine environment variable %q, expected KEY=VALUE", e)
+		}
+
+		if strings.ContainsAny(e, "'\"") {
+			return fmt.Errorf("invalid engine environment variable %q, quotes are not supported", e)
+		}
+	}
+
+	return nil
+}
+
+// ValidateKernelOptions checks the sysctls, ulimits and kernel modules,
+// which are written to the config files and boot scripts of the machine.
+func ValidateKernelOptions(sysctl, ulimits, modules []string) error {
+	for _, s := range sysctl {
+		if !validSysctlPattern.MatchString(s) {
+			return fmt.Errorf("invalid sysctl %q, expected name=value", s)
+		}
+	}
+
+	for _, u := range ulimits {
+		if !validUlimitPattern.MatchString(u) {
+			return fmt.Errorf("invalid ulimit %q, expected name=soft[:hard], e.g. nofile=65536:65536", u)
+		}
+	}
+
+	for _, m := range modules {
+		if !validKernelModulePattern.MatchString(m) {
+			return fmt.Errorf("invalid kernel module %q", m)
+		}
+	}
+
+	return nil
 }
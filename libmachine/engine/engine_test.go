@@ -1 +1,41 @@
 package engine
+
+import "testing"
+
+func TestValidateEnv(t *testing.T) {
+	valid := []string{"HTTP_PROXY=http://proxy.example.com:3128", "NO_PROXY=", "DEBUG=a=b"}
+	if err := ValidateEnv(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range []string{"HTTP_PROXY", "=value", "GREETING='hello'", `GREETING="hello"`} {
+		if err := ValidateEnv([]string{e}); err == nil {
+			t.Fatalf("expected error for %q", e)
+		}
+	}
+}
+
+func TestValidateKernelOptions(t *testing.T) {
+	sysctl := []string{"vm.max_map_count=262144", "net.ipv4.ip_local_port_range=1024 65000"}
+	ulimits := []string{"nofile=65536:65536", "memlock=-1"}
+	modules := []string{"ip_vs", "br_netfilter"}
+
+	if err := ValidateKernelOptions(sysctl, ulimits, modules); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := [][3][]string{
+		{{"vm.max_map_count"}, nil, nil},
+		{{"vm.max_map_count='262144'"}, nil, nil},
+		{{"=1"}, nil, nil},
+		{nil, {"files=1024"}, nil},
+		{nil, {"nofile=many"}, nil},
+		{nil, nil, {"ip_vs; reboot"}},
+	}
+
+	for _, options := range invalid {
+		if err := ValidateKernelOptions(options[0], options[1], options[2]); err == nil {
+			t.Fatalf("expected an error for %v", options)
+		}
+	}
+}
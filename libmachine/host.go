@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -13,7 +14,6 @@ import (
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/provision"
-	"github.com/docker/machine/libmachine/provision/pkgaction"
 	"github.com/docker/machine/libmachine/swarm"
 	"github.com/docker/machine/log"
 	"github.com/docker/machine/ssh"
@@ -24,6 +24,8 @@ import (
 var (
 	validHostNameChars                = `[a-zA-Z0-9\-\.]`
 	validHostNamePattern              = regexp.MustCompile(`^` + validHostNameChars + `+$`)
+	validDNSLabel                     = `[a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?`
+	validDNSNamePattern               = regexp.MustCompile(`^` + validDNSLabel + `(\.` + validDNSLabel + `)*$`)
 	errMachineMustBeRunningForUpgrade = errors.New("Error: machine must be running to upgrade.")
 )
 
@@ -34,6 +36,21 @@ type Host struct {
 	StorePath   string
 	HostOptions *HostOptions
 
+	// EngineVersion is the version of docker found on the machine after it
+	// was last provisioned or upgraded.
+	EngineVersion string
+
+	// provisionLog is the log of the provisioning in progress, if any.
+	provisionLog *log.HostLog
+
+	// stageSummary is how long each stage of the last provisioning took.
+	stageSummary string
+
+	// previousPin is the engine version the host was pinned to before
+	// PinEngineVersion, which a failed upgrade puts back, or nil if the pin
+	// did not change.
+	previousPin *string
+
 	// deprecated options; these are left to assist in config migrations
 	SwarmHost      string
 	SwarmMaster    bool
@@ -47,9 +64,30 @@ type Host struct {
 }
 
 type HostOptions struct {
-	Driver        string
-	Memory        int
-	Disk          int
+	Driver      string
+	Memory      int
+	Disk        int
+	Provisioner string
+
+	// Hostname and Domain name the machine instead of its name, e.g. to give
+	// it a fully qualified hostname.  The hostname defaults to the name.
+	Hostname string
+	Domain   string
+
+	// PostProvisionFiles are uploaded and PostProvisionScripts run, in
+	// order, each time the machine is provisioned.
+	PostProvisionFiles   []string
+	PostProvisionScripts []string
+
+	// AuthorizedKeys are public keys which may log in as the SSH user, and
+	// as ExtraUser if set, a user created in the docker group.
+	AuthorizedKeys []string
+	ExtraUser      string
+
+	// BootstrapContainers are started once the machine is provisioned, and
+	// replaced when their spec changes.
+	BootstrapContainers []provision.BootstrapContainer
+
 	EngineOptions *engine.EngineOptions
 	SwarmOptions  *swarm.SwarmOptions
 	AuthOptions   *auth.AuthOptions
@@ -107,9 +145,68 @@ func ValidateHostName(name string) bool {
 	return validHostNamePattern.MatchString(name)
 }
 
+// ValidateDNSName checks a hostname or a domain, made of DNS labels.
+func ValidateDNSName(name string) bool {
+	return len(name) <= 253 && validDNSNamePattern.MatchString(name)
+}
+
+// hostname returns the hostname of the machine, qualified with its domain,
+// or an empty string if it is named after the machine.
+func (h *Host) hostname() string {
+	if h.HostOptions == nil || h.HostOptions.Hostname == "" && h.HostOptions.Domain == "" {
+		return ""
+	}
+
+	hostname := h.HostOptions.Hostname
+	if hostname == "" {
+		hostname = h.Name
+	}
+
+	if h.HostOptions.Domain != "" {
+		hostname = fmt.Sprintf("%s.%s", hostname, h.HostOptions.Domain)
+	}
+
+	return hostname
+}
+
+// setHostname takes the hostname and domain the provider gave the host, if
+// none were set, and adds the hostname to the names of the server
+// certificate.
+func (h *Host) setHostname() {
+	if d, ok := h.Driver.(drivers.HostnameDriver); ok && h.HostOptions.Hostname == "" && h.HostOptions.Domain == "" {
+		if hostname := d.GetHostname(); hostname != h.Name {
+			h.HostOptions.Hostname = hostname
+		}
+		h.HostOptions.Domain = d.GetDomain()
+	}
+
+	hostname := h.hostname()
+	if hostname == "" || h.HostOptions.AuthOptions == nil {
+		return
+	}
+
+	for _, san := range h.HostOptions.AuthOptions.ServerCertSANs {
+		if san == hostname {
+			return
+		}
+	}
+
+	h.HostOptions.AuthOptions.ServerCertSANs = append(h.HostOptions.AuthOptions.ServerCertSANs, hostname)
+}
+
 func (h *Host) Create(name string) error {
+	defer h.startProvisionLog("create")()
+
+	h.setHostname()
+
+	if h.HostOptions.EngineOptions != nil && h.HostOptions.EngineOptions.CloudInit {
+		if err := h.setUserData(); err != nil {
+			return err
+		}
+	}
+
 	// create the instance
-	if err := h.Driver.Create(); err != nil {
+	if err := h.runStage("create machine", h.Driver.Create); err != nil {
 		return err
 	}
 
@@ -120,20 +217,34 @@ func (h *Host) Create(name string) error {
 
 	// TODO: Not really a fan of just checking "none" here.
 	if h.Driver.DriverName() != "none" {
-		if err := utils.WaitFor(drivers.MachineInState(h.Driver, state.Running)); err != nil {
+		if err := h.runStage("wait for machine", func() error {
+			return utils.WaitFor(drivers.MachineInState(h.Driver, state.Running))
+		}); err != nil {
 			return err
 		}
 
-		if err := WaitForSSH(h); err != nil {
+		if err := h.runStage("wait for SSH", func() error {
+			return WaitForSSH(h)
+		}); err != nil {
 			return err
 		}
 
-		provisioner, err := provision.DetectProvisioner(h.Driver)
-		if err != nil {
+		var provisioner provision.Provisioner
+		if err := h.runStage("detect provisioner", func() error {
+			var err error
+			provisioner, err = h.detectProvisioner()
+			return err
+		}); err != nil {
 			return err
 		}
 
-		if err := provisioner.Provision(*h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions); err != nil {
+		if err := h.provision(provisioner, *h.HostOptions.SwarmOptions); err != nil {
+			return err
+		}
+
+		h.recordEngineVersion(provisioner)
+
+		if err := h.SaveConfig(); err != nil {
 			return err
 		}
 	}
@@ -141,6 +252,212 @@ func (h *Host) Create(name string) error {
 	return nil
 }
 
+// provision runs the provisioner and then the steps configured for the
+// host, each as a stage of the provisioning log.
+func (h *Host) provision(provisioner provision.Provisioner, swarmOptions swarm.SwarmOptions) error {
+	if err := h.runStage("provision", func() error {
+		return provisioner.Provision(swarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions)
+	}); err != nil {
+		return err
+	}
+
+	if err := h.runStage("authorized keys", func() error {
+		return h.setAuthorizedKeys(provisioner)
+	}); err != nil {
+		return err
+	}
+
+	if err := h.runStage("bootstrap containers", func() error {
+		return h.bootstrapContainers(provisioner)
+	}); err != nil {
+		return err
+	}
+
+	return h.runStage("post-provision", func() error {
+		return h.postProvision(provisioner)
+	})
+}
+
+// setUserData passes the cloud-config document which sets up the host on
+// its first boot to the driver.
+func (h *Host) setUserData() error {
+	d, ok := h.Driver.(drivers.UserDataDriver)
+	if !ok {
+		return fmt.Errorf("The %s driver does not support user-data, which cloud-init provisioning needs", h.DriverName)
+	}
+
+	hostname := h.hostname()
+	if hostname == "" {
+		hostname = h.Name
+	}
+
+	userData, err := provision.GenerateCloudConfig(hostname, *h.HostOptions.SwarmOptions, *h.HostOptions.EngineOptions)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Using the following cloud-config:\n%s", userData)
+
+	d.SetUserData(userData)
+
+	return nil
+}
+
+// recordEngineVersion keeps the version of docker running on the machine.
+// Failing to get it is not fatal, the machine is usable regardless.
+func (h *Host) recordEngineVersion(provisioner provision.Provisioner) {
+	version, err := provision.GetDockerVersion(provisioner)
+	if err != nil {
+		log.Warnf("Unable to get the engine version of %s: %s", h.Name, err)
+		return
+	}
+
+	h.EngineVersion = version
+}
+
+// setAuthorizedKeys sets the extra authorized keys and user of the host, if
+// any.
+func (h *Host) setAuthorizedKeys(provisioner provision.Provisioner) error {
+	if h.HostOptions == nil || len(h.HostOptions.AuthorizedKeys) == 0 && h.HostOptions.ExtraUser == "" {
+		return nil
+	}
+
+	return provisioner.SetAuthorizedKeys(h.HostOptions.AuthorizedKeys, h.HostOptions.ExtraUser)
+}
+
+// SetAuthorizedKeys replaces the extra authorized keys and user of the host
+// and saves them.  If the user changes, the keys of the previous one are
+// removed, but the user is left on the machine.
+func (h *Host) SetAuthorizedKeys(keys []string, user string) error {
+	if err := provision.ValidateAuthorizedKeys(keys, user); err != nil {
+		return err
+	}
+
+	if h.HostOptions == nil {
+		h.HostOptions = &HostOptions{}
+	}
+
+	provisioner, err := h.detectProvisioner()
+	if err != nil {
+		return err
+	}
+
+	if previous := h.HostOptions.ExtraUser; previous != "" && previous != user {
+		if err := provisioner.SetAuthorizedKeys(nil, previous); err != nil {
+			return err
+		}
+	}
+
+	if err := provisioner.SetAuthorizedKeys(keys, user); err != nil {
+		return err
+	}
+
+	h.HostOptions.AuthorizedKeys = keys
+	h.HostOptions.ExtraUser = user
+
+	return h.SaveConfig()
+}
+
+// bootstrapContainers starts the bootstrap containers of the host, if any.
+func (h *Host) bootstrapContainers(provisioner provision.Provisioner) error {
+	if h.HostOptions == nil || len(h.HostOptions.BootstrapContainers) == 0 {
+		return nil
+	}
+
+	return provision.BootstrapContainers(provisioner, h.HostOptions.BootstrapContainers, *h.HostOptions.EngineOptions)
+}
+
+// LoadImages loads an archive of images, as written by docker save, into
+// the engine of the host.
+func (h *Host) LoadImages(name string, archive io.Reader) error {
+	provisioner, err := h.detectProvisioner()
+	if err != nil {
+		return err
+	}
+
+	return provision.LoadImages(provisioner, name, archive)
+}
+
+// DaemonLog returns the last lines logged by the docker daemon of the host,
+// as kept by its init system.
+func (h *Host) DaemonLog(lines int) (string, error) {
+	provisioner, err := h.detectProvisioner()
+	if err != nil {
+		return "", err
+	}
+
+	return provisioner.ServiceLog("docker", lines)
+}
+
+// postProvision runs the post-provision files and scripts of the host, with
+// the output of the scripts written to its provisioning log.
+func (h *Host) postProvision(provisioner provision.Provisioner) error {
+	if h.HostOptions == nil || len(h.HostOptions.PostProvisionFiles)+len(h.HostOptions.PostProvisionScripts) == 0 {
+		return nil
+	}
+
+	var output io.Writer = ioutil.Discard
+	if h.provisionLog != nil {
+		output = h.provisionLog
+	}
+
+	return provision.PostProvision(provisioner, h.HostOptions.PostProvisionFiles, h.HostOptions.PostProvisionScripts, output)
+}
+
+// ProvisionLogPath returns the path of the log of what was done to the
+// machine when it was created, provisioned again or upgraded.
+func (h *Host) ProvisionLogPath() string {
+	return filepath.Join(h.StorePath, "provision.log")
+}
+
+// startProvisionLog appends the stages and the SSH commands run by the
+// provisioners of the host to its provisioning log, under a header naming
+// the action, until the function it returns is called.  Each host has its
+// own log, so machines provisioned concurrently do not get each other's
+// commands.  Failing to open the log is not fatal, the stages are timed
+// regardless.
+func (h *Host) startProvisionLog(action string) func() {
+	var output io.Writer = ioutil.Discard
+
+	logFile, err := os.OpenFile(h.ProvisionLogPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		log.Debugf("Unable to open the provisioning log of %s: %s", h.Name, err)
+	} else {
+		output = logFile
+	}
+
+	h.provisionLog = log.NewHostLog(output)
+	h.provisionLog.Printf("--- %s %s", action, h.Name)
+
+	return func() {
+		h.stageSummary = h.provisionLog.Summary()
+		h.provisionLog = nil
+
+		if logFile != nil {
+			logFile.Close()
+		}
+	}
+}
+
+// runStage runs a step of the provisioning as a stage of the provisioning
+// log, which records how long it took and the error it failed with.
+func (h *Host) runStage(name string, step func() error) error {
+	end := h.provisionLog.Stage(name)
+	err := step()
+	if err != nil {
+		h.provisionLog.Printf("%s failed: %s", name, err)
+	}
+	end()
+
+	return err
+}
+
+// StageSummary returns how long each stage of the last provisioning took,
+// or an empty string if the host was not provisioned.
+func (h *Host) StageSummary() string {
+	return h.stageSummary
+}
+
 func (h *Host) RunSSHCommand(command string) (string, error) {
 	return drivers.RunSSHCommandFromDriver(h.Driver, command)
 }
@@ -163,6 +480,24 @@ func (h *Host) CreateSSHClient() (ssh.Client, error) {
 	return ssh.NewClient(h.Driver.GetSSHUsername(), addr, port, auth)
 }
 
+func (h *Host) CreateSSHTunnel(forwards []ssh.Forward) (*ssh.Tunnel, error) {
+	addr, err := h.Driver.GetSSHHostname()
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := h.Driver.GetSSHPort()
+	if err != nil {
+		return nil, err
+	}
+
+	auth := &ssh.Auth{
+		Keys: []string{h.Driver.GetSSHKeyPath()},
+	}
+
+	return ssh.NewTunnel(h.Driver.GetSSHUsername(), addr, port, auth, forwards)
+}
+
 func (h *Host) CreateSSHShell() error {
 	client, err := h.CreateSSHClient()
 	if err != nil {
@@ -227,29 +562,28 @@ func (h *Host) Restart() error {
 	return nil
 }
 
-func (h *Host) Upgrade() error {
-	machineState, err := h.Driver.GetState()
-	if err != nil {
-		return err
-	}
+// detectProvisioner returns the provisioner chosen when the host was
+// created, or detects one from the OS of the host.
+func (h *Host) detectProvisioner() (provision.Provisioner, error) {
+	var (
+		provisioner provision.Provisioner
+		err         error
+	)
 
-	if machineState != state.Running {
-		log.Fatal(errMachineMustBeRunningForUpgrade)
+	if h.HostOptions != nil && h.HostOptions.Provisioner != "" {
+		provisioner, err = provision.NewProvisioner(h.HostOptions.Provisioner, h.Driver)
+	} else {
+		provisioner, err = provision.DetectProvisioner(h.Driver)
 	}
 
-	provisioner, err := provision.DetectProvisioner(h.Driver)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if err := provisioner.Package("docker", pkgaction.Upgrade); err != nil {
-		return err
-	}
+	provisioner.SetMachineHostname(h.hostname())
+	provisioner.SetHostLog(h.provisionLog)
 
-	if err := provisioner.Service("docker", pkgaction.Restart); err != nil {
-		return err
-	}
-	return nil
+	return provisioner, nil
 }
 
 func (h *Host) Remove(force bool) error {
@@ -317,7 +651,9 @@ func (h *Host) ConfigureAuth() error {
 		return err
 	}
 
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	defer h.startProvisionLog("configure auth")()
+
+	provisioner, err := h.detectProvisioner()
 	if err != nil {
 		return err
 	}
@@ -327,11 +663,7 @@ func (h *Host) ConfigureAuth() error {
 	// and modularity of the provisioners should be).
 	//
 	// Call provision to re-provision the certs properly.
-	if err := provisioner.Provision(swarm.SwarmOptions{}, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions); err != nil {
-		return err
-	}
-
-	return nil
+	return h.provision(provisioner, swarm.SwarmOptions{})
 }
 
 func (h *Host) SaveConfig() error {
@@ -156,6 +156,92 @@ func TestValidateHostnameInvalid(t *testing.T) {
 	}
 }
 
+func TestValidateDNSName(t *testing.T) {
+	for _, name := range []string{"web01", "web-01.prod.example.com", "a"} {
+		if !ValidateDNSName(name) {
+			t.Fatalf("Thought a valid DNS name was invalid: %s", name)
+		}
+	}
+
+	for _, name := range []string{"", "web_01", "-web", "web-", "web..example.com", ".example.com", "example.com.", strings.Repeat("a", 64)} {
+		if ValidateDNSName(name) {
+			t.Fatalf("Thought an invalid DNS name was valid: %s", name)
+		}
+	}
+}
+
+// hostnameDriver names the host like the SoftLayer driver does.
+type hostnameDriver struct {
+	fakedriver.FakeDriver
+	hostname, domain string
+}
+
+func (d *hostnameDriver) GetHostname() string {
+	return d.hostname
+}
+
+func (d *hostnameDriver) GetDomain() string {
+	return d.domain
+}
+
+func TestHostHostname(t *testing.T) {
+	host := &Host{
+		Name: "friendly",
+		HostOptions: &HostOptions{
+			AuthOptions: &auth.AuthOptions{},
+		},
+	}
+
+	if hostname := host.hostname(); hostname != "" {
+		t.Fatalf("expected the host to be named after the machine; received %q", hostname)
+	}
+
+	host.HostOptions.Domain = "prod.example.com"
+	if hostname := host.hostname(); hostname != "friendly.prod.example.com" {
+		t.Fatalf("expected the name to be qualified with the domain; received %q", hostname)
+	}
+
+	host.HostOptions.Hostname = "web01"
+	if hostname := host.hostname(); hostname != "web01.prod.example.com" {
+		t.Fatalf("expected the hostname to be qualified with the domain; received %q", hostname)
+	}
+
+	host.setHostname()
+	host.setHostname()
+
+	if sans := host.HostOptions.AuthOptions.ServerCertSANs; len(sans) != 1 || sans[0] != "web01.prod.example.com" {
+		t.Fatalf("expected the hostname to be added once to the certificate names; received %v", sans)
+	}
+}
+
+func TestHostHostnameFromDriver(t *testing.T) {
+	host := &Host{
+		Name:   "friendly",
+		Driver: &hostnameDriver{hostname: "web01", domain: "prod.example.com"},
+		HostOptions: &HostOptions{
+			AuthOptions: &auth.AuthOptions{},
+		},
+	}
+
+	host.setHostname()
+
+	if hostname := host.hostname(); hostname != "web01.prod.example.com" {
+		t.Fatalf("expected the hostname of the driver; received %q", hostname)
+	}
+
+	// the hostname and domain set on the command line take precedence
+	host.HostOptions = &HostOptions{
+		Domain:      "example.org",
+		AuthOptions: &auth.AuthOptions{},
+	}
+
+	host.setHostname()
+
+	if hostname := host.hostname(); hostname != "friendly.example.org" {
+		t.Fatalf("expected the domain of the host options; received %q", hostname)
+	}
+}
+
 func TestHostOptions(t *testing.T) {
 	store, err := getTestStore()
 	if err != nil {
@@ -185,6 +271,31 @@ func TestHostOptions(t *testing.T) {
 	}
 }
 
+func TestCreateCloudInitNeedsUserData(t *testing.T) {
+	defer cleanup()
+	host, _ := getDefaultTestHost()
+	host.HostOptions.EngineOptions.CloudInit = true
+
+	// the none driver cannot pass user-data, so nothing is created
+	if err := host.Create(hostTestName); err == nil || !strings.Contains(err.Error(), "user-data") {
+		t.Fatalf("expected an error about user-data; received %v", err)
+	}
+}
+
+func TestSetAuthorizedKeysInvalidUser(t *testing.T) {
+	defer cleanup()
+	host, _ := getDefaultTestHost()
+
+	// the user is checked before connecting to the machine
+	if err := host.SetAuthorizedKeys(nil, "root"); err == nil {
+		t.Fatal("expected an error for the root user")
+	}
+
+	if host.HostOptions.ExtraUser != "" {
+		t.Fatalf("expected the extra user not to be saved; received %q", host.HostOptions.ExtraUser)
+	}
+}
+
 func TestPrintIPEmptyGivenLocalEngine(t *testing.T) {
 	defer cleanup()
 	host, _ := getDefaultTestHost()
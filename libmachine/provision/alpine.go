@@ -0,0 +1,226 @@
+package provision
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/log"
+	"github.com/docker/machine/utils"
+)
+
+func init() {
+	Register("Alpine", &RegisteredProvisioner{
+		New: NewAlpineProvisioner,
+	})
+}
+
+func NewAlpineProvisioner(d drivers.Driver) Provisioner {
+	return &AlpineProvisioner{
+		GenericProvisioner{
+			DockerOptionsDir:  "/etc/docker",
+			DaemonOptionsFile: "/etc/conf.d/docker",
+			OsReleaseId:       "alpine",
+			Packages:          []string{},
+			Driver:            d,
+		},
+	}
+}
+
+type AlpineProvisioner struct {
+	GenericProvisioner
+}
+
+func (provisioner *AlpineProvisioner) SetAuthorizedKeys(keys []string, user string) error {
+	return setAuthorizedKeys(provisioner, keys, user)
+}
+
+func (provisioner *AlpineProvisioner) Service(name string, action pkgaction.ServiceAction) error {
+	var command string
+
+	// OpenRC has no notion of enabling a service, it is added to or
+	// removed from the default runlevel instead
+	switch action {
+	case pkgaction.Enable:
+		command = fmt.Sprintf("sudo rc-update add %s default", name)
+	case pkgaction.Disable:
+		command = fmt.Sprintf("sudo rc-update del %s default", name)
+	case pkgaction.DaemonReload:
+		return nil
+	default:
+		command = fmt.Sprintf("sudo rc-service %s %s", name, action.String())
+	}
+
+	if _, err := provisioner.SSHCommand(command); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ServiceLog returns the end of the log OpenRC writes the output of the
+// service to.
+func (provisioner *AlpineProvisioner) ServiceLog(name string, lines int) (string, error) {
+	return provisioner.SSHCommand(fmt.Sprintf("sudo tail -n %d /var/log/%s.log", lines, name))
+}
+
+func (provisioner *AlpineProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	var packageAction string
+
+	updateMetadata := true
+
+	switch action {
+	case pkgaction.Install:
+		packageAction = "add"
+	case pkgaction.Remove:
+		packageAction = "del"
+		updateMetadata = false
+	case pkgaction.Upgrade:
+		packageAction = "add --upgrade"
+	}
+
+	if updateMetadata {
+		if _, err := provisioner.SSHCommand("sudo -E apk update"); err != nil {
+			return err
+		}
+	}
+
+	command := fmt.Sprintf("sudo -E apk %s %s", packageAction, name)
+
+	log.Debugf("package: action=%s name=%s", action.String(), name)
+
+	if _, err := provisioner.SSHCommand(command); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// alpineDockerPackage returns the package to install for the given version
+// of docker.  Package versions carry a release suffix, e.g. 1.8.2-r0, hence
+// the fuzzy match.
+func alpineDockerPackage(version string) string {
+	if version == "" {
+		return "docker"
+	}
+
+	return fmt.Sprintf("docker~%s", version)
+}
+
+func (provisioner *AlpineProvisioner) UpgradeDocker(version string) error {
+	if version == "" {
+		return provisioner.Package("docker", pkgaction.Upgrade)
+	}
+
+	return provisioner.Package(alpineDockerPackage(version), pkgaction.Install)
+}
+
+// RollbackDocker installs the previous version, which the package manager
+// downgrades to.
+func (provisioner *AlpineProvisioner) RollbackDocker(version string) error {
+	return provisioner.UpgradeDocker(version)
+}
+
+func (provisioner *AlpineProvisioner) dockerDaemonResponding() bool {
+	if _, err := provisioner.SSHCommand("sudo docker version"); err != nil {
+		log.Warnf("Error getting SSH command to check if the daemon is up: %s", err)
+		return false
+	}
+
+	// The daemon is up if the command worked.  Carry on.
+	return true
+}
+
+func (provisioner *AlpineProvisioner) Provision(swarmOptions swarm.SwarmOptions, authOptions auth.AuthOptions, engineOptions engine.EngineOptions) error {
+	provisioner.SwarmOptions = swarmOptions
+	provisioner.AuthOptions = authOptions
+	provisioner.EngineOptions = engineOptions
+
+	if engineOptions.CloudInit {
+		return ErrCloudInitUnsupported
+	}
+
+	if engineOptions.Offline {
+		return ErrOfflineUnsupported
+	}
+
+	// the stock kernel has no aufs
+	if provisioner.EngineOptions.StorageDriver == "" {
+		provisioner.EngineOptions.StorageDriver = "overlay"
+	}
+
+	// HACK: like debian, alpine does not come with sudo by default
+	log.Debug("installing sudo")
+	if _, err := provisioner.SSHCommand("if ! type sudo; then apk update && apk add sudo; fi"); err != nil {
+		return err
+	}
+
+	log.Debug("setting hostname")
+	if err := provisioner.SetHostname(provisioner.machineHostname()); err != nil {
+		return err
+	}
+
+	log.Debug("installing base packages")
+	for _, pkg := range provisioner.Packages {
+		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
+			return err
+		}
+	}
+
+	// docker is packaged in the community repository, which is not
+	// enabled by default on recent releases; the install script used by
+	// the other provisioners does not support alpine
+	log.Debug("installing docker")
+	if _, err := provisioner.SSHCommand(`sudo sed -i -e 's|^#\(.*/community\)$|\1|' /etc/apk/repositories`); err != nil {
+		return err
+	}
+
+	if err := provisioner.Package(alpineDockerPackage(provisioner.EngineOptions.Version), pkgaction.Install); err != nil {
+		return err
+	}
+
+	log.Debug("enabling docker in openrc")
+	if err := provisioner.Service("docker", pkgaction.Enable); err != nil {
+		return err
+	}
+
+	if err := provisioner.Service("docker", pkgaction.Start); err != nil {
+		return err
+	}
+
+	log.Debug("waiting for docker daemon")
+	if err := utils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
+		return err
+	}
+
+	if err := makeDockerOptionsDir(provisioner); err != nil {
+		return err
+	}
+
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+
+	log.Debug("configuring auth")
+	if err := ConfigureAuth(provisioner); err != nil {
+		return err
+	}
+
+	log.Debug("configuring kernel")
+	if err := configureKernel(provisioner, provisioner.EngineOptions); err != nil {
+		return err
+	}
+
+	log.Debug("configuring registries")
+	if err := configureRegistries(provisioner, provisioner.EngineOptions); err != nil {
+		return err
+	}
+
+	log.Debug("configuring swarm")
+	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions, provisioner.EngineOptions); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+func TestAlpineProvision(t *testing.T) {
+	server, d := newTestServer(t, "NAME=\"Alpine Linux\"\nID=alpine\nVERSION_ID=3.2.3\n")
+	defer server.Close()
+
+	authOptions, tmpDir := newTestAuthOptions(t, "test")
+	defer removeTestStore(tmpDir)
+
+	p, err := DetectProvisioner(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := p.(*AlpineProvisioner); !ok {
+		t.Fatalf("expected the alpine provisioner; received %T", p)
+	}
+
+	swarmOptions := swarm.SwarmOptions{
+		IsSwarm:   true,
+		Host:      "tcp://0.0.0.0:3376",
+		Image:     "swarm:latest",
+		Discovery: "token://abc",
+	}
+
+	if err := p.Provision(swarmOptions, authOptions, engine.EngineOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`^if ! type sudo; then apk update && apk add sudo; fi$`,
+		`/community.* /etc/apk/repositories$`,
+		`^sudo -E apk add docker$`,
+		`^sudo rc-update add docker default$`,
+		`^sudo rc-service docker stop$`,
+		`install -m 0644 -o root -g root \S+ '/etc/docker/ca.pem'$`,
+		`install -m 0644 -o root -g root \S+ '/etc/conf.d/docker'$`,
+		`^sudo rc-service docker start$`,
+		`join --addr 127.0.0.1:2376 token://abc`,
+	}
+
+	assertCommandSequence(t, server, expected)
+
+	if found := server.Find(`get.docker.com|apt-get|systemctl`); len(found) != 0 {
+		t.Fatalf("unexpected commands for alpine: %+v", found)
+	}
+}
+
+func TestAlpineDockerPackage(t *testing.T) {
+	if pkg := alpineDockerPackage(""); pkg != "docker" {
+		t.Fatalf("expected the docker package; received %s", pkg)
+	}
+
+	if pkg := alpineDockerPackage("1.8.2"); pkg != "docker~1.8.2" {
+		t.Fatalf("expected a fuzzy version match; received %s", pkg)
+	}
+}
@@ -0,0 +1,138 @@
+package provision
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/docker/machine/log"
+)
+
+var (
+	ErrExtraUserUnsupported = errors.New("extra users are not supported by this provisioner")
+
+	validUserPattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+)
+
+// authorizedKeysScript sets the extra keys in the authorized_keys file of
+// the SSH user, and of the extra user if any, which is created in the docker
+// group first.  The keys are kept between markers so that the other keys,
+// such as the one of the machine, are left alone, and running it again
+// replaces the keys.  It is run as root with sh.
+var authorizedKeysScript = `set -e
+
+authorize() {
+	ssh_dir="$(eval echo ~$1)/.ssh"
+	mkdir -p "$ssh_dir"
+	touch "$ssh_dir/authorized_keys"
+	awk '/^# BEGIN docker-machine authorized keys$/ { skip = 1 } !skip { print } /^# END docker-machine authorized keys$/ { skip = 0 }' "$ssh_dir/authorized_keys" > "$ssh_dir/authorized_keys.machine"
+{{ if .Keys }}	cat >> "$ssh_dir/authorized_keys.machine" <<'EOF'
+# BEGIN docker-machine authorized keys
+{{ range .Keys }}{{ . }}
+{{ end }}# END docker-machine authorized keys
+EOF
+{{ end }}	mv "$ssh_dir/authorized_keys.machine" "$ssh_dir/authorized_keys"
+	chmod 700 "$ssh_dir"
+	chmod 600 "$ssh_dir/authorized_keys"
+	chown -R "$1" "$ssh_dir"
+}
+
+authorize {{ .SSHUser }}
+{{ if .User }}
+if ! id {{ .User }} > /dev/null 2>&1; then
+	if type useradd > /dev/null 2>&1; then
+		useradd -m -s /bin/sh {{ .User }}
+	else
+		adduser -D -s /bin/sh {{ .User }}
+	fi
+fi
+
+if type usermod > /dev/null 2>&1; then
+	usermod -a -G docker {{ .User }}
+else
+	addgroup {{ .User }} docker
+fi
+
+authorize {{ .User }}
+{{ end }}`
+
+type AuthorizedKeysContext struct {
+	SSHUser string
+	User    string
+	Keys    []string
+}
+
+// ValidateAuthorizedKeys checks that the keys are normalized public keys,
+// see ssh.NormalizeAuthorizedKey, and that the extra user, if any, is a
+// valid user name.
+func ValidateAuthorizedKeys(keys []string, user string) error {
+	for _, key := range keys {
+		if strings.ContainsAny(key, "\r\n") || len(strings.Fields(key)) < 2 {
+			return fmt.Errorf("invalid authorized key %q", key)
+		}
+	}
+
+	if user != "" && !validUserPattern.MatchString(user) {
+		return fmt.Errorf("invalid user name %q, expected lower case letters, digits, - and _", user)
+	}
+
+	if user == "root" {
+		return errors.New("the extra user cannot be root")
+	}
+
+	return nil
+}
+
+// generateAuthorizedKeysScript renders authorizedKeysScript for the SSH
+// user of the machine.
+func generateAuthorizedKeysScript(p Provisioner, keys []string, user string) (string, error) {
+	if err := ValidateAuthorizedKeys(keys, user); err != nil {
+		return "", err
+	}
+
+	sshUser := p.GetDriver().GetSSHUsername()
+	if !validUserPattern.MatchString(sshUser) {
+		return "", fmt.Errorf("unexpected SSH user name %q", sshUser)
+	}
+
+	t, err := template.New("authorizedKeys").Parse(authorizedKeysScript)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, AuthorizedKeysContext{
+		SSHUser: sshUser,
+		User:    user,
+		Keys:    keys,
+	}); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// setAuthorizedKeys runs authorizedKeysScript on the machine.
+func setAuthorizedKeys(p Provisioner, keys []string, user string) error {
+	script, err := generateAuthorizedKeysScript(p, keys, user)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Setting %d authorized keys", len(keys))
+
+	tmpPath, err := uploadTempFile(p, "authorized-keys.sh", strings.NewReader(script))
+	if err != nil {
+		return err
+	}
+	defer removeTempFile(p, tmpPath)
+
+	if output, err := p.SSHCommand(fmt.Sprintf("sudo sh '%s'", shellEscape(tmpPath))); err != nil {
+		return fmt.Errorf("error setting the authorized keys: %s", output)
+	}
+
+	return nil
+}
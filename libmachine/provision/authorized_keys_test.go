@@ -0,0 +1,121 @@
+package provision
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const testAuthorizedKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIKyUbaSNk+hcK4kVXDk1j+Bi+nMaIQOGpMCZsOyNCczU alice@laptop"
+
+func TestValidateAuthorizedKeys(t *testing.T) {
+	if err := ValidateAuthorizedKeys([]string{testAuthorizedKey}, "deploy"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, user := range []string{"root", "Deploy", "de ploy", "deploy;id", "-deploy"} {
+		if err := ValidateAuthorizedKeys(nil, user); err == nil {
+			t.Fatalf("%q: expected an error", user)
+		}
+	}
+
+	for _, key := range []string{"", "ssh-ed25519", testAuthorizedKey + "\nEOF"} {
+		if err := ValidateAuthorizedKeys([]string{key}, ""); err == nil {
+			t.Fatalf("%q: expected an error", key)
+		}
+	}
+}
+
+func TestUbuntuSetAuthorizedKeys(t *testing.T) {
+	server, d := newTestServer(t, "ID=ubuntu\n")
+	defer server.Close()
+
+	if err := NewUbuntuProvisioner(d).SetAuthorizedKeys([]string{testAuthorizedKey}, "deploy"); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^cat > '/tmp/tmp.machine/authorized-keys.sh'$`,
+		`^sudo sh '/tmp/tmp.machine/authorized-keys.sh'$`,
+		`^rm -rf '/tmp/tmp.machine'$`,
+	})
+
+	found := server.Find(`^cat > '/tmp/tmp.machine/authorized-keys.sh'$`)
+	if len(found) != 1 {
+		t.Fatalf("expected the script to be uploaded; received:\n%s", server)
+	}
+
+	script := string(found[0].Stdin)
+	for _, expected := range []string{
+		"\n# BEGIN docker-machine authorized keys\n" + testAuthorizedKey + "\n# END docker-machine authorized keys\n",
+		"\nauthorize docker\n",
+		"useradd -m -s /bin/sh deploy\n",
+		"usermod -a -G docker deploy\n",
+		"\nauthorize deploy\n",
+	} {
+		if !strings.Contains(script, expected) {
+			t.Fatalf("expected %q in the script; received:\n%s", expected, script)
+		}
+	}
+}
+
+func TestUbuntuSetAuthorizedKeysNone(t *testing.T) {
+	server, d := newTestServer(t, "ID=ubuntu\n")
+	defer server.Close()
+
+	if err := NewUbuntuProvisioner(d).SetAuthorizedKeys(nil, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	found := server.Find(`^cat > '/tmp/tmp.machine/authorized-keys.sh'$`)
+	if len(found) != 1 {
+		t.Fatalf("expected the script to be uploaded; received:\n%s", server)
+	}
+
+	// the keys set before are still removed
+	script := string(found[0].Stdin)
+	if !strings.Contains(script, "awk") || strings.Contains(script, "cat >>") || strings.Contains(script, "useradd") {
+		t.Fatalf("expected the script to only remove the keys; received:\n%s", script)
+	}
+}
+
+func TestBoot2DockerSetAuthorizedKeys(t *testing.T) {
+	server, d := newTestServer(t, "ID=boot2docker\n")
+	defer server.Close()
+
+	if err := NewBoot2DockerProvisioner(d).SetAuthorizedKeys([]string{testAuthorizedKey}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`install -m 0755 -o root -g root \S+ '/var/lib/boot2docker/machine-authorized-keys.sh'$`,
+		`grep -qxF 'sh /var/lib/boot2docker/machine-authorized-keys.sh' /var/lib/boot2docker/bootsync.sh`,
+		`^sudo sh /var/lib/boot2docker/machine-authorized-keys.sh$`,
+	})
+
+	assertFileWritten(t, server, "/var/lib/boot2docker/machine-authorized-keys.sh", "0755", "\n"+regexp.QuoteMeta(testAuthorizedKey)+"\n")
+}
+
+func TestRancherSetAuthorizedKeys(t *testing.T) {
+	server, d := newTestServer(t, "ID=rancheros\n")
+	defer server.Close()
+
+	p := NewRancherProvisioner(d)
+
+	if err := p.SetAuthorizedKeys([]string{testAuthorizedKey}, "deploy"); err != ErrExtraUserUnsupported {
+		t.Fatalf("expected the extra user to be unsupported; received %v", err)
+	}
+
+	if err := p.SetAuthorizedKeys([]string{testAuthorizedKey}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if found := server.Find(`cloud-config\.d/machine-authorized-keys\.yml'$`); len(found) != 0 {
+		t.Fatalf("expected the keys to be kept out of cloud-config; received:\n%s", server)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^sudo rm -f /var/lib/rancher/conf/cloud-config\.d/machine-authorized-keys\.yml$`,
+		`^sudo sh '/tmp/tmp.machine/authorized-keys.sh'$`,
+	})
+}
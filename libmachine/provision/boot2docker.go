@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"path"
+	"regexp"
+	"strings"
 	"text/template"
 
 	"github.com/docker/machine/drivers"
@@ -34,6 +36,13 @@ type Boot2DockerProvisioner struct {
 	AuthOptions   auth.AuthOptions
 	EngineOptions engine.EngineOptions
 	SwarmOptions  swarm.SwarmOptions
+
+	// MachineHostname is the hostname of the machine, if it differs from
+	// its name.
+	MachineHostname string
+
+	// HostLog is the log of the provisioning, if any.
+	HostLog *log.HostLog
 }
 
 func (provisioner *Boot2DockerProvisioner) Service(name string, action pkgaction.ServiceAction) error {
@@ -48,7 +57,13 @@ func (provisioner *Boot2DockerProvisioner) Service(name string, action pkgaction
 	return nil
 }
 
-func (provisioner *Boot2DockerProvisioner) upgradeIso() error {
+func (provisioner *Boot2DockerProvisioner) ServiceLog(name string, lines int) (string, error) {
+	return provisioner.SSHCommand(fmt.Sprintf("sudo tail -n %d /var/log/%s.log", lines, name))
+}
+
+// upgradeIso replaces the ISO of the machine with the boot2docker release of
+// the given docker version, or the latest release if the version is empty.
+func (provisioner *Boot2DockerProvisioner) upgradeIso(version string) error {
 	log.Info("Stopping machine to do the upgrade...")
 
 	if err := provisioner.Driver.Stop(); err != nil {
@@ -65,14 +80,26 @@ func (provisioner *Boot2DockerProvisioner) upgradeIso() error {
 
 	b2dutils := utils.NewB2dUtils("", "")
 
-	// Usually we call this implicitly, but call it here explicitly to get
-	// the latest boot2docker ISO.
-	if err := b2dutils.DownloadLatestBoot2Docker(); err != nil {
+	// keep the current ISO, to roll back to if the upgrade fails
+	if err := b2dutils.BackupMachineIso(machineName); err != nil {
 		return err
 	}
 
-	// Copy the latest version of boot2docker ISO to the machine's directory
-	if err := b2dutils.CopyIsoToMachineDir("", machineName); err != nil {
+	isoURL := ""
+	if version != "" {
+		// boot2docker releases follow the docker versions
+		isoURL = b2dutils.GetBoot2DockerReleaseURL(version)
+	} else {
+		// Usually we call this implicitly, but call it here explicitly to get
+		// the latest boot2docker ISO.
+		if err := b2dutils.DownloadLatestBoot2Docker(); err != nil {
+			return err
+		}
+	}
+
+	// Copy the boot2docker ISO to the machine's directory, or download it
+	// there directly for a specific release
+	if err := b2dutils.CopyIsoToMachineDir(isoURL, machineName); err != nil {
 		return err
 	}
 
@@ -87,13 +114,43 @@ func (provisioner *Boot2DockerProvisioner) upgradeIso() error {
 
 func (provisioner *Boot2DockerProvisioner) Package(name string, action pkgaction.PackageAction) error {
 	if name == "docker" && action == pkgaction.Upgrade {
-		if err := provisioner.upgradeIso(); err != nil {
+		if err := provisioner.upgradeIso(""); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+func (provisioner *Boot2DockerProvisioner) UpgradeDocker(version string) error {
+	return provisioner.upgradeIso(version)
+}
+
+// RollbackDocker puts back the ISO the machine ran before the upgrade, whose
+// docker version is the given one.
+func (provisioner *Boot2DockerProvisioner) RollbackDocker(version string) error {
+	log.Info("Stopping machine to roll back the upgrade...")
+
+	if err := provisioner.Driver.Stop(); err != nil {
+		return err
+	}
+
+	if err := utils.WaitFor(drivers.MachineInState(provisioner.Driver, state.Stopped)); err != nil {
+		return err
+	}
+
+	if err := utils.NewB2dUtils("", "").RestoreMachineIso(provisioner.GetDriver().GetMachineName()); err != nil {
+		return err
+	}
+
+	log.Infof("Starting machine back up...")
+
+	if err := provisioner.Driver.Start(); err != nil {
+		return err
+	}
+
+	return utils.WaitFor(drivers.MachineInState(provisioner.Driver, state.Running))
+}
+
 func (provisioner *Boot2DockerProvisioner) Hostname() (string, error) {
 	return provisioner.SSHCommand("hostname")
 }
@@ -110,6 +167,138 @@ func (provisioner *Boot2DockerProvisioner) SetHostname(hostname string) error {
 	return nil
 }
 
+// SetAuthorizedKeys keeps the script which sets the keys under
+// /var/lib/boot2docker and runs it from bootsync.sh, since everything else
+// is reset from the ISO on boot, as well as right away.
+func (provisioner *Boot2DockerProvisioner) SetAuthorizedKeys(keys []string, user string) error {
+	script, err := generateAuthorizedKeysScript(provisioner, keys, user)
+	if err != nil {
+		return err
+	}
+
+	scriptPath := path.Join(provisioner.GetDockerOptionsDir(), "machine-authorized-keys.sh")
+
+	if err := WriteFile(provisioner, scriptPath, strings.NewReader(script), 0755); err != nil {
+		return err
+	}
+
+	if err := addBoot2DockerBootCommand(provisioner, fmt.Sprintf("sh %s", scriptPath)); err != nil {
+		return err
+	}
+
+	if output, err := provisioner.SSHCommand(fmt.Sprintf("sudo sh %s", scriptPath)); err != nil {
+		return fmt.Errorf("error setting the authorized keys: %s", output)
+	}
+
+	return nil
+}
+
+// configureRegistries keeps a copy of the registry files under
+// /var/lib/boot2docker along with a script which puts them in place, run
+// from bootsync.sh, since everything else is reset from the ISO on boot.
+func (provisioner *Boot2DockerProvisioner) configureRegistries() error {
+	files, err := registryFiles(provisioner, provisioner.EngineOptions)
+	if err != nil || len(files) == 0 {
+		return err
+	}
+
+	copyDir := path.Join(provisioner.GetDockerOptionsDir(), "machine-registries")
+
+	script := "set -e\n"
+	for _, file := range files {
+		copyPath := path.Join(copyDir, file.Path)
+
+		if err := writeRegistryFile(provisioner, copyPath, file); err != nil {
+			return err
+		}
+
+		script += fmt.Sprintf("mkdir -p %s && cp %s %s && chmod %04o %s\n", path.Dir(file.Path), copyPath, file.Path, uint32(file.Perm), file.Path)
+		if file.Owner != "" {
+			script += fmt.Sprintf("chown -R %s %s\n", file.Owner, path.Dir(file.Path))
+		}
+	}
+
+	scriptPath := path.Join(provisioner.GetDockerOptionsDir(), "machine-registries.sh")
+
+	if err := WriteFile(provisioner, scriptPath, strings.NewReader(script), 0755); err != nil {
+		return err
+	}
+
+	if err := addBoot2DockerBootCommand(provisioner, fmt.Sprintf("sh %s", scriptPath)); err != nil {
+		return err
+	}
+
+	if output, err := provisioner.SSHCommand(fmt.Sprintf("sudo sh %s", scriptPath)); err != nil {
+		return fmt.Errorf("error configuring the registries: %s", output)
+	}
+
+	return nil
+}
+
+// configureKernel keeps the script which loads the kernel modules and sets
+// the sysctls under /var/lib/boot2docker and runs it from bootsync.sh, so
+// that they are set before docker starts on boot, as well as right away.
+// The script is taken out of bootsync.sh and removed when there are none.
+func (provisioner *Boot2DockerProvisioner) configureKernel() error {
+	defer provisioner.GetHostLog().Stage("configure kernel")()
+
+	scriptPath := path.Join(provisioner.GetDockerOptionsDir(), "machine-kernel.sh")
+
+	if len(provisioner.EngineOptions.Sysctl)+len(provisioner.EngineOptions.KernelModules) == 0 {
+		if err := removeBoot2DockerBootCommand(provisioner, fmt.Sprintf("sh %s", scriptPath)); err != nil {
+			return err
+		}
+
+		return removeFile(provisioner, scriptPath)
+	}
+
+	if err := WriteFile(provisioner, scriptPath, strings.NewReader(kernelScript(provisioner.EngineOptions)), 0755); err != nil {
+		return err
+	}
+
+	if err := addBoot2DockerBootCommand(provisioner, fmt.Sprintf("sh %s", scriptPath)); err != nil {
+		return err
+	}
+
+	if output, err := provisioner.SSHCommand(fmt.Sprintf("sudo sh %s", scriptPath)); err != nil {
+		return fmt.Errorf("error tuning the kernel: %s", output)
+	}
+
+	return nil
+}
+
+// addBoot2DockerBootCommand adds a command to bootsync.sh, which boot2docker
+// runs on boot before starting docker, unless it is there already.
+func addBoot2DockerBootCommand(p Provisioner, command string) error {
+	bootSync := "/var/lib/boot2docker/bootsync.sh"
+
+	if _, err := p.SSHCommand(fmt.Sprintf(
+		"sudo touch %[1]s && sudo chmod +x %[1]s && if ! grep -qxF '%[2]s' %[1]s; then echo '%[2]s' | sudo tee -a %[1]s; fi",
+		bootSync,
+		command,
+	)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// removeBoot2DockerBootCommand takes a command added by
+// addBoot2DockerBootCommand out of bootsync.sh.
+func removeBoot2DockerBootCommand(p Provisioner, command string) error {
+	bootSync := "/var/lib/boot2docker/bootsync.sh"
+
+	if _, err := p.SSHCommand(fmt.Sprintf(
+		"if [ -f %[1]s ]; then sudo sed -i '\\#^%[2]s$#d' %[1]s; fi",
+		bootSync,
+		regexp.QuoteMeta(command),
+	)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (provisioner *Boot2DockerProvisioner) GetDockerOptionsDir() string {
 	return "/var/lib/boot2docker"
 }
@@ -125,12 +314,15 @@ func (provisioner *Boot2DockerProvisioner) GenerateDockerOptions(dockerPort int)
 
 	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
 	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+	provisioner.EngineOptions.Env = noProxyMachine(provisioner.Driver, provisioner.EngineOptions.Env)
 
 	engineConfigTmpl := `
-EXTRA_ARGS='
+{{ range .ShellEnv }}export {{.}}
+{{ end }}EXTRA_ARGS='
 {{ range .EngineOptions.Labels }}--label {{.}}
 {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}}
 {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}}
+{{ end }}{{ range .EngineFlags }}{{.}}
 {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}}
 {{ end }}
 '
@@ -149,7 +341,7 @@ SERVERCERT={{.AuthOptions.ServerCertRemotePath}}
 	engineConfigContext := EngineConfigContext{
 		DockerPort:    dockerPort,
 		AuthOptions:   provisioner.AuthOptions,
-		EngineOptions: provisioner.EngineOptions,
+		EngineOptions: escapeEngineOptions(provisioner.EngineOptions, shellEscape),
 	}
 
 	t.Execute(&engineCfg, engineConfigContext)
@@ -169,16 +361,32 @@ func (provisioner *Boot2DockerProvisioner) SetOsReleaseInfo(info *OsRelease) {
 	provisioner.OsReleaseInfo = info
 }
 
+func (provisioner *Boot2DockerProvisioner) SetMachineHostname(hostname string) {
+	provisioner.MachineHostname = hostname
+}
+
+func (provisioner *Boot2DockerProvisioner) SetHostLog(l *log.HostLog) {
+	provisioner.HostLog = l
+}
+
+func (provisioner *Boot2DockerProvisioner) GetHostLog() *log.HostLog {
+	return provisioner.HostLog
+}
+
 func (provisioner *Boot2DockerProvisioner) Provision(swarmOptions swarm.SwarmOptions, authOptions auth.AuthOptions, engineOptions engine.EngineOptions) error {
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
 
+	if engineOptions.CloudInit {
+		return ErrCloudInitUnsupported
+	}
+
 	if provisioner.EngineOptions.StorageDriver == "" {
 		provisioner.EngineOptions.StorageDriver = "aufs"
 	}
 
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+	if err := provisioner.SetHostname(machineHostname(provisioner.Driver, provisioner.MachineHostname)); err != nil {
 		return err
 	}
 
@@ -193,6 +401,10 @@ func (provisioner *Boot2DockerProvisioner) Provision(swarmOptions swarm.SwarmOpt
 		return err
 	}
 
+	if err := provisioner.ensureDockerVersion(ip); err != nil {
+		return err
+	}
+
 	if err := makeDockerOptionsDir(provisioner); err != nil {
 		return err
 	}
@@ -203,15 +415,48 @@ func (provisioner *Boot2DockerProvisioner) Provision(swarmOptions swarm.SwarmOpt
 		return err
 	}
 
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+	if err := provisioner.configureKernel(); err != nil {
+		return err
+	}
+
+	if err := provisioner.configureRegistries(); err != nil {
+		return err
+	}
+
+	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions, provisioner.EngineOptions); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// ensureDockerVersion switches to the boot2docker release of the requested
+// engine version, since the driver boots the latest one.
+func (provisioner *Boot2DockerProvisioner) ensureDockerVersion(ip string) error {
+	version := provisioner.EngineOptions.Version
+	if version == "" {
+		return nil
+	}
+
+	currentVersion, err := GetDockerVersion(provisioner)
+	if err != nil {
+		return err
+	}
+
+	if currentVersion == version {
+		return nil
+	}
+
+	log.Infof("Switching from docker %s to %s...", currentVersion, version)
+	if err := provisioner.upgradeIso(version); err != nil {
+		return err
+	}
+
+	return utils.WaitForDocker(ip, 2376)
+}
+
 func (provisioner *Boot2DockerProvisioner) SSHCommand(args string) (string, error) {
-	return drivers.RunSSHCommandFromDriver(provisioner.Driver, args)
+	return runSSHCommand(provisioner.Driver, provisioner.HostLog, args)
 }
 
 func (provisioner *Boot2DockerProvisioner) GetDriver() drivers.Driver {
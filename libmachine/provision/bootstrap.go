@@ -0,0 +1,172 @@
+package provision
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/log"
+)
+
+// bootstrapLabel is the label of the bootstrap containers, which holds the
+// hash of their spec, so that they are only replaced when it changes.
+const bootstrapLabel = "com.docker.machine.bootstrap"
+
+var (
+	validContainerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+	validRestartPattern       = regexp.MustCompile(`^(no|always|unless-stopped|on-failure(:[0-9]+)?)$`)
+)
+
+// BootstrapContainer is a container started on the machine once it is
+// provisioned, as read from a spec file.
+type BootstrapContainer struct {
+	Name    string   `json:"name"`
+	Image   string   `json:"image"`
+	Ports   []string `json:"ports,omitempty"`
+	Volumes []string `json:"volumes,omitempty"`
+	Env     []string `json:"env,omitempty"`
+	Restart string   `json:"restart,omitempty"`
+	Command []string `json:"command,omitempty"`
+}
+
+// BootstrapCommandContext is the context of the command which runs a
+// bootstrap container.
+type BootstrapCommandContext struct {
+	Container BootstrapContainer
+	Label     string
+	Hash      string
+}
+
+const bootstrapCmdTemplate = `sudo docker run -d \
+--name {{quote .Container.Name}} \
+--label {{quote .Label}}={{quote .Hash}} \
+{{if .Container.Restart}}--restart={{quote .Container.Restart}} \
+{{end}}{{range .Container.Ports}}-p {{quote .}} \
+{{end}}{{range .Container.Volumes}}-v {{quote .}} \
+{{end}}{{range .Container.Env}}-e {{quote .}} \
+{{end}}{{quote .Container.Image}}{{range .Container.Command}} {{quote .}}{{end}}
+`
+
+// hash returns a short hash of the spec of the container.
+func (c BootstrapContainer) hash() string {
+	data, _ := json.Marshal(c)
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:12]
+}
+
+func (c BootstrapContainer) validate() error {
+	if !validContainerNamePattern.MatchString(c.Name) {
+		return fmt.Errorf("invalid container name %q", c.Name)
+	}
+
+	if c.Image == "" {
+		return fmt.Errorf("%s: the image is missing", c.Name)
+	}
+
+	if c.Restart != "" && !validRestartPattern.MatchString(c.Restart) {
+		return fmt.Errorf("%s: invalid restart policy %q, expected no, always, unless-stopped or on-failure[:max-retries]", c.Name, c.Restart)
+	}
+
+	for _, env := range c.Env {
+		if !strings.Contains(env, "=") {
+			return fmt.Errorf("%s: invalid environment variable %q, expected NAME=value", c.Name, env)
+		}
+	}
+
+	return nil
+}
+
+// ReadBootstrapContainers reads and checks a spec file of bootstrap
+// containers, a JSON list of objects with a name, an image, and optionally
+// ports, volumes, env, a restart policy and a command.
+func ReadBootstrapContainers(specPath string) ([]BootstrapContainer, error) {
+	data, err := ioutil.ReadFile(specPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []BootstrapContainer
+	if err := json.Unmarshal(data, &containers); err != nil {
+		return nil, fmt.Errorf("%s is not a list of containers: %s", specPath, err)
+	}
+
+	names := map[string]bool{}
+	for _, c := range containers {
+		if err := c.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %s", specPath, err)
+		}
+
+		if names[c.Name] {
+			return nil, fmt.Errorf("%s: the container %s is defined twice", specPath, c.Name)
+		}
+		names[c.Name] = true
+	}
+
+	return containers, nil
+}
+
+// bootstrapContainerHash returns the hash of the spec the container was
+// started with, or an empty string if there is no such container.
+func bootstrapContainerHash(p Provisioner, name string) string {
+	output, err := p.SSHCommand(fmt.Sprintf(`sudo docker inspect -f '{{index .Config.Labels "%s"}}' %s`, bootstrapLabel, name))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(output)
+}
+
+// runBootstrapContainer starts a container, unless it already runs with the
+// same spec.  A container of the same name with another spec is replaced.
+func runBootstrapContainer(p Provisioner, c BootstrapContainer, engineOptions engine.EngineOptions) error {
+	hash := c.hash()
+
+	switch bootstrapContainerHash(p, c.Name) {
+	case hash:
+		log.Debugf("Bootstrap container %s is up to date", c.Name)
+		_, err := p.SSHCommand(fmt.Sprintf("sudo docker start %s", c.Name))
+		return err
+	case "":
+	default:
+		log.Debugf("Replacing bootstrap container %s", c.Name)
+		if _, err := p.SSHCommand(fmt.Sprintf("sudo docker rm -f %s", c.Name)); err != nil {
+			return err
+		}
+	}
+
+	if engineOptions.Offline {
+		if err := loadOfflineImage(p, c.Image); err != nil {
+			return err
+		}
+	} else if _, err := p.SSHCommand(fmt.Sprintf("sudo docker pull '%s'", shellEscape(c.Image))); err != nil {
+		return err
+	}
+
+	return runCommandFromTemplate(p, bootstrapCmdTemplate, BootstrapCommandContext{
+		Container: c,
+		Label:     bootstrapLabel,
+		Hash:      hash,
+	})
+}
+
+// BootstrapContainers starts the bootstrap containers on the machine once
+// the engine is up.  It is idempotent: the containers already started with
+// the same spec are left as they are.
+func BootstrapContainers(p Provisioner, containers []BootstrapContainer, engineOptions engine.EngineOptions) error {
+	for _, c := range containers {
+		if err := c.validate(); err != nil {
+			return err
+		}
+
+		log.Debugf("Starting bootstrap container %s", c.Name)
+
+		if err := runBootstrapContainer(p, c, engineOptions); err != nil {
+			return fmt.Errorf("error starting the bootstrap container %s: %s", c.Name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package provision
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/engine"
+)
+
+var testBootstrapContainer = BootstrapContainer{
+	Name:    "logspout",
+	Image:   "gliderlabs/logspout:v3",
+	Ports:   []string{"8000:80"},
+	Volumes: []string{"/var/run/docker.sock:/var/run/docker.sock"},
+	Env:     []string{"ROUTE_URIS=syslog://logs.example.com:514", "TAIL='all'"},
+	Restart: "always",
+	Command: []string{"syslog+tls://logs.example.com:55555"},
+}
+
+func TestReadBootstrapContainers(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	specPath := filepath.Join(tmpDir, "containers.json")
+
+	spec := `[{"name": "exporter", "image": "prom/node-exporter", "ports": ["9100:9100"], "restart": "on-failure:3"}]`
+	if err := ioutil.WriteFile(specPath, []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	containers, err := ReadBootstrapContainers(specPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(containers) != 1 || containers[0].Name != "exporter" || containers[0].Ports[0] != "9100:9100" {
+		t.Fatalf("unexpected containers %+v", containers)
+	}
+
+	invalidSpecs := []string{
+		`{"name": "exporter", "image": "prom/node-exporter"}`,
+		`[{"name": "exporter"}]`,
+		`[{"name": "-exporter", "image": "prom/node-exporter"}]`,
+		`[{"name": "exporter;id", "image": "prom/node-exporter"}]`,
+		`[{"name": "exporter", "image": "prom/node-exporter", "restart": "sometimes"}]`,
+		`[{"name": "exporter", "image": "prom/node-exporter", "env": ["DEBUG"]}]`,
+		`[{"name": "exporter", "image": "prom/node-exporter"}, {"name": "exporter", "image": "busybox"}]`,
+	}
+
+	for _, spec := range invalidSpecs {
+		if err := ioutil.WriteFile(specPath, []byte(spec), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := ReadBootstrapContainers(specPath); err == nil {
+			t.Fatalf("%s: expected an error", spec)
+		}
+	}
+}
+
+func TestBootstrapContainers(t *testing.T) {
+	server, d := newTestServer(t, "ID=ubuntu\n")
+	defer server.Close()
+
+	if err := BootstrapContainers(NewUbuntuProvisioner(d), []BootstrapContainer{testBootstrapContainer}, engine.EngineOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^sudo docker inspect -f '{{index .Config.Labels "com.docker.machine.bootstrap"}}' logspout$`,
+		`^sudo docker pull 'gliderlabs/logspout:v3'$`,
+		`^sudo docker run -d \\\n--name 'logspout' \\\n--label 'com.docker.machine.bootstrap'='` + testBootstrapContainer.hash() + `' \\\n` +
+			`--restart='always' \\\n-p '8000:80' \\\n-v '/var/run/docker.sock:/var/run/docker.sock' \\\n` +
+			`-e 'ROUTE_URIS=syslog://logs.example.com:514' \\\n-e 'TAIL='\\''all'\\''' \\\n` +
+			`'gliderlabs/logspout:v3' 'syslog\+tls://logs.example.com:55555'\n$`,
+	})
+
+	if found := server.Find(`^sudo docker rm`); len(found) != 0 {
+		t.Fatalf("expected no container to be removed; received:\n%s", server)
+	}
+}
+
+func TestBootstrapContainersUpToDate(t *testing.T) {
+	server, d := newTestServer(t, "ID=ubuntu\n")
+	defer server.Close()
+
+	server.Handle(`^sudo docker inspect .* logspout$`, testBootstrapContainer.hash()+"\n", 0)
+
+	if err := BootstrapContainers(NewUbuntuProvisioner(d), []BootstrapContainer{testBootstrapContainer}, engine.EngineOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^sudo docker inspect .* logspout$`,
+		`^sudo docker start logspout$`,
+	})
+
+	if found := server.Find(`^sudo docker (pull|run|rm)`); len(found) != 0 {
+		t.Fatalf("expected the container to be left as it is; received:\n%s", server)
+	}
+}
+
+func TestBootstrapContainersChanged(t *testing.T) {
+	server, d := newTestServer(t, "ID=ubuntu\n")
+	defer server.Close()
+
+	server.Handle(`^sudo docker inspect .* logspout$`, "0123456789ab\n", 0)
+
+	if err := BootstrapContainers(NewUbuntuProvisioner(d), []BootstrapContainer{testBootstrapContainer}, engine.EngineOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^sudo docker inspect .* logspout$`,
+		`^sudo docker rm -f logspout$`,
+		`^sudo docker pull 'gliderlabs/logspout:v3'$`,
+		`^sudo docker run -d `,
+	})
+}
@@ -8,19 +8,21 @@ const (
 	// TODO: eventually the RPM install process will be integrated
 	// into the get.docker.com install script; for now
 	// we install via vendored RPMs
-	dockerCentosRPMPath = "https://get.docker.com/rpm/1.7.0/centos-7/RPMS/x86_64/docker-engine-1.7.0-1.el7.centos.x86_64.rpm"
+	dockerCentosRPMPath = "https://get.docker.com/rpm/%[1]s/centos-7/RPMS/x86_64/docker-engine-%[1]s-1.el7.centos.x86_64.rpm"
 )
 
 func init() {
 	Register("Centos", &RegisteredProvisioner{
 		New: NewCentosProvisioner,
+		// older releases do not use systemd
+		Versions: ">=7",
 	})
 }
 
 func NewCentosProvisioner(d drivers.Driver) Provisioner {
 	g := GenericProvisioner{
 		DockerOptionsDir:  "/etc/docker",
-		DaemonOptionsFile: "/etc/systemd/system/docker.service",
+		DaemonOptionsFile: systemdDropInFile,
 		OsReleaseId:       "centos",
 		Packages:          []string{},
 		Driver:            d,
@@ -0,0 +1,103 @@
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/log"
+	"github.com/docker/machine/utils"
+)
+
+const (
+	// cloudInitDoneFile is written by cloud-init once it has run all the
+	// modules of the first boot.
+	cloudInitDoneFile = "/var/lib/cloud/instance/boot-finished"
+
+	// cloudInitLogFile holds the output of the commands run by cloud-init.
+	cloudInitLogFile = "/var/log/cloud-init-output.log"
+)
+
+// cloudConfigPackages are installed by cloud-init, with the package manager
+// of the OS.  They are the packages the provisioners install over SSH.
+var cloudConfigPackages = []string{"curl", "sudo"}
+
+var cloudConfigTmpl = `#cloud-config
+hostname: {{ quote .Hostname }}
+manage_etc_hosts: true
+packages:
+{{ range .Packages }}  - {{ quote . }}
+{{ end }}runcmd:
+{{ range .Commands }}  - [sh, -c, {{ quote . }}]
+{{ end }}`
+
+type CloudConfigContext struct {
+	Hostname string
+	Packages []string
+	Commands []string
+}
+
+// GenerateCloudConfig renders the cloud-config document which sets the
+// hostname of a machine, installs the packages docker needs and docker
+// itself on its first boot, and pulls the swarm image.  The certificates
+// and the daemon config are still written over SSH by ConfigureAuth, as
+// the server certificate needs the IP of the machine, and the format of the
+// daemon config depends on the OS, which is detected over SSH.  The swarm
+// containers need the daemon to listen with those certificates.
+//
+// TODO: render the daemon config and the swarm containers, once the server
+// certificate can be issued without the IP of the machine.
+func GenerateCloudConfig(hostname string, swarmOptions swarm.SwarmOptions, engineOptions engine.EngineOptions) (string, error) {
+	var commands []string
+
+	commands = append(commands, proxyCommand(engineOptions.Env, fmt.Sprintf("if ! type docker; then curl -sSL %s | sh -; fi", engineOptions.InstallURL)))
+
+	if swarmOptions.IsSwarm {
+		commands = append(commands, fmt.Sprintf("docker pull %s", swarmOptions.Image))
+	}
+
+	t, err := template.New("cloud-config").Funcs(template.FuncMap{
+		// double quoted YAML strings are JSON strings
+		"quote": strconv.Quote,
+	}).Parse(cloudConfigTmpl)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, CloudConfigContext{
+		Hostname: hostname,
+		Packages: cloudConfigPackages,
+		Commands: commands,
+	}); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// waitForCloudInit waits for cloud-init to finish setting up the machine,
+// and checks that it installed docker.
+func waitForCloudInit(p Provisioner) error {
+	defer p.GetHostLog().Stage("wait for cloud-init")()
+
+	log.Info("Waiting for cloud-init to set up the machine...")
+
+	// installing the packages and docker takes a while on the first boot
+	if err := utils.WaitForSpecific(func() bool {
+		_, err := p.SSHCommand(fmt.Sprintf("test -f %s", cloudInitDoneFile))
+		return err == nil
+	}, 200, 3*time.Second); err != nil {
+		return fmt.Errorf("cloud-init did not finish: %s", err)
+	}
+
+	if _, err := p.SSHCommand("type docker"); err != nil {
+		return fmt.Errorf("cloud-init did not install docker, see %s on the machine", cloudInitLogFile)
+	}
+
+	return nil
+}
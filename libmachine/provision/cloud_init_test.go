@@ -0,0 +1,84 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+func TestGenerateCloudConfig(t *testing.T) {
+	swarmOptions := swarm.SwarmOptions{
+		IsSwarm: true,
+		Image:   "swarm:latest",
+	}
+
+	engineOptions := engine.EngineOptions{
+		Env:        []string{"HTTP_PROXY=http://proxy.example.com:3128"},
+		InstallURL: "https://get.docker.com",
+	}
+
+	cloudConfig, err := GenerateCloudConfig("dev", swarmOptions, engineOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `#cloud-config
+hostname: "dev"
+manage_etc_hosts: true
+packages:
+  - "curl"
+  - "sudo"
+runcmd:
+  - [sh, -c, "export http_proxy='http://proxy.example.com:3128' HTTP_PROXY='http://proxy.example.com:3128'; if ! type docker; then curl -sSL https://get.docker.com | sh -; fi"]
+  - [sh, -c, "docker pull swarm:latest"]
+`
+
+	if cloudConfig != expected {
+		t.Fatalf("expected:\n%s\nreceived:\n%s", expected, cloudConfig)
+	}
+}
+
+func TestUbuntuCloudInitProvision(t *testing.T) {
+	server, d := newTestServer(t, "ID=ubuntu\nVERSION_ID=\"14.04\"\n")
+	defer server.Close()
+
+	authOptions, tmpDir := newTestAuthOptions(t, "test")
+	defer removeTestStore(tmpDir)
+
+	p, err := DetectProvisioner(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engineOptions := engine.EngineOptions{
+		CloudInit:  true,
+		InstallURL: "https://get.docker.com",
+	}
+
+	if err := p.Provision(swarm.SwarmOptions{}, authOptions, engineOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	if found := server.Find(`get\.docker\.com|apt-get|sudo hostname`); len(found) != 0 {
+		t.Fatalf("expected cloud-init to set up the machine; received:\n%s", server)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^test -f /var/lib/cloud/instance/boot-finished$`,
+		`^type docker$`,
+		`^sudo docker version$`,
+		`install -m 0644 -o root -g root \S+ '/etc/default/docker'$`,
+	})
+}
+
+func TestCloudInitUnsupported(t *testing.T) {
+	server, d := newTestServer(t, "ID=coreos\n")
+	defer server.Close()
+
+	p := NewCoreOSProvisioner(d)
+
+	if err := p.Provision(swarm.SwarmOptions{}, newTestRemoteAuthOptions(), engine.EngineOptions{CloudInit: true}); err != ErrCloudInitUnsupported {
+		t.Fatalf("expected cloud-init to be unsupported; received %v", err)
+	}
+}
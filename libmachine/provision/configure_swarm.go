@@ -8,6 +8,7 @@ import (
 	"text/template"
 
 	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/swarm"
 	"github.com/docker/machine/log"
 )
@@ -23,21 +24,31 @@ type SwarmCommandContext struct {
 	SwarmImage    string
 }
 
-// Wrapper function to generate a docker run swarm command (manage or join)
-// from a template/context and execute it.
-func runSwarmCommandFromTemplate(p Provisioner, cmdTmpl string, swarmCmdContext SwarmCommandContext) error {
+// templateFuncs are the functions of the command templates.  quote single
+// quotes a value for the shell.
+var templateFuncs = template.FuncMap{
+	"quote": func(s string) string {
+		return "'" + shellEscape(s) + "'"
+	},
+}
+
+// Wrapper function to generate a command, such as a docker run of a swarm
+// or bootstrap container, from a template/context and execute it.
+func runCommandFromTemplate(p Provisioner, cmdTmpl string, context interface{}) error {
 	var (
 		executedCmdTmpl bytes.Buffer
 	)
 
-	parsedMasterCmdTemplate, err := template.New("swarmMasterCmd").Parse(cmdTmpl)
+	parsedCmdTemplate, err := template.New("cmd").Funcs(templateFuncs).Parse(cmdTmpl)
 	if err != nil {
 		return err
 	}
 
-	parsedMasterCmdTemplate.Execute(&executedCmdTmpl, swarmCmdContext)
+	if err := parsedCmdTemplate.Execute(&executedCmdTmpl, context); err != nil {
+		return err
+	}
 
-	log.Debugf("The swarm command being run is: %s", executedCmdTmpl.String())
+	log.Debugf("The command being run is: %s", executedCmdTmpl.String())
 
 	if _, err := p.SSHCommand(executedCmdTmpl.String()); err != nil {
 		return err
@@ -46,11 +57,13 @@ func runSwarmCommandFromTemplate(p Provisioner, cmdTmpl string, swarmCmdContext
 	return nil
 }
 
-func configureSwarm(p Provisioner, swarmOptions swarm.SwarmOptions, authOptions auth.AuthOptions) error {
+func configureSwarm(p Provisioner, swarmOptions swarm.SwarmOptions, authOptions auth.AuthOptions, engineOptions engine.EngineOptions) error {
 	if !swarmOptions.IsSwarm {
 		return nil
 	}
 
+	defer p.GetHostLog().Stage("configure swarm")()
+
 	ip, err := p.GetDriver().GetIP()
 	if err != nil {
 		return err
@@ -78,7 +91,11 @@ func configureSwarm(p Provisioner, swarmOptions swarm.SwarmOptions, authOptions
 	}
 
 	// First things first, get the swarm image.
-	if _, err := p.SSHCommand(fmt.Sprintf("sudo docker pull %s", swarmOptions.Image)); err != nil {
+	if engineOptions.Offline {
+		if err := loadOfflineImage(p, swarmOptions.Image); err != nil {
+			return err
+		}
+	} else if _, err := p.SSHCommand(fmt.Sprintf("sudo docker pull %s", swarmOptions.Image)); err != nil {
 		return err
 	}
 
@@ -106,13 +123,13 @@ join --addr {{.Ip}}:{{.DockerPort}} {{.SwarmOptions.Discovery}}
 
 	if swarmOptions.Master {
 		log.Debug("Launching swarm master")
-		if err := runSwarmCommandFromTemplate(p, swarmMasterCmdTemplate, swarmCmdContext); err != nil {
+		if err := runCommandFromTemplate(p, swarmMasterCmdTemplate, swarmCmdContext); err != nil {
 			return err
 		}
 	}
 
 	log.Debug("Launch swarm worker")
-	if err := runSwarmCommandFromTemplate(p, swarmWorkerCmdTemplate, swarmCmdContext); err != nil {
+	if err := runCommandFromTemplate(p, swarmWorkerCmdTemplate, swarmCmdContext); err != nil {
 		return err
 	}
 
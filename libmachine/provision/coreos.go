@@ -0,0 +1,253 @@
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/log"
+	"github.com/docker/machine/utils"
+)
+
+const (
+	coreOSSocketUnit = "/etc/systemd/system/docker-tls-tcp.socket"
+)
+
+func init() {
+	Register("CoreOS", &RegisteredProvisioner{
+		New: NewCoreOSProvisioner,
+	})
+}
+
+func NewCoreOSProvisioner(d drivers.Driver) Provisioner {
+	return &CoreOSProvisioner{
+		GenericProvisioner{
+			DockerOptionsDir:  "/etc/docker",
+			DaemonOptionsFile: systemdDropInFile,
+			OsReleaseId:       "coreos",
+			Driver:            d,
+		},
+	}
+}
+
+type CoreOSProvisioner struct {
+	GenericProvisioner
+}
+
+func (provisioner *CoreOSProvisioner) SetAuthorizedKeys(keys []string, user string) error {
+	return setAuthorizedKeys(provisioner, keys, user)
+}
+
+func (provisioner *CoreOSProvisioner) Service(name string, action pkgaction.ServiceAction) error {
+	return systemdService(provisioner, name, action)
+}
+
+func (provisioner *CoreOSProvisioner) ServiceLog(name string, lines int) (string, error) {
+	return systemdServiceLog(provisioner, name, lines)
+}
+
+// Package is a no-op since CoreOS has no package manager and ships docker
+// as part of the image.  Upgrading docker means upgrading the whole image,
+// which is done with update-engine followed by a reboot.
+func (provisioner *CoreOSProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	if name == "docker" && action == pkgaction.Upgrade {
+		return provisioner.upgrade()
+	}
+
+	log.Debugf("package: skipping action=%s name=%s, CoreOS has no package manager", action.String(), name)
+
+	return nil
+}
+
+// UpgradeDocker upgrades CoreOS, which ships a single version of docker per
+// release.
+func (provisioner *CoreOSProvisioner) UpgradeDocker(version string) error {
+	if version != "" {
+		return ErrEngineVersionUnsupported
+	}
+
+	return provisioner.Package("docker", pkgaction.Upgrade)
+}
+
+func (provisioner *CoreOSProvisioner) RollbackDocker(version string) error {
+	return ErrRollbackUnsupported
+}
+
+func (provisioner *CoreOSProvisioner) bootID() (string, error) {
+	return provisioner.SSHCommand("cat /proc/sys/kernel/random/boot_id")
+}
+
+func (provisioner *CoreOSProvisioner) upgrade() error {
+	bootID, err := provisioner.bootID()
+	if err != nil {
+		return err
+	}
+
+	log.Info("Checking for CoreOS updates...")
+	if output, err := provisioner.SSHCommand("sudo update_engine_client -update"); err != nil {
+		return fmt.Errorf("error updating CoreOS: %s", output)
+	}
+
+	status, err := provisioner.SSHCommand("update_engine_client -status")
+	if err != nil {
+		return err
+	}
+
+	if !strings.Contains(status, "UPDATE_STATUS_UPDATED_NEED_REBOOT") {
+		log.Info("CoreOS is already up to date")
+		return nil
+	}
+
+	// the connection usually drops before the command returns, so
+	// whether the reboot worked is told by the boot id changing
+	log.Info("Rebooting to apply the update...")
+	provisioner.SSHCommand("sudo systemctl reboot")
+
+	return utils.WaitFor(func() bool {
+		newBootID, err := provisioner.bootID()
+		if err != nil {
+			log.Debugf("Waiting for reboot: %s", err)
+			return false
+		}
+		return newBootID != bootID
+	})
+}
+
+func (provisioner *CoreOSProvisioner) Provision(swarmOptions swarm.SwarmOptions, authOptions auth.AuthOptions, engineOptions engine.EngineOptions) error {
+	provisioner.SwarmOptions = swarmOptions
+	provisioner.AuthOptions = authOptions
+	provisioner.EngineOptions = engineOptions
+
+	if engineOptions.CloudInit {
+		return ErrCloudInitUnsupported
+	}
+
+	if provisioner.EngineOptions.Version != "" {
+		return ErrEngineVersionUnsupported
+	}
+
+	log.Debug("setting hostname")
+	if err := provisioner.SetHostname(provisioner.machineHostname()); err != nil {
+		return err
+	}
+
+	if err := makeDockerOptionsDir(provisioner); err != nil {
+		return err
+	}
+
+	log.Debug("configuring docker socket")
+	if err := provisioner.configureSocket(); err != nil {
+		return err
+	}
+
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+
+	log.Debug("configuring auth")
+	if err := ConfigureAuth(provisioner); err != nil {
+		return err
+	}
+
+	log.Debug("configuring kernel")
+	if err := configureKernel(provisioner, provisioner.EngineOptions); err != nil {
+		return err
+	}
+
+	log.Debug("configuring registries")
+	if err := configureRegistries(provisioner, provisioner.EngineOptions); err != nil {
+		return err
+	}
+
+	log.Debug("configuring swarm")
+	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions, provisioner.EngineOptions); err != nil {
+		return err
+	}
+
+	// docker is socket activated by default; start it on boot so that
+	// containers restart without waiting for the first client
+	log.Debug("enabling docker in systemd")
+	if err := provisioner.Service("docker", pkgaction.Enable); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// configureSocket adds a socket unit listening on the daemon port, which is
+// handed to docker by systemd along with the default unix socket.
+func (provisioner *CoreOSProvisioner) configureSocket() error {
+	dockerPort, err := getDockerPort(provisioner)
+	if err != nil {
+		return err
+	}
+
+	socketUnit := fmt.Sprintf(`[Unit]
+Description=Docker Secured Socket for the API
+
+[Socket]
+ListenStream=%d
+BindIPv6Only=both
+Service=docker.service
+
+[Install]
+WantedBy=sockets.target
+`, dockerPort)
+
+	if err := prepareSystemdDropIn(provisioner); err != nil {
+		return err
+	}
+
+	if err := WriteFile(provisioner, coreOSSocketUnit, strings.NewReader(socketUnit), 0644); err != nil {
+		return err
+	}
+
+	if err := provisioner.Service("docker-tls-tcp.socket", pkgaction.DaemonReload); err != nil {
+		return err
+	}
+
+	if err := provisioner.Service("docker-tls-tcp.socket", pkgaction.Enable); err != nil {
+		return err
+	}
+
+	return provisioner.Service("docker-tls-tcp.socket", pkgaction.Start)
+}
+
+// GenerateDockerOptions returns a drop-in for the docker service, which on
+// CoreOS takes its options from DOCKER_OPTS.  The listening sockets are
+// configured by systemd instead of with -H.
+func (provisioner *CoreOSProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
+	var (
+		engineCfg bytes.Buffer
+	)
+
+	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
+	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+	provisioner.EngineOptions.Env = noProxyMachine(provisioner.Driver, provisioner.EngineOptions.Env)
+
+	engineConfigTmpl := `[Service]
+Environment='DOCKER_OPTS={{ if .EngineOptions.StorageDriver }}--storage-driver {{.EngineOptions.StorageDriver}} {{ end }}--tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .EngineFlags }}{{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}'
+{{ range .EngineOptions.Env }}Environment='{{.}}'
+{{ end }}`
+	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	engineConfigContext := EngineConfigContext{
+		DockerPort:    dockerPort,
+		AuthOptions:   provisioner.AuthOptions,
+		EngineOptions: escapeEngineOptions(provisioner.EngineOptions, systemdQuotedEscape),
+	}
+
+	t.Execute(&engineCfg, engineConfigContext)
+
+	return &DockerOptions{
+		EngineOptions:     engineCfg.String(),
+		EngineOptionsPath: provisioner.DaemonOptionsFile,
+	}, nil
+}
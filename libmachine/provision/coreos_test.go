@@ -0,0 +1,138 @@
+package provision
+
+import (
+	"net"
+	"testing"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/ssh/sshtest"
+)
+
+const coreOSRelease = "NAME=CoreOS\nID=coreos\nVERSION_ID=766.3.0\n"
+
+func TestCoreOSProvision(t *testing.T) {
+	server, d := newTestServer(t, coreOSRelease)
+	defer server.Close()
+
+	authOptions, tmpDir := newTestAuthOptions(t, "test")
+	defer removeTestStore(tmpDir)
+
+	p, err := DetectProvisioner(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := p.(*CoreOSProvisioner); !ok {
+		t.Fatalf("expected the CoreOS provisioner; received %T", p)
+	}
+
+	swarmOptions := swarm.SwarmOptions{
+		IsSwarm:   true,
+		Host:      "tcp://0.0.0.0:3376",
+		Image:     "swarm:latest",
+		Discovery: "token://abc",
+	}
+
+	if err := p.Provision(swarmOptions, authOptions, engine.EngineOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, dockerPort, err := net.SplitHostPort(server.DockerAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^sudo hostname test && echo "test" \| sudo tee /etc/hostname$`,
+		`^sudo mkdir -p /etc/docker$`,
+		`install -m 0644 -o root -g root \S+ '/etc/systemd/system/docker-tls-tcp.socket'$`,
+		`^sudo systemctl enable docker-tls-tcp.socket$`,
+		`^sudo systemctl start docker-tls-tcp.socket$`,
+		`^sudo systemctl stop docker$`,
+		`install -m 0644 -o root -g root \S+ '/etc/docker/ca.pem'$`,
+		`install -m 0644 -o root -g root \S+ '/etc/systemd/system/docker.service.d/10-machine.conf'$`,
+		`^sudo systemctl daemon-reload$`,
+		`^sudo systemctl start docker$`,
+		`join --addr 127.0.0.1:2376 token://abc`,
+		`^sudo systemctl enable docker$`,
+	})
+
+	assertFileWritten(t, server, "/etc/systemd/system/docker-tls-tcp.socket", "0644", `ListenStream=`+dockerPort+`\n`)
+	assertFileWritten(t, server, "/etc/systemd/system/docker.service.d/10-machine.conf", "0644", `Environment='DOCKER_OPTS=--tlsverify --tlscacert /etc/docker/ca.pem .*'\n`)
+
+	if found := server.Find(`get.docker.com|apt-get|yum|zypper`); len(found) != 0 {
+		t.Fatalf("unexpected package installation on CoreOS: %+v", found)
+	}
+}
+
+func TestCoreOSUpgrade(t *testing.T) {
+	server, d := newTestServer(t, coreOSRelease)
+	defer server.Close()
+
+	bootID := "first-boot\n"
+	server.HandleFunc(`boot_id$`, func(cmd sshtest.Command) (string, int) {
+		return bootID, 0
+	})
+	server.Handle(`^update_engine_client -status$`, "CURRENT_OP=UPDATE_STATUS_UPDATED_NEED_REBOOT\n", 0)
+	server.HandleFunc(`^sudo systemctl reboot$`, func(cmd sshtest.Command) (string, int) {
+		bootID = "second-boot\n"
+		return "", 255
+	})
+
+	p := NewCoreOSProvisioner(d)
+
+	if err := p.Package("docker", pkgaction.Upgrade); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`boot_id$`,
+		`^sudo update_engine_client -update$`,
+		`^update_engine_client -status$`,
+		`^sudo systemctl reboot$`,
+		`boot_id$`,
+	})
+
+	server.Reset()
+	server.Handle(`^update_engine_client -status$`, "CURRENT_OP=UPDATE_STATUS_IDLE\n", 0)
+
+	if err := p.Package("docker", pkgaction.Upgrade); err != nil {
+		t.Fatal(err)
+	}
+
+	if found := server.Find(`reboot`); len(found) != 0 {
+		t.Fatalf("expected no reboot without an update; received:\n%s", server)
+	}
+
+	server.Reset()
+
+	if err := p.Package("curl", pkgaction.Install); err != nil {
+		t.Fatal(err)
+	}
+
+	if commands := server.Commands(); len(commands) != 0 {
+		t.Fatalf("expected package installation to be skipped; received:\n%s", server)
+	}
+}
+
+func TestCoreOSEngineVersionUnsupported(t *testing.T) {
+	server, d := newTestServer(t, coreOSRelease)
+	defer server.Close()
+
+	p := NewCoreOSProvisioner(d)
+
+	if err := p.Provision(swarm.SwarmOptions{}, auth.AuthOptions{}, engine.EngineOptions{Version: "1.8.2"}); err != ErrEngineVersionUnsupported {
+		t.Fatalf("expected an error for an engine version; received %v", err)
+	}
+
+	if err := p.UpgradeDocker("1.8.2"); err != ErrEngineVersionUnsupported {
+		t.Fatalf("expected an error for an engine version; received %v", err)
+	}
+
+	if len(server.Commands()) != 0 {
+		t.Fatalf("expected no commands to be run; received:\n%s", server)
+	}
+}
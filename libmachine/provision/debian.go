@@ -1,9 +1,7 @@
 package provision
 
 import (
-	"bytes"
 	"fmt"
-	"text/template"
 
 	"github.com/docker/machine/drivers"
 	"github.com/docker/machine/libmachine/auth"
@@ -17,6 +15,8 @@ import (
 func init() {
 	Register("Debian", &RegisteredProvisioner{
 		New: NewDebianProvisioner,
+		// older releases do not use systemd
+		Versions: ">=8",
 	})
 }
 
@@ -24,7 +24,7 @@ func NewDebianProvisioner(d drivers.Driver) Provisioner {
 	return &DebianProvisioner{
 		GenericProvisioner{
 			DockerOptionsDir:  "/etc/docker",
-			DaemonOptionsFile: "/etc/systemd/system/docker.service",
+			DaemonOptionsFile: systemdDropInFile,
 			OsReleaseId:       "debian",
 			Packages: []string{
 				"curl",
@@ -38,19 +38,16 @@ type DebianProvisioner struct {
 	GenericProvisioner
 }
 
-func (provisioner *DebianProvisioner) Service(name string, action pkgaction.ServiceAction) error {
-	// daemon-reload to catch config updates; systemd -- ugh
-	if _, err := provisioner.SSHCommand("sudo systemctl daemon-reload"); err != nil {
-		return err
-	}
-
-	command := fmt.Sprintf("sudo systemctl %s %s", action.String(), name)
+func (provisioner *DebianProvisioner) SetAuthorizedKeys(keys []string, user string) error {
+	return setAuthorizedKeys(provisioner, keys, user)
+}
 
-	if _, err := provisioner.SSHCommand(command); err != nil {
-		return err
-	}
+func (provisioner *DebianProvisioner) Service(name string, action pkgaction.ServiceAction) error {
+	return systemdService(provisioner, name, action)
+}
 
-	return nil
+func (provisioner *DebianProvisioner) ServiceLog(name string, lines int) (string, error) {
+	return systemdServiceLog(provisioner, name, lines)
 }
 
 func (provisioner *DebianProvisioner) Package(name string, action pkgaction.PackageAction) error {
@@ -74,7 +71,7 @@ func (provisioner *DebianProvisioner) Package(name string, action pkgaction.Pack
 	}
 
 	if updateMetadata {
-		if _, err := provisioner.SSHCommand("sudo apt-get update"); err != nil {
+		if _, err := provisioner.SSHCommand("sudo -E apt-get update"); err != nil {
 			return err
 		}
 	}
@@ -90,6 +87,50 @@ func (provisioner *DebianProvisioner) Package(name string, action pkgaction.Pack
 	return nil
 }
 
+// installDocker installs docker with the install script, pinned to the
+// requested version, or from the offline cache.
+func (provisioner *DebianProvisioner) installDocker() error {
+	engineOptions := provisioner.EngineOptions
+
+	switch {
+	case engineOptions.Offline:
+		return installOfflineDocker(provisioner, provisioner.OsReleaseInfo, engineOptions.Version, "DEBIAN_FRONTEND=noninteractive sudo -E dpkg -i '%s'")
+	case engineOptions.CloudInit:
+		// cloud-init ran the install script on the first boot
+	default:
+		// the base packages are only needed by the install script
+		for _, pkg := range provisioner.Packages {
+			if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
+				return err
+			}
+		}
+
+		if err := installDockerGeneric(provisioner, engineOptions.InstallURL); err != nil {
+			return err
+		}
+	}
+
+	if engineOptions.Version != "" {
+		return installDockerVersionApt(provisioner, engineOptions.Version)
+	}
+
+	return nil
+}
+
+func (provisioner *DebianProvisioner) UpgradeDocker(version string) error {
+	if version == "" {
+		return provisioner.Package("docker-engine", pkgaction.Upgrade)
+	}
+
+	return installDockerVersionApt(provisioner, version)
+}
+
+// RollbackDocker installs the previous version, which the package manager
+// downgrades to.
+func (provisioner *DebianProvisioner) RollbackDocker(version string) error {
+	return provisioner.UpgradeDocker(version)
+}
+
 func (provisioner *DebianProvisioner) dockerDaemonResponding() bool {
 	if _, err := provisioner.SSHCommand("sudo docker version"); err != nil {
 		log.Warnf("Error getting SSH command to check if the daemon is up: %s", err)
@@ -109,26 +150,26 @@ func (provisioner *DebianProvisioner) Provision(swarmOptions swarm.SwarmOptions,
 		provisioner.EngineOptions.StorageDriver = "aufs"
 	}
 
-	// HACK: since debian does not come with sudo by default we install
-	log.Debug("installing sudo")
-	if _, err := provisioner.SSHCommand("if ! type sudo; then apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y sudo; fi"); err != nil {
-		return err
-	}
-
-	log.Debug("setting hostname")
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
-		return err
-	}
+	// cloud-init installed sudo and set the hostname on the first boot
+	if engineOptions.CloudInit {
+		if err := waitForCloudInit(provisioner); err != nil {
+			return err
+		}
+	} else {
+		// HACK: since debian does not come with sudo by default we install
+		log.Debug("installing sudo")
+		if _, err := provisioner.SSHCommand("if ! type sudo; then apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y sudo; fi"); err != nil {
+			return err
+		}
 
-	log.Debug("installing base packages")
-	for _, pkg := range provisioner.Packages {
-		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
+		log.Debug("setting hostname")
+		if err := provisioner.SetHostname(provisioner.machineHostname()); err != nil {
 			return err
 		}
 	}
 
 	log.Debug("installing docker")
-	if err := installDockerGeneric(provisioner, engineOptions.InstallURL); err != nil {
+	if err := provisioner.installDocker(); err != nil {
 		return err
 	}
 
@@ -137,6 +178,10 @@ func (provisioner *DebianProvisioner) Provision(swarmOptions swarm.SwarmOptions,
 		return err
 	}
 
+	if err := prepareSystemdDropIn(provisioner); err != nil {
+		return err
+	}
+
 	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
 	log.Debug("configuring auth")
@@ -144,8 +189,18 @@ func (provisioner *DebianProvisioner) Provision(swarmOptions swarm.SwarmOptions,
 		return err
 	}
 
+	log.Debug("configuring kernel")
+	if err := configureKernel(provisioner, provisioner.EngineOptions); err != nil {
+		return err
+	}
+
+	log.Debug("configuring registries")
+	if err := configureRegistries(provisioner, provisioner.EngineOptions); err != nil {
+		return err
+	}
+
 	log.Debug("configuring swarm")
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions, provisioner.EngineOptions); err != nil {
 		return err
 	}
 
@@ -159,35 +214,13 @@ func (provisioner *DebianProvisioner) Provision(swarmOptions swarm.SwarmOptions,
 }
 
 func (provisioner *DebianProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
-	var (
-		engineCfg bytes.Buffer
-	)
-
 	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
 	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+	provisioner.EngineOptions.Env = noProxyMachine(provisioner.Driver, provisioner.EngineOptions.Env)
 
-	engineConfigTmpl := `[Service]
-ExecStart=/usr/bin/docker -d -H tcp://0.0.0.0:{{.DockerPort}} -H unix:///var/run/docker.sock --storage-driver {{.EngineOptions.StorageDriver}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}
-MountFlags=slave
-LimitNOFILE=1048576
-LimitNPROC=1048576
-LimitCORE=infinity
-`
-	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
-	if err != nil {
-		return nil, err
-	}
-
-	engineConfigContext := EngineConfigContext{
+	return generateSystemdDropIn("/usr/bin/docker -d", EngineConfigContext{
 		DockerPort:    dockerPort,
 		AuthOptions:   provisioner.AuthOptions,
 		EngineOptions: provisioner.EngineOptions,
-	}
-
-	t.Execute(&engineCfg, engineConfigContext)
-
-	return &DockerOptions{
-		EngineOptions:     engineCfg.String(),
-		EngineOptionsPath: provisioner.DaemonOptionsFile,
-	}, nil
+	})
 }
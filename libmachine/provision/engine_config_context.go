@@ -1,6 +1,9 @@
 package provision
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/engine"
 )
@@ -11,3 +14,104 @@ type EngineConfigContext struct {
 	EngineOptions    engine.EngineOptions
 	DockerOptionsDir string
 }
+
+var (
+	// shellEscape escapes a value for a single quoted shell string.
+	shellEscape = strings.NewReplacer(`'`, `'\''`).Replace
+
+	// systemdEscape escapes a value for a word of a systemd command line,
+	// where quotes, specifiers and variables are expanded.
+	systemdEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `'`, `\'`, `$`, `$$`, `%`, `%%`).Replace
+
+	// systemdQuotedEscape escapes a value for a single quoted systemd
+	// setting, where only specifiers are expanded.
+	systemdQuotedEscape = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `%`, `%%`).Replace
+)
+
+// escapeEngineOptions returns a copy of the engine options with the values
+// users pass to the daemon as is, such as labels and arbitrary flags,
+// escaped for the format of the config.  The environment is validated
+// instead, as it is also used in commands.
+func escapeEngineOptions(opts engine.EngineOptions, escape func(string) string) engine.EngineOptions {
+	escapeAll := func(values []string) []string {
+		var escaped []string
+		for _, v := range values {
+			escaped = append(escaped, escape(v))
+		}
+		return escaped
+	}
+
+	opts.ArbitraryFlags = escapeAll(opts.ArbitraryFlags)
+	opts.Dns = escapeAll(opts.Dns)
+	opts.ExecOpt = escapeAll(opts.ExecOpt)
+	opts.InsecureRegistry = escapeAll(opts.InsecureRegistry)
+	opts.Labels = escapeAll(opts.Labels)
+	opts.LogOpt = escapeAll(opts.LogOpt)
+	opts.RegistryMirror = escapeAll(opts.RegistryMirror)
+
+	return opts
+}
+
+// ShellEnv returns the engine environment quoted for the shell scripts
+// which start the daemon.
+func (c EngineConfigContext) ShellEnv() []string {
+	var env []string
+
+	for _, e := range c.EngineOptions.Env {
+		key, value := splitEnv(e)
+		env = append(env, fmt.Sprintf("%s='%s'", key, value))
+	}
+
+	return env
+}
+
+// EngineFlags returns the daemon flags for the engine options which have no
+// dedicated setting in any of the config formats, in the order they should
+// be passed to the daemon.
+func (c EngineConfigContext) EngineFlags() []string {
+	var flags []string
+
+	opts := c.EngineOptions
+
+	for _, dns := range opts.Dns {
+		flags = append(flags, "--dns "+dns)
+	}
+
+	if opts.GraphDir != "" {
+		flags = append(flags, "--graph "+opts.GraphDir)
+	}
+
+	if opts.Ipv6 {
+		flags = append(flags, "--ipv6")
+	}
+
+	if opts.LogLevel != "" {
+		flags = append(flags, "--log-level "+opts.LogLevel)
+	}
+
+	if opts.LogDriver != "" {
+		flags = append(flags, "--log-driver "+opts.LogDriver)
+	}
+
+	for _, opt := range opts.LogOpt {
+		flags = append(flags, "--log-opt "+opt)
+	}
+
+	if opts.SelinuxEnabled {
+		flags = append(flags, "--selinux-enabled")
+	}
+
+	if opts.ExecDriver != "" {
+		flags = append(flags, "--exec-driver "+opts.ExecDriver)
+	}
+
+	for _, opt := range opts.ExecOpt {
+		flags = append(flags, "--exec-opt "+opt)
+	}
+
+	for _, ulimit := range opts.Ulimits {
+		flags = append(flags, "--default-ulimit "+ulimit)
+	}
+
+	return flags
+}
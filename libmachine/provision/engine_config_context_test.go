@@ -0,0 +1,181 @@
+package provision
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+func newTestEngineOptions() engine.EngineOptions {
+	return engine.EngineOptions{
+		ArbitraryFlags:   []string{"bip=10.10.0.1/16"},
+		Dns:              []string{"8.8.8.8", "8.8.4.4"},
+		Env:              []string{"HTTP_PROXY=http://proxy.example.com:3128", "NO_PROXY=localhost"},
+		ExecDriver:       "native",
+		ExecOpt:          []string{"native.cgroupdriver=systemd"},
+		GraphDir:         "/mnt/docker",
+		Ipv6:             true,
+		InsecureRegistry: []string{"registry.example.com:5000"},
+		Labels:           []string{"env=test"},
+		LogDriver:        "syslog",
+		LogLevel:         "debug",
+		LogOpt:           []string{"syslog-facility=daemon"},
+		RegistryMirror:   []string{"https://mirror.example.com"},
+		SelinuxEnabled:   true,
+		StorageDriver:    "overlay",
+		Ulimits:          []string{"nofile=65536:65536"},
+	}
+}
+
+func newTestRemoteAuthOptions() auth.AuthOptions {
+	return auth.AuthOptions{
+		CaCertRemotePath:     "/etc/docker/ca.pem",
+		ServerCertRemotePath: "/etc/docker/server.pem",
+		ServerKeyRemotePath:  "/etc/docker/server-key.pem",
+	}
+}
+
+// assertGolden compares the generated config with the golden file, which is
+// rewritten instead when the tests are run with -update.
+func assertGolden(t *testing.T, name string, actual string) {
+	path := filepath.Join("testdata", name+".golden")
+
+	if *updateGolden {
+		if err := ioutil.WriteFile(path, []byte(actual), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(expected, []byte(actual)) {
+		t.Fatalf("%s: generated config does not match the golden file\nexpected:\n%s\nreceived:\n%s", name, expected, actual)
+	}
+}
+
+func TestEngineFlagsEmpty(t *testing.T) {
+	if flags := (EngineConfigContext{}).EngineFlags(); len(flags) != 0 {
+		t.Fatalf("expected no flags for the default options; received %v", flags)
+	}
+}
+
+func TestGenerateDockerOptionsGolden(t *testing.T) {
+	d := &fakedriver.FakeDriver{}
+
+	generic := NewUbuntuProvisioner(d).(*UbuntuProvisioner)
+	boot2docker := NewBoot2DockerProvisioner(d).(*Boot2DockerProvisioner)
+	rancher := NewRancherProvisioner(d).(*RancherProvisioner)
+	debian := NewDebianProvisioner(d).(*DebianProvisioner)
+	coreos := NewCoreOSProvisioner(d).(*CoreOSProvisioner)
+
+	cases := map[string]struct {
+		p             Provisioner
+		authOptions   *auth.AuthOptions
+		engineOptions *engine.EngineOptions
+	}{
+		"generic":     {generic, &generic.AuthOptions, &generic.EngineOptions},
+		"boot2docker": {boot2docker, &boot2docker.AuthOptions, &boot2docker.EngineOptions},
+		"rancheros":   {rancher, &rancher.AuthOptions, &rancher.EngineOptions},
+		"systemd":     {debian, &debian.AuthOptions, &debian.EngineOptions},
+		"coreos":      {coreos, &coreos.AuthOptions, &coreos.EngineOptions},
+	}
+
+	for name, c := range cases {
+		*c.authOptions = newTestRemoteAuthOptions()
+		*c.engineOptions = newTestEngineOptions()
+
+		opts, err := c.p.GenerateDockerOptions(2376)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertGolden(t, "engine-options-"+name, opts.EngineOptions)
+	}
+}
+
+func TestGenerateDockerOptionsQuoting(t *testing.T) {
+	d := &fakedriver.FakeDriver{}
+
+	generic := NewUbuntuProvisioner(d).(*UbuntuProvisioner)
+	debian := NewDebianProvisioner(d).(*DebianProvisioner)
+	coreos := NewCoreOSProvisioner(d).(*CoreOSProvisioner)
+
+	// systemd unescapes the flags of a command line and the values of
+	// single quoted settings, the shell does not within single quotes
+	cases := map[string]struct {
+		p             Provisioner
+		engineOptions *engine.EngineOptions
+		expected      []string
+	}{
+		"generic": {generic, &generic.EngineOptions, []string{
+			`--label note=it'\''s"$HOME"100%`,
+			`--dns-search='\''example.com'\''`,
+		}},
+		"systemd": {debian, &debian.EngineOptions, []string{
+			`--label note=it\'s\"$$HOME\"100%% `,
+			` --dns-search=\'example.com\' `,
+		}},
+		"coreos": {coreos, &coreos.EngineOptions, []string{
+			`--label note=it\'s"$HOME"100%% `,
+			` --dns-search=\'example.com\' `,
+		}},
+	}
+
+	for name, c := range cases {
+		*c.engineOptions = engine.EngineOptions{
+			Labels:         []string{`note=it's"$HOME"100%`},
+			ArbitraryFlags: []string{"dns-search='example.com'"},
+		}
+
+		opts, err := c.p.GenerateDockerOptions(2376)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, expected := range c.expected {
+			if !strings.Contains(opts.EngineOptions, expected) {
+				t.Fatalf("%s: expected %s in the options; received:\n%s", name, expected, opts.EngineOptions)
+			}
+		}
+	}
+}
+
+func TestGenerateDockerOptionsShellRoundTrip(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available")
+	}
+
+	generic := NewUbuntuProvisioner(&fakedriver.FakeDriver{}).(*UbuntuProvisioner)
+	generic.EngineOptions = engine.EngineOptions{
+		Labels: []string{`note=it's"$HOME"100%`},
+	}
+
+	opts, err := generic.GenerateDockerOptions(2376)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := exec.Command(sh, "-c", opts.EngineOptions+`printf '%s' "$DOCKER_OPTS"`).CombinedOutput()
+	if err != nil {
+		t.Fatalf("the options are not a valid shell script: %s\n%s", err, output)
+	}
+
+	if !strings.Contains(string(output), "\n--label note=it's\"$HOME\"100%\n") {
+		t.Fatalf("expected the label as is in DOCKER_OPTS; received:\n%s", output)
+	}
+}
@@ -8,4 +8,9 @@ var (
 	ErrDetectionFailed  = errors.New("OS type not recognized")
 	ErrSSHCommandFailed = errors.New("SSH command failure")
 	ErrNotImplemented   = errors.New("Runtime not implemented")
+
+	ErrEngineVersionUnsupported = errors.New("The engine version is tied to the OS release and cannot be chosen on this OS")
+	ErrCloudInitUnsupported     = errors.New("Provisioning with cloud-init is not supported on this OS")
+	ErrOfflineUnsupported       = errors.New("Provisioning without network access is not supported on this OS")
+	ErrRollbackUnsupported      = errors.New("The engine is upgraded with the OS and cannot be rolled back on this OS")
 )
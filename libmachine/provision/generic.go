@@ -9,6 +9,7 @@ import (
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/log"
 )
 
 type GenericProvisioner struct {
@@ -21,6 +22,13 @@ type GenericProvisioner struct {
 	AuthOptions       auth.AuthOptions
 	EngineOptions     engine.EngineOptions
 	SwarmOptions      swarm.SwarmOptions
+
+	// MachineHostname is the hostname of the machine, if it differs from
+	// its name.
+	MachineHostname string
+
+	// HostLog is the log of the provisioning, if any.
+	HostLog *log.HostLog
 }
 
 func (provisioner *GenericProvisioner) Hostname() (string, error) {
@@ -39,8 +47,8 @@ func (provisioner *GenericProvisioner) SetHostname(hostname string) error {
 	// ubuntu/debian use 127.0.1.1 for non "localhost" loopback hostnames: https://www.debian.org/doc/manuals/debian-reference/ch05.en.html#_the_hostname_resolution
 	if _, err := provisioner.SSHCommand(fmt.Sprintf(
 		"if grep -xq 127.0.1.1.* /etc/hosts; then sudo sed -i 's/^127.0.1.1.*/127.0.1.1 %s/g' /etc/hosts; else echo '127.0.1.1 %s' | sudo tee -a /etc/hosts; fi",
-		hostname,
-		hostname,
+		hostsNames(hostname),
+		hostsNames(hostname),
 	)); err != nil {
 		return err
 	}
@@ -52,8 +60,10 @@ func (provisioner *GenericProvisioner) GetDockerOptionsDir() string {
 	return provisioner.DockerOptionsDir
 }
 
+// SSHCommand runs a command on the machine with the proxy settings of the
+// engine environment, if any.
 func (provisioner *GenericProvisioner) SSHCommand(args string) (string, error) {
-	return drivers.RunSSHCommandFromDriver(provisioner.Driver, args)
+	return runSSHCommand(provisioner.Driver, provisioner.HostLog, proxyCommand(provisioner.EngineOptions.Env, args))
 }
 
 func (provisioner *GenericProvisioner) CompatibleWithHost() bool {
@@ -68,6 +78,22 @@ func (provisioner *GenericProvisioner) SetOsReleaseInfo(info *OsRelease) {
 	provisioner.OsReleaseInfo = info
 }
 
+func (provisioner *GenericProvisioner) SetMachineHostname(hostname string) {
+	provisioner.MachineHostname = hostname
+}
+
+func (provisioner *GenericProvisioner) SetHostLog(l *log.HostLog) {
+	provisioner.HostLog = l
+}
+
+func (provisioner *GenericProvisioner) GetHostLog() *log.HostLog {
+	return provisioner.HostLog
+}
+
+func (provisioner *GenericProvisioner) machineHostname() string {
+	return machineHostname(provisioner.Driver, provisioner.MachineHostname)
+}
+
 func (provisioner *GenericProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
 	var (
 		engineCfg bytes.Buffer
@@ -75,9 +101,11 @@ func (provisioner *GenericProvisioner) GenerateDockerOptions(dockerPort int) (*D
 
 	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
 	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+	provisioner.EngineOptions.Env = noProxyMachine(provisioner.Driver, provisioner.EngineOptions.Env)
 
 	engineConfigTmpl := `
-DOCKER_OPTS='
+{{ range .ShellEnv }}export {{.}}
+{{ end }}DOCKER_OPTS='
 -H tcp://0.0.0.0:{{.DockerPort}}
 -H unix:///var/run/docker.sock
 --storage-driver {{.EngineOptions.StorageDriver}}
@@ -88,6 +116,7 @@ DOCKER_OPTS='
 {{ range .EngineOptions.Labels }}--label {{.}}
 {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}}
 {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}}
+{{ end }}{{ range .EngineFlags }}{{.}}
 {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}}
 {{ end }}
 '
@@ -100,7 +129,7 @@ DOCKER_OPTS='
 	engineConfigContext := EngineConfigContext{
 		DockerPort:    dockerPort,
 		AuthOptions:   provisioner.AuthOptions,
-		EngineOptions: provisioner.EngineOptions,
+		EngineOptions: escapeEngineOptions(provisioner.EngineOptions, shellEscape),
 	}
 
 	t.Execute(&engineCfg, engineConfigContext)
@@ -0,0 +1,86 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/engine"
+)
+
+const (
+	sysctlFile        = "/etc/sysctl.d/99-docker-machine.conf"
+	kernelModulesFile = "/etc/modules-load.d/docker-machine.conf"
+)
+
+func splitSysctl(sysctl string) (string, string) {
+	parts := strings.SplitN(sysctl, "=", 2)
+	return parts[0], parts[1]
+}
+
+// kernelScript returns the shell script which loads the kernel modules and
+// sets the sysctls, for the distributions which run a script on boot.
+func kernelScript(engineOptions engine.EngineOptions) string {
+	script := "set -e\n"
+
+	for _, module := range engineOptions.KernelModules {
+		script += fmt.Sprintf("modprobe %s\n", module)
+	}
+
+	for _, sysctl := range engineOptions.Sysctl {
+		script += fmt.Sprintf("sysctl -w '%s'\n", shellEscape(sysctl))
+	}
+
+	return script
+}
+
+// applyKernelOptions loads the kernel modules and sets the sysctls right
+// away.
+func applyKernelOptions(p Provisioner, engineOptions engine.EngineOptions) error {
+	for _, module := range engineOptions.KernelModules {
+		if output, err := p.SSHCommand(fmt.Sprintf("sudo modprobe %s", module)); err != nil {
+			return fmt.Errorf("error loading the kernel module %s: %s", module, output)
+		}
+	}
+
+	for _, sysctl := range engineOptions.Sysctl {
+		if output, err := p.SSHCommand(fmt.Sprintf("sudo sysctl -w '%s'", shellEscape(sysctl))); err != nil {
+			return fmt.Errorf("error setting the sysctl %s: %s", sysctl, output)
+		}
+	}
+
+	return nil
+}
+
+// configureKernel loads the kernel modules and sets the sysctls of the
+// engine options, and writes them to modules-load.d and sysctl.d so that
+// they are set again on boot.  The files are removed when there are none,
+// so that the ones dropped since the last provisioning are not set on boot.
+func configureKernel(p Provisioner, engineOptions engine.EngineOptions) error {
+	defer p.GetHostLog().Stage("configure kernel")()
+
+	if len(engineOptions.KernelModules) > 0 {
+		modules := strings.Join(engineOptions.KernelModules, "\n") + "\n"
+
+		if err := WriteFile(p, kernelModulesFile, strings.NewReader(modules), 0644); err != nil {
+			return err
+		}
+	} else if err := removeFile(p, kernelModulesFile); err != nil {
+		return err
+	}
+
+	if len(engineOptions.Sysctl) > 0 {
+		conf := ""
+		for _, sysctl := range engineOptions.Sysctl {
+			name, value := splitSysctl(sysctl)
+			conf += fmt.Sprintf("%s = %s\n", name, value)
+		}
+
+		if err := WriteFile(p, sysctlFile, strings.NewReader(conf), 0644); err != nil {
+			return err
+		}
+	} else if err := removeFile(p, sysctlFile); err != nil {
+		return err
+	}
+
+	return applyKernelOptions(p, engineOptions)
+}
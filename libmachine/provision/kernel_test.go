@@ -0,0 +1,119 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/engine"
+)
+
+var testKernelOptions = engine.EngineOptions{
+	Sysctl:        []string{"vm.max_map_count=262144", "net.ipv4.ip_local_port_range=1024 65000"},
+	KernelModules: []string{"ip_vs"},
+}
+
+func TestConfigureKernel(t *testing.T) {
+	server, d := newTestServer(t, "ID=debian\n")
+	defer server.Close()
+
+	if err := configureKernel(NewDebianProvisioner(d), testKernelOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFileWritten(t, server, kernelModulesFile, "0644", `^ip_vs\n$`)
+	assertFileWritten(t, server, sysctlFile, "0644", `^vm.max_map_count = 262144\nnet.ipv4.ip_local_port_range = 1024 65000\n$`)
+
+	assertCommandSequence(t, server, []string{
+		`^sudo modprobe ip_vs$`,
+		`^sudo sysctl -w 'vm.max_map_count=262144'$`,
+		`^sudo sysctl -w 'net.ipv4.ip_local_port_range=1024 65000'$`,
+	})
+}
+
+func TestConfigureKernelNone(t *testing.T) {
+	server, d := newTestServer(t, "ID=debian\n")
+	defer server.Close()
+
+	if err := configureKernel(NewDebianProvisioner(d), engine.EngineOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^sudo rm -f '/etc/modules-load\.d/docker-machine\.conf'$`,
+		`^sudo rm -f '/etc/sysctl\.d/99-docker-machine\.conf'$`,
+	})
+
+	if found := server.Find(`modprobe|sysctl -w|install -m`); len(found) != 0 {
+		t.Fatalf("expected nothing to be set without kernel options; received:\n%s", server)
+	}
+}
+
+func TestBoot2DockerConfigureKernel(t *testing.T) {
+	server, d := newTestServer(t, "ID=boot2docker\n")
+	defer server.Close()
+
+	p := NewBoot2DockerProvisioner(d).(*Boot2DockerProvisioner)
+	p.EngineOptions = testKernelOptions
+
+	if err := p.configureKernel(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFileWritten(t, server, "/var/lib/boot2docker/machine-kernel.sh", "0755",
+		`^set -e\nmodprobe ip_vs\nsysctl -w 'vm.max_map_count=262144'\nsysctl -w 'net.ipv4.ip_local_port_range=1024 65000'\n$`)
+
+	assertCommandSequence(t, server, []string{
+		`grep -qxF 'sh /var/lib/boot2docker/machine-kernel.sh' /var/lib/boot2docker/bootsync.sh`,
+		`^sudo sh /var/lib/boot2docker/machine-kernel.sh$`,
+	})
+}
+
+func TestBoot2DockerConfigureKernelNone(t *testing.T) {
+	server, d := newTestServer(t, "ID=boot2docker\n")
+	defer server.Close()
+
+	p := NewBoot2DockerProvisioner(d).(*Boot2DockerProvisioner)
+
+	if err := p.configureKernel(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^if \[ -f /var/lib/boot2docker/bootsync\.sh \]; then sudo sed -i '\\#\^sh /var/lib/boot2docker/machine-kernel\\\.sh\$#d' /var/lib/boot2docker/bootsync\.sh; fi$`,
+		`^sudo rm -f '/var/lib/boot2docker/machine-kernel\.sh'$`,
+	})
+}
+
+func TestRancherConfigureKernel(t *testing.T) {
+	server, d := newTestServer(t, "ID=rancheros\n")
+	defer server.Close()
+
+	p := NewRancherProvisioner(d).(*RancherProvisioner)
+	p.EngineOptions = testKernelOptions
+
+	if err := p.configureKernel(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFileWritten(t, server, kernelFile, "0644",
+		`^#cloud-config\n\nrancher:\n  modules:\n    - ip_vs\n  sysctl:\n    "vm.max_map_count": "262144"\n    "net.ipv4.ip_local_port_range": "1024 65000"\n$`)
+
+	assertCommandSequence(t, server, []string{
+		`^sudo modprobe ip_vs$`,
+		`^sudo sysctl -w 'vm.max_map_count=262144'$`,
+	})
+}
+
+func TestRancherConfigureKernelNone(t *testing.T) {
+	server, d := newTestServer(t, "ID=rancheros\n")
+	defer server.Close()
+
+	p := NewRancherProvisioner(d).(*RancherProvisioner)
+
+	if err := p.configureKernel(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^sudo rm -f '/var/lib/rancher/conf/cloud-config\.d/machine-kernel\.yml'$`,
+	})
+}
@@ -0,0 +1,21 @@
+package provision
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/docker/machine/log"
+)
+
+// LoadImages streams an archive of images, as written by docker save, into
+// docker load on the machine, so that it is never written to its disk.  The
+// name of the archive is used in errors.
+func LoadImages(p Provisioner, name string, archive io.Reader) error {
+	log.Debugf("loading %s", name)
+
+	if output, err := runSSHCommandWithStdin(p.GetDriver(), p.GetHostLog(), "sudo docker load", archive); err != nil {
+		return fmt.Errorf("error loading %s: %s", name, output)
+	}
+
+	return nil
+}
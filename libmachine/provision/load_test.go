@@ -0,0 +1,35 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadImages(t *testing.T) {
+	server, d := newTestServer(t, "ID=debian\n")
+	defer server.Close()
+
+	if err := LoadImages(NewDebianProvisioner(d), "images.tar", strings.NewReader("archive")); err != nil {
+		t.Fatal(err)
+	}
+
+	if found := server.Find(`^sudo docker load$`); len(found) != 1 || string(found[0].Stdin) != "archive" {
+		t.Fatalf("expected the archive to be streamed into docker load; received:\n%s", server)
+	}
+
+	if found := server.Find(`^cat > |^mktemp `); len(found) != 0 {
+		t.Fatalf("expected no temporary file; received:\n%s", server)
+	}
+}
+
+func TestLoadImagesFailure(t *testing.T) {
+	server, d := newTestServer(t, "ID=debian\n")
+	defer server.Close()
+
+	server.Handle(`^sudo docker load$`, "open /var/lib/docker/tmp: no space left on device", 1)
+
+	err := LoadImages(NewDebianProvisioner(d), "images.tar", strings.NewReader("archive"))
+	if err == nil || !strings.Contains(err.Error(), "images.tar") || !strings.Contains(err.Error(), "no space left on device") {
+		t.Fatalf("expected the error of docker load; received %v", err)
+	}
+}
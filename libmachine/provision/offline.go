@@ -0,0 +1,186 @@
+package provision
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/log"
+	"github.com/docker/machine/utils"
+)
+
+const (
+	dockerDebPath = "https://apt.dockerproject.org/repo/pool/main/d/docker-engine/docker-engine_%[1]s-0~%[2]s_amd64.deb"
+)
+
+// offlineTargets maps the targets of the offline cache, i.e. the releases
+// engine packages are fetched for, to the URL of the package, which takes
+// the version.
+var offlineTargets = map[string]string{
+	"centos-7":      dockerCentosRPMPath,
+	"debian-jessie": fmt.Sprintf(dockerDebPath, "%[1]s", "jessie"),
+	"debian-wheezy": fmt.Sprintf(dockerDebPath, "%[1]s", "wheezy"),
+	"fedora-21":     dockerFedoraRPMPath,
+	"ubuntu-trusty": fmt.Sprintf(dockerDebPath, "%[1]s", "trusty"),
+	"ubuntu-vivid":  fmt.Sprintf(dockerDebPath, "%[1]s", "vivid"),
+	"ubuntu-wily":   fmt.Sprintf(dockerDebPath, "%[1]s", "wily"),
+}
+
+// offlineReleases maps the ID and VERSION_ID of the hosts to their target
+// in the offline cache.  Red Hat and CentOS use the same RPM, as they do
+// when online.
+var offlineReleases = map[string]string{
+	"centos 7":     "centos-7",
+	"debian 7":     "debian-wheezy",
+	"debian 8":     "debian-jessie",
+	"fedora":       "fedora-21",
+	"rhel 7":       "centos-7",
+	"ubuntu 14.04": "ubuntu-trusty",
+	"ubuntu 15.04": "ubuntu-vivid",
+	"ubuntu 15.10": "ubuntu-wily",
+}
+
+// GetOfflineTargets returns the targets engine packages can be cached for.
+func GetOfflineTargets() []string {
+	targets := []string{}
+	for target := range offlineTargets {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// GetOfflineCacheDir returns the directory of the cache used to provision
+// machines without network access.
+func GetOfflineCacheDir() string {
+	return filepath.Join(utils.GetMachineCacheDir(), "offline")
+}
+
+func offlinePackageFile(target, version string) string {
+	return fmt.Sprintf("docker-engine-%s-%s%s", version, target, path.Ext(offlineTargets[target]))
+}
+
+// GetImageArchive returns the path of the archive of the image in the
+// offline cache, as written by docker save.
+func GetImageArchive(image string) string {
+	name := strings.NewReplacer("/", "_", ":", "_").Replace(image)
+	return filepath.Join(GetOfflineCacheDir(), name+".tar")
+}
+
+// FetchOfflinePackage downloads the engine package of the given version for
+// the target into the offline cache.
+func FetchOfflinePackage(target, version string) error {
+	urlFmt, ok := offlineTargets[target]
+	if !ok {
+		return fmt.Errorf("unknown target %s, expected one of %s", target, strings.Join(GetOfflineTargets(), ", "))
+	}
+
+	cacheDir := GetOfflineCacheDir()
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf(urlFmt, version)
+	log.Infof("Downloading %s...", url)
+
+	return utils.NewB2dUtils("", "").DownloadISO(cacheDir, offlinePackageFile(target, version), url)
+}
+
+func offlineTarget(info *OsRelease) (string, error) {
+	if info != nil {
+		for _, key := range []string{info.Id + " " + info.VersionId, info.Id} {
+			if target, ok := offlineReleases[key]; ok {
+				return target, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("offline provisioning is not supported on this release of the OS")
+}
+
+// findOfflinePackage returns the path of the cached engine package for the
+// target, of the given version or else of the latest version cached.
+func findOfflinePackage(target, version string) (string, error) {
+	cacheDir := GetOfflineCacheDir()
+
+	if version != "" {
+		pkg := filepath.Join(cacheDir, offlinePackageFile(target, version))
+		if _, err := os.Stat(pkg); err != nil {
+			return "", fmt.Errorf("docker %s for %s is not in the offline cache, fetch it with the cache command: %s", version, target, err)
+		}
+		return pkg, nil
+	}
+
+	files, err := ioutil.ReadDir(cacheDir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	latest := ""
+	suffix := "-" + target + path.Ext(offlineTargets[target])
+	for _, f := range files {
+		name := f.Name()
+		if !strings.HasPrefix(name, "docker-engine-") || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+
+		v := strings.TrimSuffix(strings.TrimPrefix(name, "docker-engine-"), suffix)
+		if latest == "" || compareVersions(v, latest) > 0 {
+			latest = v
+		}
+	}
+
+	if latest == "" {
+		return "", fmt.Errorf("no docker package for %s in the offline cache, fetch one with the cache command", target)
+	}
+
+	return filepath.Join(cacheDir, offlinePackageFile(target, latest)), nil
+}
+
+// installOfflineDocker uploads the cached engine package for the release of
+// the machine and installs it with the given command, which takes the path
+// of the package.
+func installOfflineDocker(p Provisioner, info *OsRelease, version, installCommand string) error {
+	target, err := offlineTarget(info)
+	if err != nil {
+		return err
+	}
+
+	pkg, err := findOfflinePackage(target, version)
+	if err != nil {
+		return err
+	}
+
+	tmpPath, err := uploadLocalTempFile(p, pkg)
+	if err != nil {
+		return err
+	}
+	defer removeTempFile(p, tmpPath)
+
+	if output, err := p.SSHCommand(fmt.Sprintf(installCommand, shellEscape(tmpPath))); err != nil {
+		return fmt.Errorf("error installing %s: %s", filepath.Base(pkg), output)
+	}
+
+	return nil
+}
+
+// loadOfflineImage uploads the cached archive of the image to the machine
+// and loads it, in place of pulling it.
+func loadOfflineImage(p Provisioner, image string) error {
+	archive := GetImageArchive(image)
+	if _, err := os.Stat(archive); err != nil {
+		return fmt.Errorf("%s is not in the offline cache, fetch it with the cache command: %s", image, err)
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return LoadImages(p, filepath.Base(archive), f)
+}
@@ -0,0 +1,188 @@
+package provision
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+// newTestOfflineCache sets up a machine store in a temporary directory with
+// the given files in the offline cache.
+func newTestOfflineCache(t *testing.T, files map[string]string) string {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("MACHINE_STORAGE_PATH", tmpDir)
+
+	cacheDir := GetOfflineCacheDir()
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(cacheDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return tmpDir
+}
+
+func TestOfflineTarget(t *testing.T) {
+	cases := map[OsRelease]string{
+		OsRelease{Id: "ubuntu", VersionId: "14.04"}: "ubuntu-trusty",
+		OsRelease{Id: "debian", VersionId: "8"}:     "debian-jessie",
+		OsRelease{Id: "rhel", VersionId: "7"}:       "centos-7",
+		OsRelease{Id: "fedora", VersionId: "22"}:    "fedora-21",
+	}
+
+	for info, expected := range cases {
+		info := info
+		target, err := offlineTarget(&info)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if target != expected {
+			t.Fatalf("%s %s: expected %s; received %s", info.Id, info.VersionId, expected, target)
+		}
+	}
+
+	if _, err := offlineTarget(&OsRelease{Id: "ubuntu", VersionId: "12.04"}); err == nil {
+		t.Fatal("expected an error for a release without a cached package")
+	}
+}
+
+func TestFindOfflinePackage(t *testing.T) {
+	tmpDir := newTestOfflineCache(t, map[string]string{
+		"docker-engine-1.8.2-ubuntu-trusty.deb":  "",
+		"docker-engine-1.10.0-ubuntu-trusty.deb": "",
+		"docker-engine-1.9.1-ubuntu-trusty.deb":  "",
+		"docker-engine-1.11.0-debian-jessie.deb": "",
+	})
+	defer removeTestStore(tmpDir)
+
+	pkg, err := findOfflinePackage("ubuntu-trusty", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filepath.Base(pkg) != "docker-engine-1.10.0-ubuntu-trusty.deb" {
+		t.Fatalf("expected the latest package for the target; received %s", pkg)
+	}
+
+	pkg, err = findOfflinePackage("ubuntu-trusty", "1.8.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filepath.Base(pkg) != "docker-engine-1.8.2-ubuntu-trusty.deb" {
+		t.Fatalf("expected the package of the given version; received %s", pkg)
+	}
+
+	if _, err := findOfflinePackage("ubuntu-trusty", "1.7.0"); err == nil {
+		t.Fatal("expected an error for a version which is not cached")
+	}
+
+	if _, err := findOfflinePackage("centos-7", ""); err == nil {
+		t.Fatal("expected an error for a target without any package")
+	}
+}
+
+func TestGetImageArchive(t *testing.T) {
+	archive := GetImageArchive("registry.example.com:5000/swarm:1.0.0")
+	if filepath.Base(archive) != "registry.example.com_5000_swarm_1.0.0.tar" {
+		t.Fatalf("unexpected archive name: %s", archive)
+	}
+}
+
+func TestUbuntuOfflineProvision(t *testing.T) {
+	server, d := newTestServer(t, "ID=ubuntu\nVERSION_ID=\"14.04\"\n")
+	defer server.Close()
+
+	authOptions, tmpDir := newTestAuthOptions(t, "test")
+	defer removeTestStore(tmpDir)
+
+	// the auth options set the store, so the cache goes in the same one
+	cacheDir := GetOfflineCacheDir()
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range map[string]string{
+		"docker-engine-1.8.2-ubuntu-trusty.deb": "deb",
+		"swarm_latest.tar":                      "image",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(cacheDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p, err := DetectProvisioner(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	swarmOptions := swarm.SwarmOptions{
+		IsSwarm:   true,
+		Image:     "swarm:latest",
+		Host:      "tcp://0.0.0.0:3376",
+		Discovery: "token://test",
+	}
+
+	if err := p.Provision(swarmOptions, authOptions, engine.EngineOptions{Offline: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if found := server.Find(`get\.docker\.com|apt-get|docker pull`); len(found) != 0 {
+		t.Fatalf("expected no network access when offline; received:\n%s", server)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^cat > '/tmp/tmp.machine/docker-engine-1.8.2-ubuntu-trusty.deb'$`,
+		`^DEBIAN_FRONTEND=noninteractive sudo -E dpkg -i '/tmp/tmp.machine/docker-engine-1.8.2-ubuntu-trusty.deb'$`,
+		`^rm -rf '/tmp/tmp.machine'$`,
+		`^sudo docker load$`,
+		`^sudo docker run -d .*swarm-agent `,
+	})
+
+	if found := server.Find(`^cat > '/tmp/tmp.machine/docker-engine`); len(found) != 1 || string(found[0].Stdin) != "deb" {
+		t.Fatalf("expected the package to be uploaded; received:\n%s", server)
+	}
+
+	if found := server.Find(`^sudo docker load$`); len(found) != 1 || string(found[0].Stdin) != "image" {
+		t.Fatalf("expected the image to be loaded; received:\n%s", server)
+	}
+}
+
+func TestOfflineProvisionUnsupported(t *testing.T) {
+	for _, osRelease := range []string{
+		"NAME=\"Alpine Linux\"\nID=alpine\nVERSION_ID=3.2.3\n",
+		"NAME=\"SLES\"\nID=\"sles\"\nVERSION_ID=\"12.1\"\n",
+	} {
+		server, d := newTestServer(t, osRelease)
+		defer server.Close()
+
+		authOptions, tmpDir := newTestAuthOptions(t, "test")
+		defer removeTestStore(tmpDir)
+
+		p, err := DetectProvisioner(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := p.Provision(swarm.SwarmOptions{}, authOptions, engine.EngineOptions{Offline: true}); err != ErrOfflineUnsupported {
+			t.Fatalf("expected %T to reject offline provisioning; received %v", p, err)
+		}
+
+		if found := server.Find(`apk|zypper`); len(found) != 0 {
+			t.Fatalf("expected no package to be installed; received:\n%s", server)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package provision
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/docker/machine/log"
+)
+
+// splitPostProvisionFile splits a file to upload of the form local:remote.
+// The remote path is split at the last colon, so that local paths may be
+// Windows paths with a drive letter.
+func splitPostProvisionFile(file string) (string, string, error) {
+	i := strings.LastIndex(file, ":")
+	if i <= 0 || i == len(file)-1 || !path.IsAbs(file[i+1:]) {
+		return "", "", fmt.Errorf("invalid post-provision file %q, expected local:/remote/path", file)
+	}
+
+	return file[:i], file[i+1:], nil
+}
+
+// ValidatePostProvision checks that the files to upload and the scripts to
+// run after provisioning exist locally.
+func ValidatePostProvision(files, scripts []string) error {
+	for _, file := range files {
+		local, _, err := splitPostProvisionFile(file)
+		if err != nil {
+			return err
+		}
+
+		if _, err := os.Stat(local); err != nil {
+			return fmt.Errorf("invalid post-provision file: %s", err)
+		}
+	}
+
+	for _, script := range scripts {
+		if _, err := os.Stat(script); err != nil {
+			return fmt.Errorf("invalid post-provision script: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// PostProvision uploads the files and then runs the scripts, in the order
+// they are given, once the engine is configured.  Files are of the form
+// local:remote.  Scripts are run as root, and their output is written to
+// out.
+func PostProvision(p Provisioner, files, scripts []string, out io.Writer) error {
+	for _, file := range files {
+		local, remote, err := splitPostProvisionFile(file)
+		if err != nil {
+			return err
+		}
+
+		log.Debugf("uploading post-provision file %s to %s", local, remote)
+
+		if err := writeLocalFile(p, local, remote); err != nil {
+			return err
+		}
+	}
+
+	for _, script := range scripts {
+		log.Infof("Running post-provision script %s...", script)
+
+		tmpPath, err := uploadLocalTempFile(p, script)
+		if err != nil {
+			return err
+		}
+
+		output, err := p.SSHCommand(fmt.Sprintf("sudo -E sh '%s'", shellEscape(tmpPath)))
+		removeTempFile(p, tmpPath)
+
+		fmt.Fprintf(out, "==> %s\n%s", script, output)
+		if output != "" && !strings.HasSuffix(output, "\n") {
+			fmt.Fprintln(out)
+		}
+
+		if err != nil {
+			return fmt.Errorf("post-provision script %s failed: %s", script, err)
+		}
+	}
+
+	return nil
+}
+
+// writeLocalFile writes a local file to the path on the machine, keeping its
+// permissions.
+func writeLocalFile(p Provisioner, localPath, remotePath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	return WriteFile(p, remotePath, f, info.Mode())
+}
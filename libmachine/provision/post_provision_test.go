@@ -0,0 +1,114 @@
+package provision
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitPostProvisionFile(t *testing.T) {
+	cases := map[string][2]string{
+		"agent.conf:/etc/agent.conf":          {"agent.conf", "/etc/agent.conf"},
+		`C:\agent.conf:/etc/agent/agent.conf`: {`C:\agent.conf`, "/etc/agent/agent.conf"},
+	}
+
+	for file, expected := range cases {
+		local, remote, err := splitPostProvisionFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if local != expected[0] || remote != expected[1] {
+			t.Fatalf("%s: expected %v; received %s and %s", file, expected, local, remote)
+		}
+	}
+
+	for _, file := range []string{"agent.conf", "agent.conf:", ":/etc/agent.conf", "agent.conf:etc/agent.conf"} {
+		if _, _, err := splitPostProvisionFile(file); err == nil {
+			t.Fatalf("%s: expected an error", file)
+		}
+	}
+}
+
+func TestPostProvision(t *testing.T) {
+	server, d := newTestServer(t, "ID=debian\n")
+	defer server.Close()
+
+	server.Handle(`^sudo -E sh '/tmp/tmp.machine/first.sh'$`, "first\n", 0)
+	server.Handle(`^sudo -E sh '/tmp/tmp.machine/second.sh'$`, "second", 0)
+
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	files := map[string]string{
+		"agent.conf": "interval=10",
+		"first.sh":   "echo first",
+		"second.sh":  "echo second",
+	}
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scripts := []string{filepath.Join(tmpDir, "first.sh"), filepath.Join(tmpDir, "second.sh")}
+	if err := ValidatePostProvision([]string{filepath.Join(tmpDir, "agent.conf") + ":/etc/agent/agent.conf"}, scripts); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+
+	p := NewDebianProvisioner(d)
+	if err := PostProvision(p, []string{filepath.Join(tmpDir, "agent.conf") + ":/etc/agent/agent.conf"}, scripts, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^sudo mkdir -p '/etc/agent' && sudo install -m 0600 -o root -g root '/tmp/tmp.machine/agent.conf' '/etc/agent/agent.conf'$`,
+		`^sudo -E sh '/tmp/tmp.machine/first.sh'$`,
+		`^rm -rf '/tmp/tmp.machine'$`,
+		`^sudo -E sh '/tmp/tmp.machine/second.sh'$`,
+		`^rm -rf '/tmp/tmp.machine'$`,
+	})
+
+	assertFileWritten(t, server, "/etc/agent/agent.conf", "0600", `^interval=10$`)
+
+	if found := server.Find(`^cat > '/tmp/tmp.machine/second.sh'$`); len(found) != 1 || string(found[0].Stdin) != "echo second" {
+		t.Fatalf("expected the second script to be uploaded; received:\n%s", server)
+	}
+
+	expected := "==> " + scripts[0] + "\nfirst\n==> " + scripts[1] + "\nsecond\n"
+	if out.String() != expected {
+		t.Fatalf("expected the output of the scripts in order:\n%s\nreceived:\n%s", expected, out.String())
+	}
+}
+
+func TestPostProvisionScriptFailure(t *testing.T) {
+	server, d := newTestServer(t, "ID=debian\n")
+	defer server.Close()
+
+	server.Handle(`^sudo -E sh `, "boom", 1)
+
+	script, err := ioutil.TempFile("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script.Close()
+	defer os.Remove(script.Name())
+
+	var out bytes.Buffer
+
+	if err := PostProvision(NewDebianProvisioner(d), nil, []string{script.Name()}, &out); err == nil {
+		t.Fatal("expected an error when a script fails")
+	}
+
+	if !bytes.Contains(out.Bytes(), []byte("boom")) {
+		t.Fatalf("expected the output of the failed script; received %q", out.String())
+	}
+}
@@ -2,6 +2,9 @@ package provision
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/docker/machine/drivers"
 	"github.com/docker/machine/libmachine/auth"
@@ -27,12 +30,25 @@ type Provisioner interface {
 	// Run a package action e.g. install
 	Package(name string, action pkgaction.PackageAction) error
 
+	// Upgrade docker to the given version, or to the latest one if the
+	// version is empty.
+	UpgradeDocker(version string) error
+
+	// Roll docker back to the given version, the one running before an
+	// upgrade which failed.
+	RollbackDocker(version string) error
+
 	// Get Hostname
 	Hostname() (string, error)
 
 	// Set hostname
 	SetHostname(hostname string) error
 
+	// Set the extra public keys which may log in as the SSH user, and as
+	// the given user if not empty, which is created in the docker group.
+	// The keys replace the ones set before.
+	SetAuthorizedKeys(keys []string, user string) error
+
 	// Figure out if this is the right provisioner to use based on /etc/os-release info
 	CompatibleWithHost() bool
 
@@ -47,6 +63,9 @@ type Provisioner interface {
 	// Perform action on a named service e.g. stop
 	Service(name string, action pkgaction.ServiceAction) error
 
+	// Get the last lines logged by a named service, e.g. the daemon
+	ServiceLog(name string, lines int) (string, error)
+
 	// Get the driver which is contained in the provisioner.
 	GetDriver() drivers.Driver
 
@@ -56,18 +75,93 @@ type Provisioner interface {
 	// Set the OS Release info depending on how it's represented
 	// internally
 	SetOsReleaseInfo(info *OsRelease)
+
+	// Set the hostname, which may be fully qualified, to give the machine
+	// when it is provisioned instead of its name.
+	SetMachineHostname(hostname string)
+
+	// Set the log the stages and SSH commands of the provisioning are
+	// written to, or nil not to log them.
+	SetHostLog(l *log.HostLog)
+
+	// Get the log of the provisioning, which may be nil.
+	GetHostLog() *log.HostLog
 }
 
 // Detection
 type RegisteredProvisioner struct {
 	New func(d drivers.Driver) Provisioner
+
+	// Priority orders the provisioners during detection, higher ones are
+	// tried first and provisioners of equal priority are tried by name.
+	Priority int
+
+	// Versions restricts the VERSION_ID of hosts the provisioner is used
+	// for, e.g. ">=7" or ">=14.04,<16.04".  Empty matches every version.
+	Versions string
 }
 
 func Register(name string, p *RegisteredProvisioner) {
 	provisioners[name] = p
 }
 
-func DetectProvisioner(d drivers.Driver) (Provisioner, error) {
+type namedProvisioner struct {
+	Name string
+	*RegisteredProvisioner
+}
+
+type byPriority []namedProvisioner
+
+func (p byPriority) Len() int      { return len(p) }
+func (p byPriority) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byPriority) Less(i, j int) bool {
+	if p[i].Priority != p[j].Priority {
+		return p[i].Priority > p[j].Priority
+	}
+	return p[i].Name < p[j].Name
+}
+
+// orderedProvisioners returns the registered provisioners in the order in
+// which they are tried during detection.
+func orderedProvisioners() []namedProvisioner {
+	ordered := []namedProvisioner{}
+	for name, p := range provisioners {
+		ordered = append(ordered, namedProvisioner{name, p})
+	}
+
+	sort.Sort(byPriority(ordered))
+
+	return ordered
+}
+
+// GetProvisionerNames returns the names of the registered provisioners in
+// detection order.
+func GetProvisionerNames() []string {
+	names := []string{}
+	for _, p := range orderedProvisioners() {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func findProvisioner(name string) (*RegisteredProvisioner, error) {
+	for _, p := range orderedProvisioners() {
+		if strings.EqualFold(p.Name, name) {
+			return p.RegisteredProvisioner, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Unknown provisioner %q, available provisioners are: %s", name, strings.Join(GetProvisionerNames(), ", "))
+}
+
+// ValidateProvisionerName returns an error if no provisioner is registered
+// under the given name.
+func ValidateProvisionerName(name string) error {
+	_, err := findProvisioner(name)
+	return err
+}
+
+func getOsReleaseInfo(d drivers.Driver) (*OsRelease, error) {
 	osReleaseOut, err := drivers.RunSSHCommandFromDriver(d, "cat /etc/os-release")
 	if err != nil {
 		return nil, fmt.Errorf("Error getting SSH command: %s", err)
@@ -78,15 +172,141 @@ func DetectProvisioner(d drivers.Driver) (Provisioner, error) {
 		return nil, fmt.Errorf("Error parsing /etc/os-release file: %s", err)
 	}
 
-	for _, p := range provisioners {
+	return osReleaseInfo, nil
+}
+
+// NewProvisioner returns the provisioner registered under the given name,
+// regardless of whether it is compatible with the host.
+func NewProvisioner(name string, d drivers.Driver) (Provisioner, error) {
+	p, err := findProvisioner(name)
+	if err != nil {
+		return nil, err
+	}
+
+	osReleaseInfo, err := getOsReleaseInfo(d)
+	if err != nil {
+		log.Warnf("Unable to read the OS release information, using the %s provisioner anyway: %s", name, err)
+		osReleaseInfo = &OsRelease{}
+	}
+
+	provisioner := p.New(d)
+	provisioner.SetOsReleaseInfo(osReleaseInfo)
+
+	return provisioner, nil
+}
+
+func DetectProvisioner(d drivers.Driver) (Provisioner, error) {
+	osReleaseInfo, err := getOsReleaseInfo(d)
+	if err != nil {
+		return nil, err
+	}
+
+	return detectProvisioner(d, osReleaseInfo)
+}
+
+// detectProvisioner returns the first provisioner compatible with the ID
+// of the host.  Failing that, the provisioners are tried against each of
+// the distributions listed in ID_LIKE, so that derivatives are handled like
+// the distribution they are based on.
+func detectProvisioner(d drivers.Driver, osReleaseInfo *OsRelease) (Provisioner, error) {
+	ordered := orderedProvisioners()
+
+	for _, p := range ordered {
 		provisioner := p.New(d)
 		provisioner.SetOsReleaseInfo(osReleaseInfo)
 
 		if provisioner.CompatibleWithHost() {
+			if !versionInRange(osReleaseInfo.VersionId, p.Versions) {
+				log.Debugf("%s %s is outside of the versions supported by the %s provisioner: %s", osReleaseInfo.Id, osReleaseInfo.VersionId, p.Name, p.Versions)
+				continue
+			}
+
 			log.Debugf("found compatible host: %s", osReleaseInfo.Id)
 			return provisioner, nil
 		}
 	}
 
+	for _, like := range strings.Fields(osReleaseInfo.IdLike) {
+		// the version of a derivative says nothing about the version of
+		// the distribution it is based on, so ranges are not checked
+		likeInfo := *osReleaseInfo
+		likeInfo.Id = like
+
+		for _, p := range ordered {
+			provisioner := p.New(d)
+			provisioner.SetOsReleaseInfo(&likeInfo)
+
+			if provisioner.CompatibleWithHost() {
+				log.Infof("Using the %s provisioner for %s, which is like %s", p.Name, osReleaseInfo.Id, like)
+				provisioner.SetOsReleaseInfo(osReleaseInfo)
+				return provisioner, nil
+			}
+		}
+	}
+
 	return nil, ErrDetectionFailed
 }
+
+// versionInRange reports whether the version satisfies every comma
+// separated constraint in versions, e.g. ">=7,<8".
+func versionInRange(version, versions string) bool {
+	// rolling releases such as Debian testing have no VERSION_ID
+	if versions == "" || version == "" {
+		return true
+	}
+
+	for _, constraint := range strings.Split(versions, ",") {
+		constraint = strings.TrimSpace(constraint)
+
+		op := strings.TrimRight(constraint, "0123456789.v ")
+		cmp := compareVersions(version, strings.TrimSpace(constraint[len(op):]))
+
+		var ok bool
+		switch op {
+		case ">=":
+			ok = cmp >= 0
+		case ">":
+			ok = cmp > 0
+		case "<=":
+			ok = cmp <= 0
+		case "<":
+			ok = cmp < 0
+		case "=", "==", "":
+			ok = cmp == 0
+		default:
+			log.Debugf("Invalid version constraint %q", constraint)
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compareVersions compares dotted versions such as "14.04" component by
+// component, returning -1, 0 or 1.  Missing components count as zero.
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+
+	return 0
+}
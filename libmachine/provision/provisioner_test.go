@@ -0,0 +1,503 @@
+package provision
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/ssh/sshtest"
+	"github.com/docker/machine/utils"
+)
+
+func newTestServer(t *testing.T, osRelease string) (*sshtest.Server, *sshtest.Driver) {
+	server, err := sshtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.Handle(`^cat /etc/os-release$`, osRelease, 0)
+	server.Handle(`^mktemp -d$`, "/tmp/tmp.machine\n", 0)
+
+	return server, sshtest.NewDriver(server, "test")
+}
+
+// testStoragePath is the machine store the tests started with, which is
+// set back by removeTestStore.
+var testStoragePath = os.Getenv("MACHINE_STORAGE_PATH")
+
+// removeTestStore removes a machine store set up by newTestAuthOptions or
+// newTestOfflineCache, and points MACHINE_STORAGE_PATH back to the previous
+// one.
+func removeTestStore(tmpDir string) {
+	os.Setenv("MACHINE_STORAGE_PATH", testStoragePath)
+	os.RemoveAll(tmpDir)
+}
+
+// newTestAuthOptions sets up a machine store in a temporary directory with
+// the certificates ConfigureAuth expects.
+func newTestAuthOptions(t *testing.T, machineName string) (auth.AuthOptions, string) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("MACHINE_STORAGE_PATH", tmpDir)
+
+	certDir := utils.GetMachineCertDir()
+	machineDir := filepath.Join(utils.GetMachineDir(), machineName)
+
+	for _, dir := range []string{certDir, machineDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	authOptions := auth.AuthOptions{
+		CaCertPath:     filepath.Join(certDir, "ca.pem"),
+		PrivateKeyPath: filepath.Join(certDir, "ca-key.pem"),
+		ClientCertPath: filepath.Join(certDir, "cert.pem"),
+		ClientKeyPath:  filepath.Join(certDir, "key.pem"),
+		ServerCertPath: filepath.Join(machineDir, "server.pem"),
+		ServerKeyPath:  filepath.Join(machineDir, "server-key.pem"),
+	}
+
+	if err := utils.GenerateCACertificate(authOptions.CaCertPath, authOptions.PrivateKeyPath, "test", 1024); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := utils.GenerateCert([]string{""}, authOptions.ClientCertPath, authOptions.ClientKeyPath, authOptions.CaCertPath, authOptions.PrivateKeyPath, "test", 1024); err != nil {
+		t.Fatal(err)
+	}
+
+	return authOptions, tmpDir
+}
+
+// assertCommandSequence checks that commands matching the patterns were run
+// on the server in the given order, with any other commands in between.
+func assertCommandSequence(t *testing.T, server *sshtest.Server, patterns []string) {
+	commands := server.Commands()
+
+	i := 0
+	for _, pattern := range patterns {
+		re := regexp.MustCompile("(?s)" + pattern)
+		for i < len(commands) && !re.MatchString(commands[i].Command) {
+			i++
+		}
+
+		if i == len(commands) {
+			t.Fatalf("expected a command matching %s after the previous ones; received:\n%s", pattern, server)
+		}
+		i++
+	}
+}
+
+// assertFileWritten checks that a file was written to the remote path with
+// WriteFile, with the given permissions and content matching the pattern.
+func assertFileWritten(t *testing.T, server *sshtest.Server, remotePath string, perm string, pattern string) {
+	commands := server.Commands()
+	install := regexp.MustCompile(`install -m ` + perm + ` -o root -g root ('(?:[^']|'\\'')*') ` + regexp.QuoteMeta("'"+shellEscape(remotePath)+"'") + `$`)
+	content := regexp.MustCompile("(?s)" + pattern)
+
+	for i := len(commands) - 1; i >= 0; i-- {
+		m := install.FindStringSubmatch(commands[i].Command)
+		if m == nil {
+			continue
+		}
+
+		for j := i - 1; j >= 0; j-- {
+			if commands[j].Command != "cat > "+m[1] {
+				continue
+			}
+
+			if !content.Match(commands[j].Stdin) {
+				t.Fatalf("expected the content of %s to match %s; received:\n%s", remotePath, pattern, commands[j].Stdin)
+			}
+			return
+		}
+
+		t.Fatalf("expected %s to be uploaded before being installed to %s; received:\n%s", m[1], remotePath, server)
+	}
+
+	t.Fatalf("expected %s to be written with mode %s; received:\n%s", remotePath, perm, server)
+}
+
+func TestDetectProvisioner(t *testing.T) {
+	cases := map[string]string{
+		"ID=ubuntu\nVERSION_ID=\"14.04\"\n":   "*provision.UbuntuProvisioner",
+		"ID=debian\nVERSION_ID=\"8\"\n":       "*provision.DebianProvisioner",
+		"ID=centos\nVERSION_ID=\"7\"\n":       "*provision.CentosProvisioner",
+		"ID=fedora\nVERSION_ID=21\n":          "*provision.FedoraProvisioner",
+		"ID=\"rhel\"\nVERSION_ID=\"7.0\"\n":   "*provision.RedHatProvisioner",
+		"ID=boot2docker\n":                    "*provision.Boot2DockerProvisioner",
+		"ID=rancheros\nVERSION_ID=v0.3.3\n":   "*provision.RancherProvisioner",
+		"ID=alpine\nVERSION_ID=3.2.3\n":       "*provision.AlpineProvisioner",
+		"NAME=\"Linux\"\nID=unknown-distro\n": "",
+	}
+
+	for osRelease, expected := range cases {
+		server, d := newTestServer(t, osRelease)
+
+		p, err := DetectProvisioner(d)
+		server.Close()
+
+		if expected == "" {
+			if err != ErrDetectionFailed {
+				t.Fatalf("expected detection to fail for %q; received %v", osRelease, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%q: %s", osRelease, err)
+		}
+
+		if name := fmt.Sprintf("%T", p); name != expected {
+			t.Fatalf("expected %s for %q; received %s", expected, osRelease, name)
+		}
+	}
+}
+
+func TestUbuntuProvision(t *testing.T) {
+	server, d := newTestServer(t, "ID=ubuntu\nVERSION_ID=\"14.04\"\n")
+	defer server.Close()
+
+	authOptions, tmpDir := newTestAuthOptions(t, "test")
+	defer removeTestStore(tmpDir)
+
+	p, err := DetectProvisioner(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	swarmOptions := swarm.SwarmOptions{
+		IsSwarm:   true,
+		Master:    true,
+		Host:      "tcp://0.0.0.0:3376",
+		Image:     "swarm:latest",
+		Strategy:  "spread",
+		Discovery: "token://abc",
+	}
+
+	engineOptions := engine.EngineOptions{
+		InstallURL: "https://get.docker.com",
+	}
+
+	if err := p.Provision(swarmOptions, authOptions, engineOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`^sudo hostname test && echo "test" \| sudo tee /etc/hostname$`,
+		`apt-get install -y  curl$`,
+		`curl -sSL https://get.docker.com \| sh -`,
+		`^sudo service docker stop$`,
+		`install -m 0644 -o root -g root \S+ '/etc/docker/ca.pem'$`,
+		`install -m 0644 -o root -g root \S+ '/etc/docker/server.pem'$`,
+		`install -m 0600 -o root -g root \S+ '/etc/docker/server-key.pem'$`,
+		`install -m 0644 -o root -g root \S+ '/etc/default/docker'$`,
+		`^sudo service docker start$`,
+		`^sudo docker pull swarm:latest$`,
+		`--name swarm-agent-master`,
+		`join --addr 127.0.0.1:2376 token://abc`,
+	}
+
+	assertCommandSequence(t, server, expected)
+
+	assertFileWritten(t, server, "/etc/docker/ca.pem", "0644", `BEGIN CERTIFICATE`)
+	assertFileWritten(t, server, "/etc/docker/server-key.pem", "0600", `BEGIN RSA PRIVATE KEY`)
+	assertFileWritten(t, server, "/etc/default/docker", "0644", `DOCKER_OPTS='.*--tlsverify`)
+
+	for _, name := range []string{"ca.pem", "cert.pem", "key.pem"} {
+		if _, err := os.Stat(filepath.Join(utils.GetMachineDir(), "test", name)); err != nil {
+			t.Fatalf("expected %s in the machine directory: %s", name, err)
+		}
+	}
+}
+
+func TestConfigureAuthFailure(t *testing.T) {
+	server, d := newTestServer(t, "ID=ubuntu\n")
+	defer server.Close()
+
+	authOptions, tmpDir := newTestAuthOptions(t, "test")
+	defer removeTestStore(tmpDir)
+
+	server.Handle(`install -m 0644 -o root -g root \S+ '/etc/docker/server.pem'$`, "install: cannot create regular file '/etc/docker/server.pem': No space left on device\n", 1)
+
+	p, err := DetectProvisioner(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ubuntu := p.(*UbuntuProvisioner)
+	ubuntu.AuthOptions = authOptions
+	ubuntu.AuthOptions = setRemoteAuthOptions(ubuntu)
+
+	if err := ConfigureAuth(p); err == nil {
+		t.Fatal("expected error when uploading the server certificate fails")
+	}
+
+	if found := server.Find(`service docker start`); len(found) != 0 {
+		t.Fatalf("expected docker not to be started after a failure; received:\n%s", server)
+	}
+
+	if found := server.Find(`server-key.pem`); len(found) != 0 {
+		t.Fatalf("expected provisioning to stop at the failed upload; received:\n%s", server)
+	}
+
+	if commands := server.Commands(); commands[len(commands)-1].Command != "rm -rf '/tmp/tmp.machine'" {
+		t.Fatalf("expected the temporary directory to be removed after the failed upload; received:\n%s", server)
+	}
+}
+
+func TestUbuntuProvisionHostname(t *testing.T) {
+	server, d := newTestServer(t, "ID=ubuntu\n")
+	defer server.Close()
+
+	authOptions, tmpDir := newTestAuthOptions(t, "test")
+	defer removeTestStore(tmpDir)
+
+	authOptions.ServerCertSANs = []string{"web01.prod.example.com"}
+
+	p, err := DetectProvisioner(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.SetMachineHostname("web01.prod.example.com")
+
+	if err := p.Provision(swarm.SwarmOptions{}, authOptions, engine.EngineOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^sudo hostname web01\.prod\.example\.com && echo "web01\.prod\.example\.com" \| sudo tee /etc/hostname$`,
+		`sudo sed -i 's/\^127\.0\.1\.1\.\*/127\.0\.1\.1 web01\.prod\.example\.com web01/g' /etc/hosts; else echo '127\.0\.1\.1 web01\.prod\.example\.com web01' \| sudo tee -a /etc/hosts; fi$`,
+	})
+
+	data, err := ioutil.ReadFile(authOptions.ServerCertPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatal("expected a PEM server certificate")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cert.DNSNames) != 1 || cert.DNSNames[0] != "web01.prod.example.com" || len(cert.IPAddresses) != 1 {
+		t.Fatalf("expected the server certificate to name the IP and the hostname; received %v %v", cert.IPAddresses, cert.DNSNames)
+	}
+}
+
+func TestDetectProvisionerIdLike(t *testing.T) {
+	cases := map[string]string{
+		"ID=linuxmint\nID_LIKE=\"ubuntu debian\"\nVERSION_ID=\"17.1\"\n": "*provision.UbuntuProvisioner",
+		"ID=raspbian\nID_LIKE=debian\nVERSION_ID=\"8\"\n":                "*provision.DebianProvisioner",
+		"ID=\"ol\"\nID_LIKE=\"fedora\"\nVERSION_ID=\"7.1\"\n":            "*provision.FedoraProvisioner",
+	}
+
+	for osRelease, expected := range cases {
+		info, err := NewOsRelease([]byte(osRelease))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := detectProvisioner(&fakedriver.FakeDriver{}, info)
+		if err != nil {
+			t.Fatalf("%q: %s", osRelease, err)
+		}
+
+		if name := fmt.Sprintf("%T", p); name != expected {
+			t.Fatalf("expected %s for %q; received %s", expected, osRelease, name)
+		}
+	}
+}
+
+func TestDetectProvisionerVersions(t *testing.T) {
+	info, err := NewOsRelease([]byte("ID=centos\nVERSION_ID=\"6\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := detectProvisioner(&fakedriver.FakeDriver{}, info); err != ErrDetectionFailed {
+		t.Fatalf("expected detection to fail for CentOS 6; received %v", err)
+	}
+
+	info.VersionId = ""
+	if _, err := detectProvisioner(&fakedriver.FakeDriver{}, info); err != nil {
+		t.Fatalf("expected hosts without a version to be detected; received %v", err)
+	}
+}
+
+func TestDetectProvisionerPriority(t *testing.T) {
+	defer delete(provisioners, "test-ubuntu")
+
+	Register("test-ubuntu", &RegisteredProvisioner{
+		New: func(d drivers.Driver) Provisioner {
+			return &DebianProvisioner{
+				GenericProvisioner{
+					OsReleaseId: "ubuntu",
+					Driver:      d,
+				},
+			}
+		},
+		Priority: 10,
+	})
+
+	if names := GetProvisionerNames(); names[0] != "test-ubuntu" {
+		t.Fatalf("expected the provisioner with the highest priority first; received %v", names)
+	}
+
+	info, err := NewOsRelease([]byte("ID=ubuntu\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		p, err := detectProvisioner(&fakedriver.FakeDriver{}, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := p.(*DebianProvisioner); !ok {
+			t.Fatalf("expected the provisioner with the highest priority; received %T", p)
+		}
+	}
+}
+
+func TestNewProvisioner(t *testing.T) {
+	server, d := newTestServer(t, "ID=unknown-distro\nID_LIKE=nothing\n")
+	defer server.Close()
+
+	p, err := NewProvisioner("debian", d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := p.(*DebianProvisioner); !ok {
+		t.Fatalf("expected the debian provisioner; received %T", p)
+	}
+
+	if _, err := NewProvisioner("windows", d); err == nil {
+		t.Fatal("expected error for an unknown provisioner")
+	}
+
+	if err := ValidateProvisionerName("RancherOS"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVersionInRange(t *testing.T) {
+	cases := []struct {
+		version  string
+		versions string
+		expected bool
+	}{
+		{"7", ">=7", true},
+		{"7.1.1503", ">=7", true},
+		{"6.6", ">=7", false},
+		{"14.04", ">=14.04,<16.04", true},
+		{"16.04", ">=14.04,<16.04", false},
+		{"14.10", ">14.04", true},
+		{"21", "21", true},
+		{"v0.3.3", ">=v0.3", true},
+		{"8", "", true},
+		{"", ">=8", true},
+	}
+
+	for _, c := range cases {
+		if inRange := versionInRange(c.version, c.versions); inRange != c.expected {
+			t.Errorf("%q in %q: expected %v; received %v", c.version, c.versions, c.expected, inRange)
+		}
+	}
+}
+
+func TestUbuntuProvisionEngineVersion(t *testing.T) {
+	server, d := newTestServer(t, "ID=ubuntu\nVERSION_ID=\"14.04\"\n")
+	defer server.Close()
+
+	server.Handle(`^apt-cache madison docker-engine`, "1.8.2-0~trusty\n", 0)
+
+	authOptions, tmpDir := newTestAuthOptions(t, "test")
+	defer removeTestStore(tmpDir)
+
+	p, err := DetectProvisioner(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engineOptions := engine.EngineOptions{
+		InstallURL: "https://get.docker.com",
+		Version:    "1.8.2",
+	}
+
+	if err := p.Provision(swarm.SwarmOptions{}, authOptions, engineOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`curl -sSL https://get.docker.com \| sh -`,
+		`^sudo -E apt-get update$`,
+		`^apt-cache madison docker-engine \| awk 'index\(\$3, "1\.8\.2-"\) == 1 { print \$3; exit }'$`,
+		`^DEBIAN_FRONTEND=noninteractive sudo -E apt-get install -y --force-yes docker-engine=1\.8\.2-0~trusty$`,
+		`^sudo service docker stop$`,
+	})
+
+	server.Reset()
+
+	if err := p.UpgradeDocker(""); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^DEBIAN_FRONTEND=noninteractive sudo -E apt-get upgrade -y  docker-engine$`,
+	})
+}
+
+func TestUbuntuUpgradeDockerVersionNotFound(t *testing.T) {
+	server, d := newTestServer(t, "ID=ubuntu\nVERSION_ID=\"14.04\"\n")
+	defer server.Close()
+
+	p, err := DetectProvisioner(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = p.UpgradeDocker("1.99.0")
+	if err == nil || !strings.Contains(err.Error(), "docker 1.99.0 was not found") {
+		t.Fatalf("expected the missing version to be reported; received %v", err)
+	}
+
+	if found := server.Find(`apt-get install`); len(found) != 0 {
+		t.Fatalf("expected nothing to be installed; received %v", found)
+	}
+}
+
+func TestRedHatDockerRPMPath(t *testing.T) {
+	expected := map[string]string{
+		dockerRHELRPMPath:   "https://get.docker.com/rpm/1.8.2/centos-7/RPMS/x86_64/docker-engine-1.8.2-1.el7.centos.x86_64.rpm",
+		dockerFedoraRPMPath: "https://get.docker.com/rpm/1.8.2/fedora-21/RPMS/x86_64/docker-engine-1.8.2-1.fc21.x86_64.rpm",
+	}
+
+	for rpmPath, url := range expected {
+		if actual := fmt.Sprintf(rpmPath, "1.8.2"); actual != url {
+			t.Fatalf("expected %s; received %s", url, actual)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/log"
+)
+
+// proxyVars are the environment variables, in either case, which configure
+// a proxy for the daemon, the install script and the package managers.
+var proxyVars = []string{"http_proxy", "https_proxy", "no_proxy"}
+
+func splitEnv(env string) (string, string) {
+	parts := strings.SplitN(env, "=", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+func isProxyVar(key string) bool {
+	for _, v := range proxyVars {
+		if strings.ToLower(key) == v {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyEnv returns the proxy settings found in the engine environment.
+// Each is set in both cases, since tools disagree on which one they read.
+func proxyEnv(env []string) []string {
+	var (
+		proxyEnv []string
+		seen     = map[string]bool{}
+	)
+
+	for _, e := range env {
+		key, value := splitEnv(e)
+		if !isProxyVar(key) || seen[strings.ToLower(key)] {
+			continue
+		}
+		seen[strings.ToLower(key)] = true
+
+		for _, k := range []string{strings.ToLower(key), strings.ToUpper(key)} {
+			proxyEnv = append(proxyEnv, fmt.Sprintf("%s='%s'", k, value))
+		}
+	}
+
+	return proxyEnv
+}
+
+// proxyCommand prefixes the command with the proxy settings of the engine
+// environment.  Commands run with sudo need -E to keep them.
+func proxyCommand(env []string, command string) string {
+	proxyEnv := proxyEnv(env)
+	if len(proxyEnv) == 0 {
+		return command
+	}
+
+	return fmt.Sprintf("export %s; %s", strings.Join(proxyEnv, " "), command)
+}
+
+// noProxyMachine adds the IP of the machine to NO_PROXY when a proxy is set,
+// so that the daemon does not go through the proxy to reach itself.
+func noProxyMachine(d drivers.Driver, env []string) []string {
+	var (
+		hasProxy  bool
+		noProxies []int
+	)
+
+	for i, e := range env {
+		key, _ := splitEnv(e)
+		switch strings.ToLower(key) {
+		case "http_proxy", "https_proxy":
+			hasProxy = true
+		case "no_proxy":
+			noProxies = append(noProxies, i)
+		}
+	}
+
+	if !hasProxy {
+		return env
+	}
+
+	ip, err := d.GetIP()
+	if err != nil {
+		log.Warnf("Unable to add the machine IP to NO_PROXY: %s", err)
+		return env
+	}
+
+	if ip == "" {
+		return env
+	}
+
+	newEnv := append([]string{}, env...)
+
+	if len(noProxies) == 0 {
+		return append(newEnv, "NO_PROXY="+ip)
+	}
+
+	for _, i := range noProxies {
+		key, value := splitEnv(newEnv[i])
+
+		hosts := strings.Split(value, ",")
+		found := false
+		for _, host := range hosts {
+			if strings.TrimSpace(host) == ip {
+				found = true
+			}
+		}
+
+		if found {
+			continue
+		}
+
+		if value == "" {
+			newEnv[i] = key + "=" + ip
+		} else {
+			newEnv[i] = key + "=" + value + "," + ip
+		}
+	}
+
+	return newEnv
+}
@@ -0,0 +1,113 @@
+package provision
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+func TestProxyCommand(t *testing.T) {
+	if command := proxyCommand([]string{"DEBUG=1"}, "sudo docker version"); command != "sudo docker version" {
+		t.Fatalf("expected the command to be unchanged without a proxy; received %s", command)
+	}
+
+	env := []string{"DEBUG=1", "HTTP_PROXY=http://proxy:3128", "no_proxy=localhost"}
+	expected := "export http_proxy='http://proxy:3128' HTTP_PROXY='http://proxy:3128' no_proxy='localhost' NO_PROXY='localhost'; sudo -E apt-get update"
+
+	if command := proxyCommand(env, "sudo -E apt-get update"); command != expected {
+		t.Fatalf("expected %s; received %s", expected, command)
+	}
+}
+
+func TestNoProxyMachine(t *testing.T) {
+	cases := []struct {
+		env      []string
+		expected []string
+	}{
+		{
+			[]string{"DEBUG=1"},
+			[]string{"DEBUG=1"},
+		},
+		{
+			[]string{"HTTP_PROXY=http://proxy:3128"},
+			[]string{"HTTP_PROXY=http://proxy:3128", "NO_PROXY=1.2.3.4"},
+		},
+		{
+			[]string{"https_proxy=http://proxy:3128", "no_proxy=localhost", "NO_PROXY="},
+			[]string{"https_proxy=http://proxy:3128", "no_proxy=localhost,1.2.3.4", "NO_PROXY=1.2.3.4"},
+		},
+		{
+			[]string{"HTTP_PROXY=http://proxy:3128", "NO_PROXY=localhost, 1.2.3.4"},
+			[]string{"HTTP_PROXY=http://proxy:3128", "NO_PROXY=localhost, 1.2.3.4"},
+		},
+	}
+
+	for _, c := range cases {
+		if env := noProxyMachine(&fakedriver.FakeDriver{}, c.env); !reflect.DeepEqual(env, c.expected) {
+			t.Errorf("%v: expected %v; received %v", c.env, c.expected, env)
+		}
+	}
+}
+
+func TestUbuntuProvisionBehindProxy(t *testing.T) {
+	server, d := newTestServer(t, "ID=ubuntu\nVERSION_ID=\"14.04\"\n")
+	defer server.Close()
+
+	authOptions, tmpDir := newTestAuthOptions(t, "test")
+	defer removeTestStore(tmpDir)
+
+	p, err := DetectProvisioner(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engineOptions := engine.EngineOptions{
+		Env:        []string{"HTTP_PROXY=http://proxy:3128"},
+		InstallURL: "https://get.docker.com",
+	}
+
+	if err := p.Provision(swarm.SwarmOptions{}, authOptions, engineOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^export http_proxy='http://proxy:3128' HTTP_PROXY='http://proxy:3128'; DEBIAN_FRONTEND=noninteractive sudo -E apt-get install -y  curl$`,
+		`^export http_proxy='http://proxy:3128' HTTP_PROXY='http://proxy:3128'; if ! type docker; then curl -sSL https://get.docker.com \| sh -; fi$`,
+		`install -m 0644 -o root -g root \S+ '/etc/default/docker'$`,
+	})
+}
+
+func TestDebianProvisionBehindProxy(t *testing.T) {
+	server, d := newTestServer(t, "ID=debian\nVERSION_ID=\"8\"\n")
+	defer server.Close()
+
+	authOptions, tmpDir := newTestAuthOptions(t, "test")
+	defer removeTestStore(tmpDir)
+
+	p, err := DetectProvisioner(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := p.(*DebianProvisioner); !ok {
+		t.Fatalf("expected the debian provisioner; received %T", p)
+	}
+
+	engineOptions := engine.EngineOptions{
+		Env:        []string{"HTTP_PROXY=http://proxy:3128"},
+		InstallURL: "https://get.docker.com",
+	}
+
+	if err := p.Provision(swarm.SwarmOptions{}, authOptions, engineOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^export http_proxy='http://proxy:3128' HTTP_PROXY='http://proxy:3128'; sudo -E apt-get update$`,
+		`^export http_proxy='http://proxy:3128' HTTP_PROXY='http://proxy:3128'; DEBIAN_FRONTEND=noninteractive sudo -E apt-get install -y  curl$`,
+		`^export http_proxy='http://proxy:3128' HTTP_PROXY='http://proxy:3128'; if ! type docker; then curl -sSL https://get.docker.com \| sh -; fi$`,
+	})
+}
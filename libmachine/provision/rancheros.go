@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/docker/machine/drivers"
@@ -17,14 +18,14 @@ import (
 )
 
 const (
-	versionsUrl  = "http://releases.rancher.com/os/versions.yml"
-	isoUrl       = "https://github.com/rancherio/os/releases/download/%s/machine-rancheros.iso"
-	hostnameTmpl = `sudo mkdir -p /var/lib/rancher/conf/cloud-config.d/  
-sudo tee /var/lib/rancher/conf/cloud-config.d/machine-hostname.yml << EOF
-#cloud-config
+	versionsUrl        = "http://releases.rancher.com/os/versions.yml"
+	isoUrl             = "https://github.com/rancherio/os/releases/download/%s/machine-rancheros.iso"
+	hostnameFile       = "/var/lib/rancher/conf/cloud-config.d/machine-hostname.yml"
+	authorizedKeysFile = "/var/lib/rancher/conf/cloud-config.d/machine-authorized-keys.yml"
+	kernelFile         = "/var/lib/rancher/conf/cloud-config.d/machine-kernel.yml"
+	hostnameTmpl       = `#cloud-config
 
 hostname: %s
-EOF
 `
 )
 
@@ -59,6 +60,11 @@ func (provisioner *RancherProvisioner) Service(name string, action pkgaction.Ser
 	return nil
 }
 
+// ServiceLog returns the logs of the system container running the service.
+func (provisioner *RancherProvisioner) ServiceLog(name string, lines int) (string, error) {
+	return provisioner.SSHCommand(fmt.Sprintf("sudo system-docker logs --tail %d %s", lines, name))
+}
+
 func (provisioner *RancherProvisioner) Package(name string, action pkgaction.PackageAction) error {
 	var packageAction string
 
@@ -85,19 +91,41 @@ func (provisioner *RancherProvisioner) Package(name string, action pkgaction.Pac
 	return nil
 }
 
+// UpgradeDocker upgrades RancherOS, which ships a single version of docker
+// per release.
+func (provisioner *RancherProvisioner) UpgradeDocker(version string) error {
+	if version != "" {
+		return ErrEngineVersionUnsupported
+	}
+
+	return provisioner.upgrade()
+}
+
+func (provisioner *RancherProvisioner) RollbackDocker(version string) error {
+	return ErrRollbackUnsupported
+}
+
 func (provisioner *RancherProvisioner) Provision(swarmOptions swarm.SwarmOptions, authOptions auth.AuthOptions, engineOptions engine.EngineOptions) error {
 	provisioner.SwarmOptions = swarmOptions
 	provisioner.AuthOptions = authOptions
 	provisioner.EngineOptions = engineOptions
 
+	if engineOptions.CloudInit {
+		return ErrCloudInitUnsupported
+	}
+
 	if provisioner.EngineOptions.StorageDriver == "" {
 		provisioner.EngineOptions.StorageDriver = "overlay"
 	} else if provisioner.EngineOptions.StorageDriver != "overlay" {
 		return fmt.Errorf("Unsupported storage driver: %s", provisioner.EngineOptions.StorageDriver)
 	}
 
-	log.Debugf("Setting hostname %s", provisioner.Driver.GetMachineName())
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+	if provisioner.EngineOptions.Version != "" {
+		return ErrEngineVersionUnsupported
+	}
+
+	log.Debugf("Setting hostname %s", provisioner.machineHostname())
+	if err := provisioner.SetHostname(provisioner.machineHostname()); err != nil {
 		return err
 	}
 
@@ -116,8 +144,18 @@ func (provisioner *RancherProvisioner) Provision(swarmOptions swarm.SwarmOptions
 		return err
 	}
 
+	log.Debugf("Configuring the kernel")
+	if err := provisioner.configureKernel(); err != nil {
+		return err
+	}
+
+	log.Debugf("Configuring registries")
+	if err := configureRegistries(provisioner, provisioner.EngineOptions); err != nil {
+		return err
+	}
+
 	log.Debugf("Configuring swarm")
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions, provisioner.EngineOptions); err != nil {
 		return err
 	}
 
@@ -134,13 +172,29 @@ func (provisioner *RancherProvisioner) SetHostname(hostname string) error {
 		return err
 	}
 
-	if _, err := provisioner.SSHCommand(fmt.Sprintf(hostnameTmpl, hostname)); err != nil {
+	if err := WriteFile(provisioner, hostnameFile, strings.NewReader(fmt.Sprintf(hostnameTmpl, hostname)), 0644); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// SetAuthorizedKeys keeps the keys in authorized_keys only: RancherOS adds
+// the ssh_authorized_keys of cloud-config on every boot, so a key removed
+// from there would come back.  The cloud-config file written by earlier
+// versions is removed.
+func (provisioner *RancherProvisioner) SetAuthorizedKeys(keys []string, user string) error {
+	if user != "" {
+		return ErrExtraUserUnsupported
+	}
+
+	if _, err := provisioner.SSHCommand(fmt.Sprintf("sudo rm -f %s", authorizedKeysFile)); err != nil {
+		return err
+	}
+
+	return setAuthorizedKeys(provisioner, keys, user)
+}
+
 func (provisioner *RancherProvisioner) upgrade() error {
 	switch provisioner.Driver.DriverName() {
 	case "virtualbox":
@@ -220,3 +274,38 @@ func (provisioner *RancherProvisioner) getLatestISOURL() (string, error) {
 
 	return "", fmt.Errorf("Failed to find current version")
 }
+
+// configureKernel writes the kernel modules and the sysctls to a
+// cloud-config file, which RancherOS applies on boot, and sets them right
+// away.  The cloud-config file is removed when there are none.
+func (provisioner *RancherProvisioner) configureKernel() error {
+	defer provisioner.GetHostLog().Stage("configure kernel")()
+
+	if len(provisioner.EngineOptions.Sysctl)+len(provisioner.EngineOptions.KernelModules) == 0 {
+		return removeFile(provisioner, kernelFile)
+	}
+
+	config := "#cloud-config\n\nrancher:\n"
+
+	if len(provisioner.EngineOptions.KernelModules) > 0 {
+		config += "  modules:\n"
+		for _, module := range provisioner.EngineOptions.KernelModules {
+			config += fmt.Sprintf("    - %s\n", module)
+		}
+	}
+
+	if len(provisioner.EngineOptions.Sysctl) > 0 {
+		config += "  sysctl:\n"
+		for _, sysctl := range provisioner.EngineOptions.Sysctl {
+			name, value := splitSysctl(sysctl)
+			// double quoted YAML strings are JSON strings
+			config += fmt.Sprintf("    %s: %s\n", strconv.Quote(name), strconv.Quote(value))
+		}
+	}
+
+	if err := WriteFile(provisioner, kernelFile, strings.NewReader(config), 0644); err != nil {
+		return err
+	}
+
+	return applyKernelOptions(provisioner, provisioner.EngineOptions)
+}
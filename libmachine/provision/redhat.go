@@ -1,9 +1,8 @@
 package provision
 
 import (
-	"bytes"
 	"fmt"
-	"text/template"
+	"time"
 
 	"github.com/docker/machine/drivers"
 	"github.com/docker/machine/libmachine/auth"
@@ -18,13 +17,16 @@ import (
 const (
 	// TODO: eventually the RPM install process will be integrated
 	// into the get.docker.com install script; for now
-	// we install via vendored RPMs
-	dockerRHELRPMPath = "https://get.docker.com/rpm/1.7.0/centos-7/RPMS/x86_64/docker-engine-1.7.0-1.el7.centos.x86_64.rpm"
+	// we install via vendored RPMs, the URLs take the version
+	dockerRPMVersion  = "1.7.0"
+	dockerRHELRPMPath = "https://get.docker.com/rpm/%[1]s/centos-7/RPMS/x86_64/docker-engine-%[1]s-1.el7.centos.x86_64.rpm"
 )
 
 func init() {
 	Register("RedHat", &RegisteredProvisioner{
 		New: NewRedHatProvisioner,
+		// older releases do not use systemd
+		Versions: ">=7",
 	})
 }
 
@@ -32,7 +34,7 @@ func NewRedHatProvisioner(d drivers.Driver) Provisioner {
 	return &RedHatProvisioner{
 		GenericProvisioner: GenericProvisioner{
 			DockerOptionsDir:  "/etc/docker",
-			DaemonOptionsFile: "/etc/systemd/system/docker.service",
+			DaemonOptionsFile: systemdDropInFile,
 			OsReleaseId:       "rhel",
 			Packages: []string{
 				"curl",
@@ -45,15 +47,22 @@ func NewRedHatProvisioner(d drivers.Driver) Provisioner {
 
 type RedHatProvisioner struct {
 	GenericProvisioner
+
+	// DockerRPMPath is the URL of the docker RPM, with %[1]s in place of
+	// the version.
 	DockerRPMPath string
 }
 
 func (provisioner *RedHatProvisioner) SSHCommand(args string) (string, error) {
+	args = proxyCommand(provisioner.EngineOptions.Env, args)
+
 	client, err := drivers.GetSSHClientFromDriver(provisioner.Driver)
 	if err != nil {
 		return "", err
 	}
 
+	start := time.Now()
+
 	// redhat needs "-t" for tty allocation on ssh therefore we check for the
 	// external client and add as needed
 	switch c := client.(type) {
@@ -61,10 +70,12 @@ func (provisioner *RedHatProvisioner) SSHCommand(args string) (string, error) {
 		c.BaseArgs = append(c.BaseArgs, "-t")
 		client = c
 	case ssh.NativeClient:
-		return c.OutputWithPty(args)
+		output, err := c.OutputWithPty(args)
+		return logCommand(provisioner.HostLog, args, start, output, err)
 	}
 
-	return client.Output(args)
+	output, err := client.Output(args)
+	return logCommand(provisioner.HostLog, args, start, output, err)
 }
 
 func (provisioner *RedHatProvisioner) SetHostname(hostname string) error {
@@ -81,8 +92,8 @@ func (provisioner *RedHatProvisioner) SetHostname(hostname string) error {
 	// ubuntu/debian use 127.0.1.1 for non "localhost" loopback hostnames: https://www.debian.org/doc/manuals/debian-reference/ch05.en.html#_the_hostname_resolution
 	if _, err := provisioner.SSHCommand(fmt.Sprintf(
 		"if grep -xq 127.0.1.1.* /etc/hosts; then sudo sed -i 's/^127.0.1.1.*/127.0.1.1 %s/g' /etc/hosts; else echo '127.0.1.1 %s' | sudo tee -a /etc/hosts; fi",
-		hostname,
-		hostname,
+		hostsNames(hostname),
+		hostsNames(hostname),
 	)); err != nil {
 		return err
 	}
@@ -90,29 +101,18 @@ func (provisioner *RedHatProvisioner) SetHostname(hostname string) error {
 	return nil
 }
 
-func (provisioner *RedHatProvisioner) Service(name string, action pkgaction.ServiceAction) error {
-	reloadDaemon := false
-	switch action {
-	case pkgaction.Start, pkgaction.Restart:
-		reloadDaemon = true
-	}
-
-	// systemd needs reloaded when config changes on disk; we cannot
-	// be sure exactly when it changes from the provisioner so
-	// we call a reload on every restart to be safe
-	if reloadDaemon {
-		if _, err := provisioner.SSHCommand("sudo systemctl daemon-reload"); err != nil {
-			return err
-		}
-	}
-
-	command := fmt.Sprintf("sudo systemctl %s %s", action.String(), name)
+func (provisioner *RedHatProvisioner) SetAuthorizedKeys(keys []string, user string) error {
+	// as for SetHostname, the RedHat provisioner SSHCommand is needed for
+	// the tty allocation
+	return setAuthorizedKeys(provisioner, keys, user)
+}
 
-	if _, err := provisioner.SSHCommand(command); err != nil {
-		return err
-	}
+func (provisioner *RedHatProvisioner) Service(name string, action pkgaction.ServiceAction) error {
+	return systemdService(provisioner, name, action)
+}
 
-	return nil
+func (provisioner *RedHatProvisioner) ServiceLog(name string, lines int) (string, error) {
+	return systemdServiceLog(provisioner, name, lines)
 }
 
 func (provisioner *RedHatProvisioner) Package(name string, action pkgaction.PackageAction) error {
@@ -155,8 +155,49 @@ func installDocker(provisioner *RedHatProvisioner) error {
 func (provisioner *RedHatProvisioner) installOfficialDocker() error {
 	log.Debug("installing docker")
 
-	if _, err := provisioner.SSHCommand(fmt.Sprintf("sudo yum install -y --nogpgcheck  %s", provisioner.DockerRPMPath)); err != nil {
-		return err
+	if provisioner.EngineOptions.Offline {
+		return installOfflineDocker(provisioner, provisioner.OsReleaseInfo, provisioner.EngineOptions.Version, "sudo -E yum localinstall -y --nogpgcheck '%s'")
+	}
+
+	// cloud-init ran the install script, only a pinned version is left
+	if provisioner.EngineOptions.CloudInit {
+		if provisioner.EngineOptions.Version == "" {
+			return nil
+		}
+		return provisioner.installDockerRPM(provisioner.EngineOptions.Version)
+	}
+
+	version := provisioner.EngineOptions.Version
+	if version == "" {
+		version = dockerRPMVersion
+	}
+
+	return provisioner.installDockerRPM(version)
+}
+
+func (provisioner *RedHatProvisioner) installDockerRPM(version string) error {
+	rpmPath := fmt.Sprintf(provisioner.DockerRPMPath, version)
+
+	if output, err := provisioner.SSHCommand(fmt.Sprintf("sudo -E yum install -y --nogpgcheck  %s", rpmPath)); err != nil {
+		return fmt.Errorf("error installing docker %s: %s", version, output)
+	}
+
+	return nil
+}
+
+func (provisioner *RedHatProvisioner) UpgradeDocker(version string) error {
+	if version == "" {
+		return provisioner.Package("docker-engine", pkgaction.Upgrade)
+	}
+
+	return provisioner.installDockerRPM(version)
+}
+
+func (provisioner *RedHatProvisioner) RollbackDocker(version string) error {
+	rpmPath := fmt.Sprintf(provisioner.DockerRPMPath, version)
+
+	if output, err := provisioner.SSHCommand(fmt.Sprintf("sudo -E yum downgrade -y --nogpgcheck %s", rpmPath)); err != nil {
+		return fmt.Errorf("error rolling docker back to %s: %s", version, output)
 	}
 
 	return nil
@@ -182,20 +223,29 @@ func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.SwarmOptions,
 		provisioner.EngineOptions.StorageDriver = "devicemapper"
 	}
 
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
+	// cloud-init set the hostname and installed docker on the first boot
+	if engineOptions.CloudInit {
+		if err := waitForCloudInit(provisioner); err != nil {
+			return err
+		}
+	} else if err := provisioner.SetHostname(provisioner.machineHostname()); err != nil {
 		return err
 	}
 
-	for _, pkg := range provisioner.Packages {
-		log.Debugf("installing base package: name=%s", pkg)
-		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
-			return err
+	// offline machines install from the cache and rely on the packages
+	// already on the image
+	if !engineOptions.Offline && !engineOptions.CloudInit {
+		for _, pkg := range provisioner.Packages {
+			log.Debugf("installing base package: name=%s", pkg)
+			if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
+				return err
+			}
 		}
-	}
 
-	// update OS -- this is needed for libdevicemapper and the docker install
-	if _, err := provisioner.SSHCommand("sudo yum -y update"); err != nil {
-		return err
+		// update OS -- this is needed for libdevicemapper and the docker install
+		if _, err := provisioner.SSHCommand("sudo -E yum -y update"); err != nil {
+			return err
+		}
 	}
 
 	// install docker
@@ -211,13 +261,25 @@ func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.SwarmOptions,
 		return err
 	}
 
+	if err := prepareSystemdDropIn(provisioner); err != nil {
+		return err
+	}
+
 	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
 
 	if err := ConfigureAuth(provisioner); err != nil {
 		return err
 	}
 
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+	if err := configureKernel(provisioner, provisioner.EngineOptions); err != nil {
+		return err
+	}
+
+	if err := configureRegistries(provisioner, provisioner.EngineOptions); err != nil {
+		return err
+	}
+
+	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions, provisioner.EngineOptions); err != nil {
 		return err
 	}
 
@@ -225,45 +287,14 @@ func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.SwarmOptions,
 }
 
 func (provisioner *RedHatProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
-	var (
-		engineCfg  bytes.Buffer
-		configPath = provisioner.DaemonOptionsFile
-	)
-
-	// remove existing
-	//if _, err := provisioner.SSHCommand(fmt.Sprintf("sudo rm %s", configPath)); err != nil {
-	//	return nil, err
-	//}
-
 	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
 	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+	provisioner.EngineOptions.Env = noProxyMachine(provisioner.Driver, provisioner.EngineOptions.Env)
 
-	// systemd / redhat will not load options if they are on newlines
-	// instead, it just continues with a different set of options; yeah...
-	engineConfigTmpl := `[Service]
-ExecStart=/usr/bin/docker -d -H tcp://0.0.0.0:{{.DockerPort}} -H unix:///var/run/docker.sock --storage-driver {{.EngineOptions.StorageDriver}} --tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}
-MountFlags=slave
-LimitNOFILE=1048576
-LimitNPROC=1048576
-LimitCORE=infinity
-`
-	t, err := template.New("engineConfig").Parse(engineConfigTmpl)
-	if err != nil {
-		return nil, err
-	}
-
-	engineConfigContext := EngineConfigContext{
+	return generateSystemdDropIn("/usr/bin/docker -d", EngineConfigContext{
 		DockerPort:       dockerPort,
 		AuthOptions:      provisioner.AuthOptions,
 		EngineOptions:    provisioner.EngineOptions,
 		DockerOptionsDir: provisioner.DockerOptionsDir,
-	}
-
-	t.Execute(&engineCfg, engineConfigContext)
-
-	daemonOptsDir := configPath
-	return &DockerOptions{
-		EngineOptions:     engineCfg.String(),
-		EngineOptionsPath: daemonOptsDir,
-	}, nil
+	})
 }
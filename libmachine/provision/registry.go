@@ -0,0 +1,214 @@
+package provision
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/log"
+)
+
+// certsDir holds the CA certificates the daemon trusts for each registry,
+// in certs.d/<registry>/ca.crt.
+const certsDir = "/etc/docker/certs.d"
+
+var validRegistryPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9\-\.]*(:[0-9]+)?$`)
+
+// registryFile is a file to write to the machine for the registry options.
+type registryFile struct {
+	Path    string
+	Content []byte
+	Perm    os.FileMode
+
+	// Owner is the user the directory of the file and the file belong to,
+	// or empty for root.
+	Owner string
+}
+
+// splitRegistryCA splits a registry CA certificate of the form
+// registry=path.
+func splitRegistryCA(ca string) (string, string, error) {
+	parts := strings.SplitN(ca, "=", 2)
+	if len(parts) != 2 || !validRegistryPattern.MatchString(parts[0]) || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid registry CA certificate %q, expected registry=path, e.g. registry.example.com:5000=ca.pem", ca)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// readRegistryCA reads a CA certificate file in PEM format.
+func readRegistryCA(caPath string) ([]byte, error) {
+	content, err := ioutil.ReadFile(caPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if block, _ := pem.Decode(content); block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s is not a PEM certificate", caPath)
+	}
+
+	return content, nil
+}
+
+// readRegistryConfig reads a docker config.json file.
+func readRegistryConfig(configPath string) ([]byte, error) {
+	content, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var config map[string]interface{}
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("%s is not a docker config.json file: %s", configPath, err)
+	}
+
+	return content, nil
+}
+
+// ValidateRegistries checks the registry CA certificates, of the form
+// registry=path, and the docker config.json file, if any.
+func ValidateRegistries(cas []string, config string) error {
+	for _, ca := range cas {
+		_, caPath, err := splitRegistryCA(ca)
+		if err != nil {
+			return err
+		}
+
+		if _, err := readRegistryCA(caPath); err != nil {
+			return fmt.Errorf("invalid registry CA certificate: %s", err)
+		}
+	}
+
+	if config != "" {
+		if _, err := readRegistryConfig(config); err != nil {
+			return fmt.Errorf("invalid registry config: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// userHome returns the home directory of a user on the machine.
+func userHome(p Provisioner, user string) (string, error) {
+	output, err := p.SSHCommand(fmt.Sprintf("echo ~%s", user))
+	if err != nil {
+		return "", err
+	}
+
+	home := strings.TrimSpace(output)
+	if !path.IsAbs(home) || path.Clean(home) != home {
+		return "", fmt.Errorf("unexpected home directory of %s: %q", user, output)
+	}
+
+	return home, nil
+}
+
+// registryFiles reads the files of the registry options: the CA
+// certificates, and the config.json of root, which pulls the swarm image,
+// and of the SSH user.
+func registryFiles(p Provisioner, engineOptions engine.EngineOptions) ([]registryFile, error) {
+	var files []registryFile
+
+	for _, ca := range engineOptions.RegistryCA {
+		registry, caPath, err := splitRegistryCA(ca)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := readRegistryCA(caPath)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, registryFile{
+			Path:    path.Join(certsDir, registry, "ca.crt"),
+			Content: content,
+			Perm:    0644,
+		})
+	}
+
+	if engineOptions.RegistryConfig == "" {
+		return files, nil
+	}
+
+	content, err := readRegistryConfig(engineOptions.RegistryConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	users := []string{"root"}
+	if sshUser := p.GetDriver().GetSSHUsername(); sshUser != "root" {
+		if !validUserPattern.MatchString(sshUser) {
+			return nil, fmt.Errorf("unexpected SSH user name %q", sshUser)
+		}
+		users = append(users, sshUser)
+	}
+
+	for _, user := range users {
+		home, err := userHome(p, user)
+		if err != nil {
+			return nil, err
+		}
+
+		file := registryFile{
+			Path:    path.Join(home, ".docker", "config.json"),
+			Content: content,
+			Perm:    0600,
+		}
+
+		if user != "root" {
+			file.Owner = user
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// writeRegistryFile writes a file of the registry options to the path, which
+// may differ from the one of the file.
+func writeRegistryFile(p Provisioner, remotePath string, file registryFile) error {
+	if err := WriteFile(p, remotePath, bytes.NewReader(file.Content), file.Perm); err != nil {
+		return err
+	}
+
+	if file.Owner == "" {
+		return nil
+	}
+
+	if _, err := p.SSHCommand(fmt.Sprintf("sudo chown -R %s %s", file.Owner, path.Dir(remotePath))); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// configureRegistries writes the CA certificates and the credentials of the
+// registries to the machine.  The daemon reads the certificates when it
+// connects to a registry, so it does not need to be restarted.
+func configureRegistries(p Provisioner, engineOptions engine.EngineOptions) error {
+	defer p.GetHostLog().Stage("configure registries")()
+
+	files, err := registryFiles(p, engineOptions)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		log.Debugf("writing registry file %s", file.Path)
+
+		if err := writeRegistryFile(p, file.Path, file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
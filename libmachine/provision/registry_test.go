@@ -0,0 +1,126 @@
+package provision
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/engine"
+)
+
+const testRegistryConfig = `{"auths": {"registry.example.com:5000": {"auth": "dXNlcjpwYXNz"}}}`
+
+// newTestRegistryOptions returns engine options with a registry CA
+// certificate and a config.json in the temporary directory of the auth
+// options.
+func newTestRegistryOptions(t *testing.T, tmpDir string, caPath string) engine.EngineOptions {
+	configPath := filepath.Join(tmpDir, "config.json")
+	if err := ioutil.WriteFile(configPath, []byte(testRegistryConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return engine.EngineOptions{
+		RegistryCA:     []string{"registry.example.com:5000=" + caPath},
+		RegistryConfig: configPath,
+	}
+}
+
+func TestValidateRegistries(t *testing.T) {
+	authOptions, tmpDir := newTestAuthOptions(t, "test")
+	defer removeTestStore(tmpDir)
+
+	opts := newTestRegistryOptions(t, tmpDir, authOptions.CaCertPath)
+	if err := ValidateRegistries(opts.RegistryCA, opts.RegistryConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	invalidCAs := []string{
+		authOptions.CaCertPath,
+		"=" + authOptions.CaCertPath,
+		"registry.example.com=",
+		"registry.example.com/v2=" + authOptions.CaCertPath,
+		"registry.example.com=" + opts.RegistryConfig,
+		"registry.example.com=" + filepath.Join(tmpDir, "missing.pem"),
+	}
+
+	for _, ca := range invalidCAs {
+		if err := ValidateRegistries([]string{ca}, ""); err == nil {
+			t.Fatalf("%q: expected an error", ca)
+		}
+	}
+
+	if err := ValidateRegistries(nil, authOptions.CaCertPath); err == nil {
+		t.Fatal("expected an error for a config which is not JSON")
+	}
+}
+
+func TestUbuntuConfigureRegistries(t *testing.T) {
+	server, d := newTestServer(t, "ID=ubuntu\n")
+	defer server.Close()
+
+	server.Handle(`^echo ~root$`, "/root\n", 0)
+	server.Handle(`^echo ~docker$`, "/home/docker\n", 0)
+
+	authOptions, tmpDir := newTestAuthOptions(t, "test")
+	defer removeTestStore(tmpDir)
+
+	if err := configureRegistries(NewUbuntuProvisioner(d), newTestRegistryOptions(t, tmpDir, authOptions.CaCertPath)); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFileWritten(t, server, "/etc/docker/certs.d/registry.example.com:5000/ca.crt", "0644", `^-----BEGIN CERTIFICATE-----`)
+	assertFileWritten(t, server, "/root/.docker/config.json", "0600", `"auth": "dXNlcjpwYXNz"`)
+	assertFileWritten(t, server, "/home/docker/.docker/config.json", "0600", `"auth": "dXNlcjpwYXNz"`)
+
+	assertCommandSequence(t, server, []string{
+		`install -m 0600 -o root -g root \S+ '/home/docker/.docker/config.json'$`,
+		`^sudo chown -R docker /home/docker/.docker$`,
+	})
+}
+
+func TestBoot2DockerConfigureRegistries(t *testing.T) {
+	server, d := newTestServer(t, "ID=boot2docker\n")
+	defer server.Close()
+
+	server.Handle(`^echo ~root$`, "/root\n", 0)
+	server.Handle(`^echo ~docker$`, "/home/docker\n", 0)
+
+	authOptions, tmpDir := newTestAuthOptions(t, "test")
+	defer removeTestStore(tmpDir)
+
+	p := NewBoot2DockerProvisioner(d).(*Boot2DockerProvisioner)
+	p.EngineOptions = newTestRegistryOptions(t, tmpDir, authOptions.CaCertPath)
+
+	if err := p.configureRegistries(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the files are kept under /var/lib/boot2docker and copied on boot
+	assertFileWritten(t, server, "/var/lib/boot2docker/machine-registries/etc/docker/certs.d/registry.example.com:5000/ca.crt", "0644", `^-----BEGIN CERTIFICATE-----`)
+	assertFileWritten(t, server, "/var/lib/boot2docker/machine-registries/home/docker/.docker/config.json", "0600", `"auth"`)
+	assertFileWritten(t, server, "/var/lib/boot2docker/machine-registries.sh", "0755",
+		`mkdir -p /etc/docker/certs.d/registry.example.com:5000 && cp /var/lib/boot2docker/machine-registries/etc/docker/certs.d/registry.example.com:5000/ca.crt /etc/docker/certs.d/registry.example.com:5000/ca.crt && chmod 0644 /etc/docker/certs.d/registry.example.com:5000/ca.crt\n`+
+			`.*chmod 0600 /home/docker/.docker/config.json\nchown -R docker /home/docker/.docker\n$`)
+
+	assertCommandSequence(t, server, []string{
+		`grep -qxF 'sh /var/lib/boot2docker/machine-registries.sh' /var/lib/boot2docker/bootsync.sh`,
+		`^sudo sh /var/lib/boot2docker/machine-registries.sh$`,
+	})
+
+	if found := server.Find(`^sudo mkdir -p /etc/docker/certs.d`); len(found) != 0 {
+		t.Fatalf("expected the certificates to only be copied by the script; received:\n%s", server)
+	}
+}
+
+func TestConfigureRegistriesNone(t *testing.T) {
+	server, d := newTestServer(t, "ID=ubuntu\n")
+	defer server.Close()
+
+	if err := configureRegistries(NewUbuntuProvisioner(d), engine.EngineOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if commands := server.Commands(); len(commands) != 0 {
+		t.Fatalf("expected no commands without registry options; received:\n%s", server)
+	}
+}
@@ -0,0 +1,211 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/log"
+	"github.com/docker/machine/utils"
+)
+
+func init() {
+	Register("SUSE", &RegisteredProvisioner{
+		New: NewSUSEProvisioner,
+	})
+}
+
+func NewSUSEProvisioner(d drivers.Driver) Provisioner {
+	return &SUSEProvisioner{
+		GenericProvisioner{
+			DockerOptionsDir:  "/etc/docker",
+			DaemonOptionsFile: systemdDropInFile,
+			OsReleaseId:       "opensuse",
+			Packages:          []string{},
+			Driver:            d,
+		},
+	}
+}
+
+type SUSEProvisioner struct {
+	GenericProvisioner
+}
+
+// CompatibleWithHost matches openSUSE, including the ids of the Leap and
+// Tumbleweed releases, and SUSE Linux Enterprise Server.  Other
+// derivatives are matched through the "suse" in their ID_LIKE.
+func (provisioner *SUSEProvisioner) CompatibleWithHost() bool {
+	id := provisioner.OsReleaseInfo.Id
+	return id == "opensuse" || id == "sles" || id == "suse" || strings.HasPrefix(id, "opensuse-")
+}
+
+func (provisioner *SUSEProvisioner) SetAuthorizedKeys(keys []string, user string) error {
+	return setAuthorizedKeys(provisioner, keys, user)
+}
+
+func (provisioner *SUSEProvisioner) Service(name string, action pkgaction.ServiceAction) error {
+	return systemdService(provisioner, name, action)
+}
+
+func (provisioner *SUSEProvisioner) ServiceLog(name string, lines int) (string, error) {
+	return systemdServiceLog(provisioner, name, lines)
+}
+
+func (provisioner *SUSEProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	var packageAction string
+
+	switch action {
+	case pkgaction.Install:
+		packageAction = "install"
+	case pkgaction.Remove:
+		packageAction = "remove"
+	case pkgaction.Upgrade:
+		packageAction = "update"
+	}
+
+	command := fmt.Sprintf("sudo -E zypper -n %s %s", packageAction, name)
+
+	log.Debugf("package: action=%s name=%s", action.String(), name)
+
+	if _, err := provisioner.SSHCommand(command); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// installDocker installs the given version of docker, or the latest one if
+// the version is empty.  A specific version may be older than the installed
+// one.
+func (provisioner *SUSEProvisioner) installDocker(version string) error {
+	if version == "" {
+		return provisioner.Package("docker", pkgaction.Install)
+	}
+
+	if output, err := provisioner.SSHCommand(fmt.Sprintf("sudo -E zypper -n install --oldpackage docker=%s", version)); err != nil {
+		return fmt.Errorf("error installing docker %s: %s", version, output)
+	}
+
+	return nil
+}
+
+func (provisioner *SUSEProvisioner) UpgradeDocker(version string) error {
+	if version == "" {
+		return provisioner.Package("docker", pkgaction.Upgrade)
+	}
+
+	return provisioner.installDocker(version)
+}
+
+// RollbackDocker installs the previous version, which the package manager
+// downgrades to.
+func (provisioner *SUSEProvisioner) RollbackDocker(version string) error {
+	return provisioner.UpgradeDocker(version)
+}
+
+func (provisioner *SUSEProvisioner) dockerDaemonResponding() bool {
+	if _, err := provisioner.SSHCommand("sudo docker version"); err != nil {
+		log.Warnf("Error getting SSH command to check if the daemon is up: %s", err)
+		return false
+	}
+
+	// The daemon is up if the command worked.  Carry on.
+	return true
+}
+
+func (provisioner *SUSEProvisioner) Provision(swarmOptions swarm.SwarmOptions, authOptions auth.AuthOptions, engineOptions engine.EngineOptions) error {
+	provisioner.SwarmOptions = swarmOptions
+	provisioner.AuthOptions = authOptions
+	provisioner.EngineOptions = engineOptions
+
+	if engineOptions.CloudInit {
+		return ErrCloudInitUnsupported
+	}
+
+	if engineOptions.Offline {
+		return ErrOfflineUnsupported
+	}
+
+	log.Debug("setting hostname")
+	if err := provisioner.SetHostname(provisioner.machineHostname()); err != nil {
+		return err
+	}
+
+	log.Debug("installing base packages")
+	for _, pkg := range provisioner.Packages {
+		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
+			return err
+		}
+	}
+
+	// docker is packaged by the distribution; on SLES it requires the
+	// containers module to be enabled
+	log.Debug("installing docker")
+	if err := provisioner.installDocker(provisioner.EngineOptions.Version); err != nil {
+		return err
+	}
+
+	log.Debug("enabling docker in systemd")
+	if err := provisioner.Service("docker", pkgaction.Enable); err != nil {
+		return err
+	}
+
+	if err := provisioner.Service("docker", pkgaction.Start); err != nil {
+		return err
+	}
+
+	log.Debug("waiting for docker daemon")
+	if err := utils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
+		return err
+	}
+
+	if err := makeDockerOptionsDir(provisioner); err != nil {
+		return err
+	}
+
+	if err := prepareSystemdDropIn(provisioner); err != nil {
+		return err
+	}
+
+	provisioner.AuthOptions = setRemoteAuthOptions(provisioner)
+
+	log.Debug("configuring auth")
+	if err := ConfigureAuth(provisioner); err != nil {
+		return err
+	}
+
+	log.Debug("configuring kernel")
+	if err := configureKernel(provisioner, provisioner.EngineOptions); err != nil {
+		return err
+	}
+
+	log.Debug("configuring registries")
+	if err := configureRegistries(provisioner, provisioner.EngineOptions); err != nil {
+		return err
+	}
+
+	log.Debug("configuring swarm")
+	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions, provisioner.EngineOptions); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (provisioner *SUSEProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
+	driverNameLabel := fmt.Sprintf("provider=%s", provisioner.Driver.DriverName())
+	provisioner.EngineOptions.Labels = append(provisioner.EngineOptions.Labels, driverNameLabel)
+	provisioner.EngineOptions.Env = noProxyMachine(provisioner.Driver, provisioner.EngineOptions.Env)
+
+	// the storage driver is left to docker unless asked for, since the
+	// best one depends on whether the root filesystem is btrfs
+	return generateSystemdDropIn("/usr/bin/docker daemon", EngineConfigContext{
+		DockerPort:    dockerPort,
+		AuthOptions:   provisioner.AuthOptions,
+		EngineOptions: provisioner.EngineOptions,
+	})
+}
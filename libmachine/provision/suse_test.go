@@ -0,0 +1,132 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+func TestSUSECompatibleWithHost(t *testing.T) {
+	cases := map[string]bool{
+		"ID=opensuse\nVERSION_ID=\"13.2\"\n":                             true,
+		"ID=\"opensuse-leap\"\nID_LIKE=\"suse opensuse\"\n":              true,
+		"ID=\"sles\"\nVERSION_ID=\"12.1\"\n":                             true,
+		"ID=\"sles_sap\"\nID_LIKE=\"suse\"\nVERSION_ID=\"12.1\"\n":       true,
+		"ID=\"fedora\"\nVERSION_ID=\"23\"\n":                             false,
+		"ID=\"opensusefork\"\nVERSION_ID=\"1\"\n":                        false,
+		"ID=\"opensuse-tumbleweed\"\nID_LIKE=\"opensuse suse\"\n":        true,
+		"ID=\"ubuntu\"\nID_LIKE=\"debian\"\nVERSION_ID=\"14.04\"\n":      false,
+		"ID=\"centos\"\nID_LIKE=\"rhel fedora\"\nVERSION_ID=\"7\"\n":     false,
+		"ID=\"opensuse\"\nID_LIKE=\"suse\"\nVERSION_ID=\"42.1\"\n":       true,
+		"ID=\"sled\"\nID_LIKE=\"suse\"\nVERSION_ID=\"12\"\n":             true,
+		"ID=\"suse-derivative\"\nID_LIKE=\"suse\"\nVERSION_ID=\"1.0\"\n": true,
+	}
+
+	for osRelease, expected := range cases {
+		info, err := NewOsRelease([]byte(osRelease))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := detectProvisioner(&fakedriver.FakeDriver{}, info)
+		_, isSUSE := p.(*SUSEProvisioner)
+
+		if expected && (err != nil || !isSUSE) {
+			t.Errorf("expected the SUSE provisioner for %q; received %T %v", osRelease, p, err)
+		}
+
+		if !expected && isSUSE {
+			t.Errorf("did not expect the SUSE provisioner for %q", osRelease)
+		}
+	}
+}
+
+func TestSUSEProvision(t *testing.T) {
+	server, d := newTestServer(t, "NAME=\"SLES\"\nID=\"sles\"\nVERSION_ID=\"12.1\"\n")
+	defer server.Close()
+
+	authOptions, tmpDir := newTestAuthOptions(t, "test")
+	defer removeTestStore(tmpDir)
+
+	p, err := DetectProvisioner(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	swarmOptions := swarm.SwarmOptions{
+		IsSwarm:   true,
+		Host:      "tcp://0.0.0.0:3376",
+		Image:     "swarm:latest",
+		Discovery: "token://abc",
+	}
+
+	engineOptions := engine.EngineOptions{
+		Labels: []string{"env=test"},
+	}
+
+	if err := p.Provision(swarmOptions, authOptions, engineOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^sudo hostname test && echo "test" \| sudo tee /etc/hostname$`,
+		`^sudo -E zypper -n install docker$`,
+		`^sudo systemctl enable docker$`,
+		`^sudo systemctl daemon-reload$`,
+		`^sudo systemctl start docker$`,
+		`^sudo docker version$`,
+		`^sudo mkdir -p /etc/docker$`,
+		`^sudo mkdir -p /etc/systemd/system/docker.service.d$`,
+		`^sudo systemctl stop docker$`,
+		`install -m 0644 -o root -g root \S+ '/etc/docker/ca.pem'$`,
+		`install -m 0644 -o root -g root \S+ '/etc/docker/server.pem'$`,
+		`install -m 0600 -o root -g root \S+ '/etc/docker/server-key.pem'$`,
+		`install -m 0644 -o root -g root \S+ '/etc/systemd/system/docker.service.d/10-machine.conf'$`,
+		`^sudo systemctl daemon-reload$`,
+		`^sudo systemctl start docker$`,
+		`^sudo docker pull swarm:latest$`,
+		`join --addr 127.0.0.1:2376 token://abc`,
+	})
+
+	if found := server.Find(`get.docker.com|apt-get|yum`); len(found) != 0 {
+		t.Fatalf("unexpected commands for SUSE: %+v", found)
+	}
+}
+
+func TestSUSEGenerateDockerOptions(t *testing.T) {
+	p := NewSUSEProvisioner(&fakedriver.FakeDriver{}).(*SUSEProvisioner)
+	p.AuthOptions = auth.AuthOptions{
+		CaCertRemotePath:     "/etc/docker/ca.pem",
+		ServerCertRemotePath: "/etc/docker/server.pem",
+		ServerKeyRemotePath:  "/etc/docker/server-key.pem",
+	}
+
+	opts, err := p.GenerateDockerOptions(2376)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(opts.EngineOptions, "\n")
+	if lines[0] != "[Service]" || lines[1] != "ExecStart=" {
+		t.Fatalf("expected a drop-in resetting ExecStart; received:\n%s", opts.EngineOptions)
+	}
+
+	if strings.Contains(opts.EngineOptions, "--storage-driver") {
+		t.Fatalf("expected no storage driver by default; received:\n%s", opts.EngineOptions)
+	}
+
+	p.EngineOptions.StorageDriver = "btrfs"
+
+	opts, err = p.GenerateDockerOptions(2376)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(opts.EngineOptions, "--storage-driver btrfs --tlsverify") {
+		t.Fatalf("expected the storage driver to be set; received:\n%s", opts.EngineOptions)
+	}
+}
@@ -0,0 +1,105 @@
+package provision
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+)
+
+const (
+	systemdDropInDir = "/etc/systemd/system/docker.service.d"
+
+	// systemdDropInFile is where the daemon options go on systemd
+	// distributions.  Overriding the packaged unit with a drop-in instead
+	// of replacing it keeps package upgrades from clobbering our changes.
+	systemdDropInFile = systemdDropInDir + "/10-machine.conf"
+
+	// systemdLegacyUnitFile is the full unit written by earlier versions.
+	systemdLegacyUnitFile = "/etc/systemd/system/docker.service"
+)
+
+type systemdDropInContext struct {
+	EngineConfigContext
+	DockerCommand string
+}
+
+// A unit may only have one ExecStart, so the packaged one is reset before
+// setting ours.
+const systemdDropInTmpl = `[Service]
+ExecStart=
+ExecStart={{.DockerCommand}} -H tcp://0.0.0.0:{{.DockerPort}} -H unix:///var/run/docker.sock {{ if .EngineOptions.StorageDriver }}--storage-driver {{.EngineOptions.StorageDriver}} {{ end }}--tlsverify --tlscacert {{.AuthOptions.CaCertRemotePath}} --tlscert {{.AuthOptions.ServerCertRemotePath}} --tlskey {{.AuthOptions.ServerKeyRemotePath}} {{ range .EngineOptions.Labels }}--label {{.}} {{ end }}{{ range .EngineOptions.InsecureRegistry }}--insecure-registry {{.}} {{ end }}{{ range .EngineOptions.RegistryMirror }}--registry-mirror {{.}} {{ end }}{{ range .EngineFlags }}{{.}} {{ end }}{{ range .EngineOptions.ArbitraryFlags }}--{{.}} {{ end }}
+{{ range .EngineOptions.Env }}Environment='{{.}}'
+{{ end }}MountFlags=slave
+LimitNOFILE=1048576
+LimitNPROC=1048576
+LimitCORE=infinity
+`
+
+// generateSystemdDropIn returns the drop-in starting the daemon with the
+// given command, e.g. "/usr/bin/docker daemon", and the engine options.
+func generateSystemdDropIn(dockerCommand string, engineConfigContext EngineConfigContext) (*DockerOptions, error) {
+	var (
+		engineCfg bytes.Buffer
+	)
+
+	t, err := template.New("engineConfig").Parse(systemdDropInTmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	engineConfigContext.EngineOptions = escapeEngineOptions(engineConfigContext.EngineOptions, systemdEscape)
+
+	if err := t.Execute(&engineCfg, systemdDropInContext{engineConfigContext, dockerCommand}); err != nil {
+		return nil, err
+	}
+
+	return &DockerOptions{
+		EngineOptions:     engineCfg.String(),
+		EngineOptionsPath: systemdDropInFile,
+	}, nil
+}
+
+// prepareSystemdDropIn creates the drop-in directory and removes the full
+// unit written by earlier versions, which would otherwise shadow the
+// packaged one.
+func prepareSystemdDropIn(p Provisioner) error {
+	if _, err := p.SSHCommand(fmt.Sprintf("sudo mkdir -p %s", systemdDropInDir)); err != nil {
+		return err
+	}
+
+	if _, err := p.SSHCommand(fmt.Sprintf("if grep -qs -- --tlscacert %s; then sudo rm -f %s; fi", systemdLegacyUnitFile, systemdLegacyUnitFile)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// systemdService performs a service action with systemctl.  Unit files are
+// reloaded before every (re)start, since we cannot be sure exactly when
+// the drop-in last changed.
+func systemdService(p Provisioner, name string, action pkgaction.ServiceAction) error {
+	switch action {
+	case pkgaction.Start, pkgaction.Restart:
+		if err := systemdService(p, name, pkgaction.DaemonReload); err != nil {
+			return err
+		}
+	}
+
+	command := fmt.Sprintf("sudo systemctl %s %s", action.String(), name)
+	if action == pkgaction.DaemonReload {
+		command = "sudo systemctl daemon-reload"
+	}
+
+	if _, err := p.SSHCommand(command); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// systemdServiceLog returns the last lines of the journal of a service.
+func systemdServiceLog(p Provisioner, name string, lines int) (string, error) {
+	return p.SSHCommand(fmt.Sprintf("sudo journalctl -u %s --no-pager -n %d", name, lines))
+}
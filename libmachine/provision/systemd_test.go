@@ -0,0 +1,123 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+func TestSystemdDropIn(t *testing.T) {
+	authOptions := auth.AuthOptions{
+		CaCertRemotePath:     "/etc/docker/ca.pem",
+		ServerCertRemotePath: "/etc/docker/server.pem",
+		ServerKeyRemotePath:  "/etc/docker/server-key.pem",
+	}
+
+	debian := NewDebianProvisioner(&fakedriver.FakeDriver{}).(*DebianProvisioner)
+	debian.AuthOptions = authOptions
+
+	redhat := NewRedHatProvisioner(&fakedriver.FakeDriver{}).(*RedHatProvisioner)
+	redhat.AuthOptions = authOptions
+
+	for _, p := range []Provisioner{debian, redhat} {
+		opts, err := p.GenerateDockerOptions(2376)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if opts.EngineOptionsPath != "/etc/systemd/system/docker.service.d/10-machine.conf" {
+			t.Fatalf("%T: expected the options in a drop-in; received %s", p, opts.EngineOptionsPath)
+		}
+
+		lines := strings.Split(opts.EngineOptions, "\n")
+		if lines[0] != "[Service]" || lines[1] != "ExecStart=" {
+			t.Fatalf("%T: expected a drop-in resetting ExecStart; received:\n%s", p, opts.EngineOptions)
+		}
+
+		if !strings.HasPrefix(lines[2], "ExecStart=/usr/bin/docker -d -H tcp://0.0.0.0:2376 ") {
+			t.Fatalf("%T: unexpected ExecStart; received:\n%s", p, opts.EngineOptions)
+		}
+
+		if !strings.Contains(lines[2], "--tlsverify --tlscacert /etc/docker/ca.pem") || !strings.Contains(lines[2], "--label provider=fakedriver") {
+			t.Fatalf("%T: expected the TLS options and driver label; received:\n%s", p, opts.EngineOptions)
+		}
+	}
+}
+
+func TestSystemdServiceReloadsBeforeStart(t *testing.T) {
+	server, d := newTestServer(t, "ID=debian\n")
+	defer server.Close()
+
+	p := NewDebianProvisioner(d)
+
+	if err := p.Service("docker", pkgaction.Enable); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Service("docker", pkgaction.Restart); err != nil {
+		t.Fatal(err)
+	}
+
+	commands := server.Commands()
+	if len(commands) != 3 {
+		t.Fatalf("expected enable, daemon-reload and restart; received:\n%s", server)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^sudo systemctl enable docker$`,
+		`^sudo systemctl daemon-reload$`,
+		`^sudo systemctl restart docker$`,
+	})
+}
+
+func TestDebianProvision(t *testing.T) {
+	server, d := newTestServer(t, "ID=debian\nVERSION_ID=\"8\"\n")
+	defer server.Close()
+
+	authOptions, tmpDir := newTestAuthOptions(t, "test")
+	defer removeTestStore(tmpDir)
+
+	p, err := DetectProvisioner(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engineOptions := engine.EngineOptions{
+		InstallURL: "https://get.docker.com",
+	}
+
+	if err := p.Provision(swarm.SwarmOptions{}, authOptions, engineOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`curl -sSL https://get.docker.com \| sh -`,
+		`^sudo mkdir -p /etc/systemd/system/docker.service.d$`,
+		`^if grep -qs -- --tlscacert /etc/systemd/system/docker.service; then sudo rm -f /etc/systemd/system/docker.service; fi$`,
+		`^sudo systemctl stop docker$`,
+		`install -m 0644 -o root -g root \S+ '/etc/systemd/system/docker.service.d/10-machine.conf'$`,
+		`^sudo systemctl daemon-reload$`,
+		`^sudo systemctl start docker$`,
+	})
+}
+
+func TestServiceLog(t *testing.T) {
+	server, d := newTestServer(t, "ID=debian\n")
+	defer server.Close()
+
+	server.Handle(`^sudo journalctl -u docker --no-pager -n 20$`, "level=info msg=\"Daemon has completed initialization\"\n", 0)
+
+	output, err := NewDebianProvisioner(d).ServiceLog("docker", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(output, "completed initialization") {
+		t.Fatalf("unexpected daemon log %q", output)
+	}
+}
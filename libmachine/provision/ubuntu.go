@@ -36,6 +36,10 @@ type UbuntuProvisioner struct {
 	GenericProvisioner
 }
 
+func (provisioner *UbuntuProvisioner) SetAuthorizedKeys(keys []string, user string) error {
+	return setAuthorizedKeys(provisioner, keys, user)
+}
+
 func (provisioner *UbuntuProvisioner) Service(name string, action pkgaction.ServiceAction) error {
 	command := fmt.Sprintf("sudo service %s %s", name, action.String())
 
@@ -46,6 +50,14 @@ func (provisioner *UbuntuProvisioner) Service(name string, action pkgaction.Serv
 	return nil
 }
 
+// ServiceLog returns the end of the log upstart writes the output of the
+// service to, or of its journal on releases which run systemd.
+func (provisioner *UbuntuProvisioner) ServiceLog(name string, lines int) (string, error) {
+	logPath := fmt.Sprintf("/var/log/upstart/%s.log", name)
+
+	return provisioner.SSHCommand(fmt.Sprintf("if [ -f %s ]; then sudo tail -n %d %s; else sudo journalctl -u %s --no-pager -n %d; fi", logPath, lines, logPath, name, lines))
+}
+
 func (provisioner *UbuntuProvisioner) Package(name string, action pkgaction.PackageAction) error {
 	var (
 		packageAction  string
@@ -84,6 +96,50 @@ func (provisioner *UbuntuProvisioner) Package(name string, action pkgaction.Pack
 	return nil
 }
 
+// installDocker installs docker with the install script, pinned to the
+// requested version, or from the offline cache.
+func (provisioner *UbuntuProvisioner) installDocker() error {
+	engineOptions := provisioner.EngineOptions
+
+	switch {
+	case engineOptions.Offline:
+		return installOfflineDocker(provisioner, provisioner.OsReleaseInfo, engineOptions.Version, "DEBIAN_FRONTEND=noninteractive sudo -E dpkg -i '%s'")
+	case engineOptions.CloudInit:
+		// cloud-init ran the install script on the first boot
+	default:
+		// the base packages are only needed by the install script
+		for _, pkg := range provisioner.Packages {
+			if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
+				return err
+			}
+		}
+
+		if err := installDockerGeneric(provisioner, engineOptions.InstallURL); err != nil {
+			return err
+		}
+	}
+
+	if engineOptions.Version != "" {
+		return installDockerVersionApt(provisioner, engineOptions.Version)
+	}
+
+	return nil
+}
+
+func (provisioner *UbuntuProvisioner) UpgradeDocker(version string) error {
+	if version == "" {
+		return provisioner.Package("docker-engine", pkgaction.Upgrade)
+	}
+
+	return installDockerVersionApt(provisioner, version)
+}
+
+// RollbackDocker installs the previous version, which the package manager
+// downgrades to.
+func (provisioner *UbuntuProvisioner) RollbackDocker(version string) error {
+	return provisioner.UpgradeDocker(version)
+}
+
 func (provisioner *UbuntuProvisioner) dockerDaemonResponding() bool {
 	if _, err := provisioner.SSHCommand("sudo docker version"); err != nil {
 		log.Warnf("Error getting SSH command to check if the daemon is up: %s", err)
@@ -103,17 +159,17 @@ func (provisioner *UbuntuProvisioner) Provision(swarmOptions swarm.SwarmOptions,
 		provisioner.EngineOptions.StorageDriver = "aufs"
 	}
 
-	if err := provisioner.SetHostname(provisioner.Driver.GetMachineName()); err != nil {
-		return err
-	}
-
-	for _, pkg := range provisioner.Packages {
-		if err := provisioner.Package(pkg, pkgaction.Install); err != nil {
+	// cloud-init set the hostname on the first boot
+	if engineOptions.CloudInit {
+		if err := waitForCloudInit(provisioner); err != nil {
 			return err
 		}
+	} else if err := provisioner.SetHostname(provisioner.machineHostname()); err != nil {
+		return err
 	}
 
-	if err := installDockerGeneric(provisioner, engineOptions.InstallURL); err != nil {
+	log.Debug("installing docker")
+	if err := provisioner.installDocker(); err != nil {
 		return err
 	}
 
@@ -131,7 +187,15 @@ func (provisioner *UbuntuProvisioner) Provision(swarmOptions swarm.SwarmOptions,
 		return err
 	}
 
-	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions); err != nil {
+	if err := configureKernel(provisioner, provisioner.EngineOptions); err != nil {
+		return err
+	}
+
+	if err := configureRegistries(provisioner, provisioner.EngineOptions); err != nil {
+		return err
+	}
+
+	if err := configureSwarm(provisioner, swarmOptions, provisioner.AuthOptions, provisioner.EngineOptions); err != nil {
 		return err
 	}
 
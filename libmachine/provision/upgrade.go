@@ -0,0 +1,41 @@
+package provision
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GetRunningContainers returns the full IDs of the containers running on
+// the machine.
+func GetRunningContainers(p Provisioner) ([]string, error) {
+	output, err := p.SSHCommand("sudo docker ps -q --no-trunc")
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(output), nil
+}
+
+// GetRestartingContainers returns the full IDs of the running containers
+// with a restart policy, which the daemon starts again when it restarts.
+func GetRestartingContainers(p Provisioner) ([]string, error) {
+	running, err := GetRunningContainers(p)
+	if err != nil || len(running) == 0 {
+		return nil, err
+	}
+
+	output, err := p.SSHCommand(fmt.Sprintf("sudo docker inspect -f '{{.Id}} {{.HostConfig.RestartPolicy.Name}}' %s", strings.Join(running, " ")))
+	if err != nil {
+		return nil, err
+	}
+
+	containers := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] != "no" {
+			containers = append(containers, fields[0])
+		}
+	}
+
+	return containers, nil
+}
@@ -0,0 +1,49 @@
+package provision
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetRestartingContainers(t *testing.T) {
+	server, d := newTestServer(t, "ID=ubuntu\n")
+	defer server.Close()
+
+	p := NewUbuntuProvisioner(d)
+
+	containers, err := GetRestartingContainers(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(containers) != 0 {
+		t.Fatalf("expected no containers; received %v", containers)
+	}
+
+	if found := server.Find(`docker inspect`); len(found) != 0 {
+		t.Fatalf("expected no containers to be inspected; received:\n%s", server)
+	}
+
+	server.Handle(`^sudo docker ps -q --no-trunc$`, "aaa\nbbb\n", 0)
+	server.Handle(`^sudo docker inspect -f '{{.Id}} {{.HostConfig.RestartPolicy.Name}}' aaa bbb$`, "aaa always\nbbb no\n", 0)
+
+	containers, err = GetRestartingContainers(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(containers, []string{"aaa"}) {
+		t.Fatalf("expected the container with a restart policy; received %v", containers)
+	}
+}
+
+func TestRollbackDockerUnsupported(t *testing.T) {
+	server, d := newTestServer(t, "ID=rancheros\n")
+	defer server.Close()
+
+	for _, p := range []Provisioner{NewCoreOSProvisioner(d), NewRancherProvisioner(d)} {
+		if err := p.RollbackDocker("1.8.2"); err != ErrRollbackUnsupported {
+			t.Fatalf("expected rolling back to be unsupported; received %v", err)
+		}
+	}
+}
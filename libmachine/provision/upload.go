@@ -0,0 +1,93 @@
+package provision
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/log"
+)
+
+// uploadTempFile streams the content over SSH to a file with the given name
+// in a new temporary directory on the machine, only readable by the SSH
+// user, and returns its path, which must be quoted in commands.  The
+// content is passed on stdin so that it never goes through the shell.  The
+// directory is removed with removeTempFile.
+func uploadTempFile(p Provisioner, name string, content io.Reader) (string, error) {
+	output, err := runSSHCommand(p.GetDriver(), p.GetHostLog(), "mktemp -d")
+	if err != nil {
+		return "", fmt.Errorf("error creating a temporary directory: %s", output)
+	}
+
+	// the directory is removed afterwards, so make sure it is one
+	dir := strings.TrimSpace(output)
+	if !path.IsAbs(dir) || path.Clean(dir) == "/" {
+		return "", fmt.Errorf("unexpected temporary directory: %q", output)
+	}
+
+	tmpPath := path.Join(dir, name)
+
+	log.Debugf("uploading %s", tmpPath)
+
+	if output, err := runSSHCommandWithStdin(p.GetDriver(), p.GetHostLog(), fmt.Sprintf("cat > '%s'", shellEscape(tmpPath)), content); err != nil {
+		removeTempFile(p, tmpPath)
+		return "", fmt.Errorf("error uploading %s: %s", name, output)
+	}
+
+	return tmpPath, nil
+}
+
+// uploadLocalTempFile uploads a local file with uploadTempFile, under the
+// same name.
+func uploadLocalTempFile(p Provisioner, localPath string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return uploadTempFile(p, filepath.Base(localPath), f)
+}
+
+// removeTempFile removes a file uploaded by uploadTempFile along with its
+// directory.  Failing to is only logged, the machine is usable regardless.
+func removeTempFile(p Provisioner, tmpPath string) {
+	if _, err := runSSHCommand(p.GetDriver(), p.GetHostLog(), fmt.Sprintf("rm -rf '%s'", shellEscape(path.Dir(tmpPath)))); err != nil {
+		log.Warnf("Unable to remove %s: %s", path.Dir(tmpPath), err)
+	}
+}
+
+// WriteFile writes the content to the path on the machine, owned by root
+// with the given permissions, creating the directories it is in.  The
+// content is uploaded to a private temporary file first, so that it is
+// never readable by other users or half written.
+func WriteFile(p Provisioner, remotePath string, content io.Reader, perm os.FileMode) error {
+	tmpPath, err := uploadTempFile(p, path.Base(remotePath), content)
+	if err != nil {
+		return err
+	}
+	defer removeTempFile(p, tmpPath)
+
+	if output, err := p.SSHCommand(fmt.Sprintf("sudo mkdir -p '%s' && sudo install -m %04o -o root -g root '%s' '%s'",
+		shellEscape(path.Dir(remotePath)),
+		uint32(perm.Perm()),
+		shellEscape(tmpPath),
+		shellEscape(remotePath),
+	)); err != nil {
+		return fmt.Errorf("error writing %s: %s", remotePath, output)
+	}
+
+	return nil
+}
+
+// removeFile removes a file written by WriteFile, if it is there.
+func removeFile(p Provisioner, remotePath string) error {
+	if output, err := p.SSHCommand(fmt.Sprintf("sudo rm -f '%s'", shellEscape(remotePath))); err != nil {
+		return fmt.Errorf("error removing %s: %s", remotePath, output)
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package provision
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	server, d := newTestServer(t, "ID=debian\n")
+	defer server.Close()
+
+	content := "key='it'\\''s' \"$HOME\" `id` %s\n"
+
+	if err := WriteFile(NewDebianProvisioner(d), "/etc/docker/key.pem", strings.NewReader(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^mktemp -d$`,
+		`^cat > '/tmp/tmp.machine/key.pem'$`,
+		`^sudo mkdir -p '/etc/docker' && sudo install -m 0600 -o root -g root '/tmp/tmp.machine/key.pem' '/etc/docker/key.pem'$`,
+		`^rm -rf '/tmp/tmp.machine'$`,
+	})
+
+	found := server.Find(`^cat > `)
+	if len(found) != 1 || string(found[0].Stdin) != content {
+		t.Fatalf("expected the content to be uploaded as is; received:\n%s", server)
+	}
+
+	if found := server.Find(`HOME|id`); len(found) != 0 {
+		t.Fatalf("expected the content not to be part of any command; received:\n%s", server)
+	}
+}
+
+func TestWriteFileQuotesPaths(t *testing.T) {
+	server, d := newTestServer(t, "ID=debian\n")
+	defer server.Close()
+
+	if err := WriteFile(NewDebianProvisioner(d), "/etc/my app/it's.conf", strings.NewReader("conf"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	assertCommandSequence(t, server, []string{
+		`^cat > '/tmp/tmp.machine/it'\\''s.conf'$`,
+		`^sudo mkdir -p '/etc/my app' && sudo install -m 0644 -o root -g root '/tmp/tmp.machine/it'\\''s.conf' '/etc/my app/it'\\''s.conf'$`,
+		`^rm -rf '/tmp/tmp.machine'$`,
+	})
+
+	assertFileWritten(t, server, "/etc/my app/it's.conf", "0644", `^conf$`)
+}
+
+func TestWriteFileUnexpectedTempDir(t *testing.T) {
+	for _, output := range []string{"", "/\n", "tmp.machine\n"} {
+		server, d := newTestServer(t, "ID=debian\n")
+		server.Handle(`^mktemp -d$`, output, 0)
+
+		err := WriteFile(NewDebianProvisioner(d), "/etc/docker/ca.pem", strings.NewReader("ca"), 0644)
+		found := server.Find(`^rm |^cat > |install`)
+		server.Close()
+
+		if err == nil {
+			t.Fatalf("%q: expected an error", output)
+		}
+
+		if len(found) != 0 {
+			t.Fatalf("%q: expected nothing to be uploaded or removed; received %+v", output, found)
+		}
+	}
+}
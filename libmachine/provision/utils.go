@@ -1,14 +1,19 @@
 package provision
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/url"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/docker/machine/drivers"
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/provision/pkgaction"
 	"github.com/docker/machine/log"
@@ -20,7 +25,39 @@ type DockerOptions struct {
 	EngineOptionsPath string
 }
 
+// logCommand logs a command run on the machine to its host log, if it has
+// one, and returns its output.
+func logCommand(l *log.HostLog, command string, start time.Time, output string, err error) (string, error) {
+	l.LogCommand(command, output, err, time.Since(start))
+	return output, err
+}
+
+// runSSHCommand runs a command on the machine with the SSH client of its
+// driver, and logs it.
+func runSSHCommand(d drivers.Driver, l *log.HostLog, command string) (string, error) {
+	start := time.Now()
+	output, err := drivers.RunSSHCommandFromDriver(d, command)
+
+	return logCommand(l, command, start, output, err)
+}
+
+// runSSHCommandWithStdin runs a command on the machine with stdin connected
+// to the reader, and logs it.
+func runSSHCommandWithStdin(d drivers.Driver, l *log.HostLog, command string, stdin io.Reader) (string, error) {
+	client, err := drivers.GetSSHClientFromDriver(d)
+	if err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	output, err := client.OutputWithStdin(command, stdin)
+
+	return logCommand(l, command, start, output, err)
+}
+
 func installDockerGeneric(p Provisioner, baseURL string) error {
+	defer p.GetHostLog().Stage("install docker")()
+
 	// install docker - until cloudinit we use ubuntu everywhere so we
 	// just install it using the docker repos
 	if output, err := p.SSHCommand(fmt.Sprintf("if ! type docker; then curl -sSL %s | sh -; fi", baseURL)); err != nil {
@@ -30,6 +67,80 @@ func installDockerGeneric(p Provisioner, baseURL string) error {
 	return nil
 }
 
+// installDockerVersionApt installs the given version of the docker-engine
+// package from the repository set up by the install script.  The package
+// versions carry a distribution suffix, e.g. 1.8.2-0~trusty, so the full
+// version is looked up first.
+func installDockerVersionApt(p Provisioner, version string) error {
+	if _, err := p.SSHCommand("sudo -E apt-get update"); err != nil {
+		return err
+	}
+
+	output, err := p.SSHCommand(fmt.Sprintf("apt-cache madison docker-engine | awk 'index($3, \"%s-\") == 1 { print $3; exit }'", version))
+	if err != nil {
+		return fmt.Errorf("error looking up docker %s: %s", version, output)
+	}
+
+	packageVersion := strings.TrimSpace(output)
+	if packageVersion == "" {
+		return fmt.Errorf("docker %s was not found in the docker-engine repository", version)
+	}
+
+	command := fmt.Sprintf("DEBIAN_FRONTEND=noninteractive sudo -E apt-get install -y --force-yes docker-engine=%s", packageVersion)
+	if output, err := p.SSHCommand(command); err != nil {
+		return fmt.Errorf("error installing docker %s: %s", version, output)
+	}
+
+	return nil
+}
+
+var (
+	serverVersionRegexp = regexp.MustCompile(`(?m)^Server version: (\S+)`)
+	serverSectionRegexp = regexp.MustCompile(`(?m)^Server:\s*\n\s*Version:\s*(\S+)`)
+)
+
+// parseDockerVersion returns the server version from the output of docker
+// version, which is formatted differently since docker 1.8.
+func parseDockerVersion(output string) (string, error) {
+	for _, re := range []*regexp.Regexp{serverVersionRegexp, serverSectionRegexp} {
+		if m := re.FindStringSubmatch(output); m != nil {
+			return m[1], nil
+		}
+	}
+
+	return "", fmt.Errorf("unable to find the server version in: %s", output)
+}
+
+// GetDockerVersion returns the version of the engine running on the machine.
+func GetDockerVersion(p Provisioner) (string, error) {
+	output, err := p.SSHCommand("sudo docker version")
+	if err != nil {
+		return "", err
+	}
+
+	return parseDockerVersion(output)
+}
+
+// machineHostname returns the hostname to give the machine, which is its
+// name unless another hostname is set.
+func machineHostname(d drivers.Driver, hostname string) string {
+	if hostname != "" {
+		return hostname
+	}
+
+	return d.GetMachineName()
+}
+
+// hostsNames returns the names of the machine in /etc/hosts: the hostname,
+// followed by the short hostname if it is fully qualified.
+func hostsNames(hostname string) string {
+	if i := strings.Index(hostname, "."); i > 0 {
+		return fmt.Sprintf("%s %s", hostname, hostname[:i])
+	}
+
+	return hostname
+}
+
 func makeDockerOptionsDir(p Provisioner) error {
 	dockerDir := p.GetDockerOptionsDir()
 	if _, err := p.SSHCommand(fmt.Sprintf("sudo mkdir -p %s", dockerDir)); err != nil {
@@ -52,7 +163,32 @@ func setRemoteAuthOptions(p Provisioner) auth.AuthOptions {
 	return authOptions
 }
 
+// getDockerPort returns the port of the daemon from the URL of the driver.
+func getDockerPort(p Provisioner) (int, error) {
+	dockerUrl, err := p.GetDriver().GetURL()
+	if err != nil {
+		return 0, err
+	}
+	u, err := url.Parse(dockerUrl)
+	if err != nil {
+		return 0, err
+	}
+	dockerPort := 2376
+	parts := strings.Split(u.Host, ":")
+	if len(parts) == 2 {
+		dPort, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return 0, err
+		}
+		dockerPort = dPort
+	}
+
+	return dockerPort, nil
+}
+
 func ConfigureAuth(p Provisioner) error {
+	defer p.GetHostLog().Stage("configure auth")()
+
 	var (
 		err error
 	)
@@ -92,7 +228,7 @@ func ConfigureAuth(p Provisioner) error {
 	// TODO: Switch to passing just authOptions to this func
 	// instead of all these individual fields
 	err = utils.GenerateCert(
-		[]string{ip},
+		append([]string{ip}, authOptions.ServerCertSANs...),
 		authOptions.ServerCertPath,
 		authOptions.ServerKeyPath,
 		authOptions.CaCertPath,
@@ -124,47 +260,30 @@ func ConfigureAuth(p Provisioner) error {
 		return err
 	}
 
-	// printf will choke if we don't pass a format string because of the
-	// dashes, so that's the reason for the '%%s'
-	certTransferCmdFmt := "printf '%%s' '%s' | sudo tee %s"
-
 	// These ones are for Jessie and Mike <3 <3 <3
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(caCert), authOptions.CaCertRemotePath)); err != nil {
+	if err := WriteFile(p, authOptions.CaCertRemotePath, bytes.NewReader(caCert), 0644); err != nil {
 		return err
 	}
 
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(serverCert), authOptions.ServerCertRemotePath)); err != nil {
+	if err := WriteFile(p, authOptions.ServerCertRemotePath, bytes.NewReader(serverCert), 0644); err != nil {
 		return err
 	}
 
-	if _, err := p.SSHCommand(fmt.Sprintf(certTransferCmdFmt, string(serverKey), authOptions.ServerKeyRemotePath)); err != nil {
+	if err := WriteFile(p, authOptions.ServerKeyRemotePath, bytes.NewReader(serverKey), 0600); err != nil {
 		return err
 	}
 
-	dockerUrl, err := p.GetDriver().GetURL()
+	dockerPort, err := getDockerPort(p)
 	if err != nil {
 		return err
 	}
-	u, err := url.Parse(dockerUrl)
-	if err != nil {
-		return err
-	}
-	dockerPort := 2376
-	parts := strings.Split(u.Host, ":")
-	if len(parts) == 2 {
-		dPort, err := strconv.Atoi(parts[1])
-		if err != nil {
-			return err
-		}
-		dockerPort = dPort
-	}
 
 	dkrcfg, err := p.GenerateDockerOptions(dockerPort)
 	if err != nil {
 		return err
 	}
 
-	if _, err = p.SSHCommand(fmt.Sprintf("printf \"%s\" | sudo tee %s", dkrcfg.EngineOptions, dkrcfg.EngineOptionsPath)); err != nil {
+	if err := WriteFile(p, dkrcfg.EngineOptionsPath, strings.NewReader(dkrcfg.EngineOptions), 0644); err != nil {
 		return err
 	}
 
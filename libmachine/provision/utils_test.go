@@ -112,3 +112,25 @@ func TestMachineCustomPortBoot2Docker(t *testing.T) {
 		t.Errorf("expected url %s; received %s", bindUrl, url)
 	}
 }
+
+func TestParseDockerVersion(t *testing.T) {
+	cases := map[string]string{
+		"Client version: 1.7.1\nClient API version: 1.19\nServer version: 1.7.0\nServer API version: 1.19\n":         "1.7.0",
+		"Client:\n Version:      1.8.2\n API version:  1.20\n\nServer:\n Version:      1.8.1\n API version:  1.20\n": "1.8.1",
+	}
+
+	for output, expected := range cases {
+		version, err := parseDockerVersion(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if version != expected {
+			t.Fatalf("expected version %s; received %s", expected, version)
+		}
+	}
+
+	if _, err := parseDockerVersion("Client version: 1.7.1\nCannot connect to the Docker daemon.\n"); err == nil {
+		t.Fatal("expected error without a server version")
+	}
+}
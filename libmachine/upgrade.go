@@ -0,0 +1,316 @@
+package libmachine
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/log"
+	"github.com/docker/machine/state"
+	"github.com/docker/machine/utils"
+)
+
+// engineCheckAttempts and engineCheckInterval bound how long an upgraded
+// engine has to respond and to start its containers again.
+var (
+	engineCheckAttempts = 30
+	engineCheckInterval = 2 * time.Second
+)
+
+// pingEngine checks that the engine at the URL responds over TLS with the
+// client certificate of the auth options.
+var pingEngine = func(engineURL string, authOptions auth.AuthOptions) error {
+	u, err := url.Parse(engineURL)
+	if err != nil {
+		return err
+	}
+
+	caCert, err := ioutil.ReadFile(authOptions.CaCertPath)
+	if err != nil {
+		return err
+	}
+
+	certPool := x509.NewCertPool()
+	certPool.AppendCertsFromPEM(caCert)
+
+	keypair, err := tls.LoadX509KeyPair(authOptions.ClientCertPath, authOptions.ClientKeyPath)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:      certPool,
+				Certificates: []tls.Certificate{keypair},
+			},
+		},
+	}
+
+	resp, err := client.Get(fmt.Sprintf("https://%s/_ping", u.Host))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// UpgradePlan is what upgrading the engine of a host changes.
+type UpgradePlan struct {
+	Name string
+
+	// CurrentVersion is the version of the engine before the upgrade,
+	// which it is rolled back to if the upgrade fails.
+	CurrentVersion string
+
+	// TargetVersion is the version to upgrade to, or empty for the latest.
+	TargetVersion string
+
+	// ReplacesISO is set when the upgrade replaces the boot2docker ISO of
+	// the machine, which restarts it.
+	ReplacesISO bool
+
+	// Containers are the running containers with a restart policy, which
+	// must be running again after the upgrade.
+	Containers []string
+}
+
+func (p *UpgradePlan) String() string {
+	target := p.TargetVersion
+	if target == "" {
+		target = "the latest version"
+	}
+
+	lines := []string{fmt.Sprintf("%s: docker %s would be upgraded to %s", p.Name, p.CurrentVersion, target)}
+
+	if p.ReplacesISO {
+		lines = append(lines, fmt.Sprintf("%s: the boot2docker ISO would be replaced, which restarts the machine", p.Name))
+	}
+
+	for _, id := range p.Containers {
+		lines = append(lines, fmt.Sprintf("%s: container %s would be checked to be running again", p.Name, utils.TruncateID(id)))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (h *Host) upgradeProvisioner() (provision.Provisioner, error) {
+	machineState, err := h.Driver.GetState()
+	if err != nil {
+		return nil, err
+	}
+
+	if machineState != state.Running {
+		return nil, errMachineMustBeRunningForUpgrade
+	}
+
+	return h.detectProvisioner()
+}
+
+func (h *Host) planUpgrade(provisioner provision.Provisioner) (*UpgradePlan, error) {
+	currentVersion, err := provision.GetDockerVersion(provisioner)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the engine version to roll back to: %s", err)
+	}
+
+	containers, err := provision.GetRestartingContainers(provisioner)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the running containers: %s", err)
+	}
+
+	// upgrade to the version the machine is pinned to, if any, which is
+	// saved so that provisioning the machine again does not undo it
+	targetVersion := ""
+	if h.HostOptions != nil && h.HostOptions.EngineOptions != nil {
+		targetVersion = h.HostOptions.EngineOptions.Version
+	}
+
+	_, replacesISO := provisioner.(*provision.Boot2DockerProvisioner)
+
+	return &UpgradePlan{
+		Name:           h.Name,
+		CurrentVersion: currentVersion,
+		TargetVersion:  targetVersion,
+		ReplacesISO:    replacesISO,
+		Containers:     containers,
+	}, nil
+}
+
+// PinEngineVersion pins the host to the engine version, which its upgrades
+// and provisionings install from then on, or unpins it if the version is
+// empty.  The pin is saved with the config of the host by Upgrade, unless
+// the upgrade fails, in which case the previous pin is put back.
+func (h *Host) PinEngineVersion(version string) {
+	if h.HostOptions == nil || h.HostOptions.EngineOptions == nil {
+		return
+	}
+
+	if h.previousPin == nil {
+		previous := h.HostOptions.EngineOptions.Version
+		h.previousPin = &previous
+	}
+
+	h.HostOptions.EngineOptions.Version = version
+}
+
+// PlanUpgrade returns what upgrading the engine of the host would change,
+// without changing anything.
+func (h *Host) PlanUpgrade() (*UpgradePlan, error) {
+	provisioner, err := h.upgradeProvisioner()
+	if err != nil {
+		return nil, err
+	}
+
+	return h.planUpgrade(provisioner)
+}
+
+// Upgrade upgrades the engine of the host and checks that it responds over
+// TLS and that the containers with a restart policy run again.  If not, the
+// engine is rolled back to the version it ran before.
+func (h *Host) Upgrade() error {
+	defer h.startProvisionLog("upgrade")()
+
+	provisioner, err := h.upgradeProvisioner()
+	if err != nil {
+		return err
+	}
+
+	plan, err := h.planUpgrade(provisioner)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Upgrading docker %s on %s...", plan.CurrentVersion, h.Name)
+
+	if err := h.upgradeEngine(provisioner, plan); err != nil {
+		return h.rollbackUpgrade(provisioner, plan, err)
+	}
+
+	h.recordEngineVersion(provisioner)
+	h.previousPin = nil
+
+	return h.SaveConfig()
+}
+
+func (h *Host) upgradeEngine(provisioner provision.Provisioner, plan *UpgradePlan) error {
+	if err := provisioner.UpgradeDocker(plan.TargetVersion); err != nil {
+		return err
+	}
+
+	if err := provisioner.Service("docker", pkgaction.Restart); err != nil {
+		return err
+	}
+
+	return h.checkEngine(provisioner, plan.Containers)
+}
+
+// rollbackUpgrade puts back the engine the host ran before an upgrade which
+// failed with upgradeErr.
+func (h *Host) rollbackUpgrade(provisioner provision.Provisioner, plan *UpgradePlan, upgradeErr error) error {
+	log.Warnf("Error upgrading %s, rolling back to docker %s: %s", h.Name, plan.CurrentVersion, upgradeErr)
+
+	// there is no package to roll back if the version did not change, e.g.
+	// when only the containers did not start again
+	rollback := plan.ReplacesISO
+	if !rollback {
+		version, err := provision.GetDockerVersion(provisioner)
+		rollback = err != nil || version != plan.CurrentVersion
+	}
+
+	if rollback {
+		if err := provisioner.RollbackDocker(plan.CurrentVersion); err != nil {
+			return fmt.Errorf("error upgrading %s: %s; rolling back failed: %s", h.Name, upgradeErr, err)
+		}
+	}
+
+	if err := provisioner.Service("docker", pkgaction.Restart); err != nil {
+		return fmt.Errorf("error upgrading %s: %s; rolling back failed: %s", h.Name, upgradeErr, err)
+	}
+
+	if err := h.checkEngine(provisioner, plan.Containers); err != nil {
+		return fmt.Errorf("error upgrading %s: %s; rolled back to docker %s, which fails too: %s", h.Name, upgradeErr, plan.CurrentVersion, err)
+	}
+
+	h.recordEngineVersion(provisioner)
+
+	// the version which failed must not stay pinned, or provisioning the
+	// machine again would install it without any check
+	if h.previousPin != nil {
+		h.HostOptions.EngineOptions.Version = *h.previousPin
+		h.previousPin = nil
+	}
+
+	if err := h.SaveConfig(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("error upgrading %s, rolled back to docker %s: %s", h.Name, plan.CurrentVersion, upgradeErr)
+}
+
+// checkEngine waits for the engine of the host to respond over TLS and to
+// run the containers again.
+func (h *Host) checkEngine(provisioner provision.Provisioner, containers []string) error {
+	engineURL, err := h.GetURL()
+	if err != nil {
+		return err
+	}
+
+	var pingErr error
+	if err := utils.WaitForSpecific(func() bool {
+		pingErr = pingEngine(engineURL, *h.HostOptions.AuthOptions)
+		return pingErr == nil
+	}, engineCheckAttempts, engineCheckInterval); err != nil {
+		return fmt.Errorf("the engine does not respond over TLS: %s", pingErr)
+	}
+
+	missing := containers
+	if err := utils.WaitForSpecific(func() bool {
+		running, err := provision.GetRunningContainers(provisioner)
+		if err != nil {
+			return false
+		}
+
+		missing = missingContainers(containers, running)
+		return len(missing) == 0
+	}, engineCheckAttempts, engineCheckInterval); err != nil {
+		ids := []string{}
+		for _, id := range missing {
+			ids = append(ids, utils.TruncateID(id))
+		}
+
+		return fmt.Errorf("the containers %s are not running again", strings.Join(ids, ", "))
+	}
+
+	return nil
+}
+
+// missingContainers returns the containers which are not running.
+func missingContainers(containers, running []string) []string {
+	isRunning := map[string]bool{}
+	for _, id := range running {
+		isRunning[id] = true
+	}
+
+	missing := []string{}
+	for _, id := range containers {
+		if !isRunning[id] {
+			missing = append(missing, id)
+		}
+	}
+
+	return missing
+}
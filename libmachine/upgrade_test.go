@@ -0,0 +1,316 @@
+package libmachine
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/ssh/sshtest"
+)
+
+const testContainerID = "3f4ab8c21d2e5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b"
+
+// newTestUpgradeHost returns an Ubuntu host running docker 1.8.2 and a
+// container with a restart policy, which runs as long as running returns
+// true.  Installing a version with apt switches the version of the host.
+func newTestUpgradeHost(t *testing.T, running func(version string) bool) (*Host, *sshtest.Server) {
+	server, err := sshtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version := "1.8.2"
+
+	server.Handle(`^cat /etc/os-release$`, "ID=ubuntu\n", 0)
+	server.HandleFunc(`^sudo docker version$`, func(cmd sshtest.Command) (string, int) {
+		return "Server version: " + version + "\n", 0
+	})
+	server.HandleFunc(`^apt-cache madison docker-engine`, func(cmd sshtest.Command) (string, int) {
+		return regexp.MustCompile(`"([0-9.]+)-"`).FindStringSubmatch(cmd.Command)[1] + "-0~trusty\n", 0
+	})
+	server.HandleFunc(`apt-get install .*docker-engine=`, func(cmd sshtest.Command) (string, int) {
+		version = regexp.MustCompile(`docker-engine=([0-9.]+)-`).FindStringSubmatch(cmd.Command)[1]
+		return "", 0
+	})
+	server.HandleFunc(`^sudo docker ps -q --no-trunc$`, func(cmd sshtest.Command) (string, int) {
+		if running(version) {
+			return testContainerID + "\n", 0
+		}
+		return "", 0
+	})
+	server.Handle(`^sudo docker inspect -f '{{.Id}} {{.HostConfig.RestartPolicy.Name}}' `+testContainerID+`$`, testContainerID+" always\n", 0)
+
+	storePath, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host := &Host{
+		Name:      hostTestName,
+		Driver:    sshtest.NewDriver(server, hostTestName),
+		StorePath: storePath,
+		HostOptions: &HostOptions{
+			EngineOptions: &engine.EngineOptions{Version: "1.9.0"},
+			AuthOptions:   &auth.AuthOptions{},
+		},
+	}
+
+	return host, server
+}
+
+func stubPingEngine() func() {
+	previousPing, previousAttempts, previousInterval := pingEngine, engineCheckAttempts, engineCheckInterval
+
+	pingEngine = func(engineURL string, authOptions auth.AuthOptions) error {
+		return nil
+	}
+	engineCheckAttempts = 2
+	engineCheckInterval = 0
+
+	return func() {
+		pingEngine, engineCheckAttempts, engineCheckInterval = previousPing, previousAttempts, previousInterval
+	}
+}
+
+func TestPlanUpgrade(t *testing.T) {
+	host, server := newTestUpgradeHost(t, func(string) bool { return true })
+	defer server.Close()
+	defer os.RemoveAll(host.StorePath)
+
+	plan, err := host.PlanUpgrade()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plan.CurrentVersion != "1.8.2" || plan.TargetVersion != "1.9.0" || plan.ReplacesISO || len(plan.Containers) != 1 {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+
+	expected := hostTestName + ": docker 1.8.2 would be upgraded to 1.9.0\n" +
+		hostTestName + ": container 3f4ab8c21d2e would be checked to be running again"
+	if plan.String() != expected {
+		t.Fatalf("expected %q; received %q", expected, plan.String())
+	}
+
+	if found := server.Find(`apt-get|service docker`); len(found) != 0 {
+		t.Fatalf("expected nothing to change; received:\n%s", server)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	defer stubPingEngine()()
+
+	host, server := newTestUpgradeHost(t, func(string) bool { return true })
+	defer server.Close()
+	defer os.RemoveAll(host.StorePath)
+
+	if err := host.Upgrade(); err != nil {
+		t.Fatal(err)
+	}
+
+	if host.EngineVersion != "1.9.0" {
+		t.Fatalf("expected the new engine version to be recorded; received %q", host.EngineVersion)
+	}
+
+	if found := server.Find(`"1\.8\.2-"`); len(found) != 0 {
+		t.Fatalf("expected no rollback; received:\n%s", server)
+	}
+}
+
+func TestUpgradeRollback(t *testing.T) {
+	defer stubPingEngine()()
+
+	// the container does not start again with the new version
+	host, server := newTestUpgradeHost(t, func(version string) bool { return version == "1.8.2" })
+	defer server.Close()
+	defer os.RemoveAll(host.StorePath)
+
+	err := host.Upgrade()
+	if err == nil || !strings.Contains(err.Error(), "rolled back to docker 1.8.2") || !strings.Contains(err.Error(), "3f4ab8c21d2e") {
+		t.Fatalf("expected the upgrade to be rolled back; received %v", err)
+	}
+
+	if host.EngineVersion != "1.8.2" {
+		t.Fatalf("expected the previous engine version to be recorded; received %q", host.EngineVersion)
+	}
+
+	if found := server.Find(`^sudo service docker restart$`); len(found) != 2 {
+		t.Fatalf("expected docker to be restarted after the upgrade and the rollback; received:\n%s", server)
+	}
+}
+
+func TestUpgradeRollbackFails(t *testing.T) {
+	defer stubPingEngine()()
+
+	// the container only runs before the upgrade
+	calls := 0
+	host, server := newTestUpgradeHost(t, func(string) bool {
+		calls++
+		return calls == 1
+	})
+	defer server.Close()
+	defer os.RemoveAll(host.StorePath)
+
+	err := host.Upgrade()
+	if err == nil || !strings.Contains(err.Error(), "which fails too") {
+		t.Fatalf("expected the rollback to fail; received %v", err)
+	}
+}
+
+func TestUpgradeProvisionLog(t *testing.T) {
+	defer stubPingEngine()()
+
+	host, server := newTestUpgradeHost(t, func(string) bool { return true })
+	defer server.Close()
+	defer os.RemoveAll(host.StorePath)
+
+	if err := host.Upgrade(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(host.ProvisionLogPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"--- upgrade " + hostTestName, "$ sudo docker version", "| Server version: 1.9.0"} {
+		if !strings.Contains(string(data), expected) {
+			t.Fatalf("expected the log to contain %q; received:\n%s", expected, data)
+		}
+	}
+}
+
+func TestUpgradeConcurrentProvisionLogs(t *testing.T) {
+	defer stubPingEngine()()
+
+	first, firstServer := newTestUpgradeHost(t, func(string) bool { return true })
+	defer firstServer.Close()
+	defer os.RemoveAll(first.StorePath)
+
+	second, secondServer := newTestUpgradeHost(t, func(string) bool { return true })
+	defer secondServer.Close()
+	defer os.RemoveAll(second.StorePath)
+
+	second.Name = "other-machine"
+	second.HostOptions.EngineOptions.Version = "1.9.1"
+
+	// each upgrade waits for the other one to have started, which never
+	// happens if they run one after the other
+	var started sync.WaitGroup
+	started.Add(2)
+
+	bothStarted := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(bothStarted)
+	}()
+
+	for _, server := range []*sshtest.Server{firstServer, secondServer} {
+		var once sync.Once
+		server.HandleFunc(`^cat /etc/os-release$`, func(cmd sshtest.Command) (string, int) {
+			once.Do(started.Done)
+
+			select {
+			case <-bothStarted:
+				return "ID=ubuntu\n", 0
+			case <-time.After(5 * time.Second):
+				return "the other upgrade did not start", 1
+			}
+		})
+	}
+
+	errs := make(chan error, 2)
+	for _, host := range []*Host{first, second} {
+		go func(host *Host) {
+			errs <- host.Upgrade()
+		}(host)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, c := range []struct {
+		host              *Host
+		expected, foreign string
+	}{
+		{first, "| Server version: 1.9.0", "1.9.1"},
+		{second, "| Server version: 1.9.1", "1.9.0"},
+	} {
+		data, err := ioutil.ReadFile(c.host.ProvisionLogPath())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Count(string(data), "--- upgrade ") != 1 || !strings.Contains(string(data), "--- upgrade "+c.host.Name) {
+			t.Fatalf("expected the log to be of the upgrade of %s only; received:\n%s", c.host.Name, data)
+		}
+		if !strings.Contains(string(data), c.expected) || strings.Contains(string(data), c.foreign) {
+			t.Fatalf("expected the log to contain %q and not %q; received:\n%s", c.expected, c.foreign, data)
+		}
+	}
+}
+
+func TestUpgradeRollbackKeepsPreviousPin(t *testing.T) {
+	defer stubPingEngine()()
+
+	// the container does not start again with the new version
+	host, server := newTestUpgradeHost(t, func(version string) bool { return version == "1.8.2" })
+	defer server.Close()
+	defer os.RemoveAll(host.StorePath)
+
+	host.HostOptions.EngineOptions.Version = "1.8.2"
+	host.PinEngineVersion("1.9.0")
+
+	if err := host.Upgrade(); err == nil || !strings.Contains(err.Error(), "rolled back to docker 1.8.2") {
+		t.Fatalf("expected the upgrade to be rolled back; received %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(host.StorePath, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var saved struct {
+		HostOptions struct {
+			EngineOptions struct {
+				Version string
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+
+	if saved.HostOptions.EngineOptions.Version != "1.8.2" {
+		t.Fatalf("expected the machine to stay pinned to 1.8.2; received %q", saved.HostOptions.EngineOptions.Version)
+	}
+}
+
+func TestUpgradeSavesPin(t *testing.T) {
+	defer stubPingEngine()()
+
+	host, server := newTestUpgradeHost(t, func(string) bool { return true })
+	defer server.Close()
+	defer os.RemoveAll(host.StorePath)
+
+	host.HostOptions.EngineOptions.Version = "1.8.2"
+	host.PinEngineVersion("1.9.0")
+
+	if err := host.Upgrade(); err != nil {
+		t.Fatal(err)
+	}
+
+	if host.HostOptions.EngineOptions.Version != "1.9.0" {
+		t.Fatalf("expected the machine to be pinned to 1.9.0; received %q", host.HostOptions.EngineOptions.Version)
+	}
+}
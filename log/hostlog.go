@@ -0,0 +1,172 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	urlPasswordPattern = regexp.MustCompile(`(://[^:/@\s]+:)[^@\s]+@`)
+	secretEnvPattern   = regexp.MustCompile(`(?i)(\b[a-z0-9_]*(password|passwd|secret|token|key)[a-z0-9_]*=)('[^']*'|"[^"]*"|[^\s'"]+)`)
+	privateKeyPattern  = regexp.MustCompile(`(?s)-----BEGIN ([A-Z ]*)PRIVATE KEY-----.*?-----END ([A-Z ]*)PRIVATE KEY-----`)
+)
+
+// Redact masks the secrets in commands and their output before they are
+// logged: passwords in URLs, values of variables named like a password, a
+// secret, a token or a key, and private keys.
+func Redact(s string) string {
+	s = privateKeyPattern.ReplaceAllString(s, "-----BEGIN ${1}PRIVATE KEY-----[redacted]-----END ${2}PRIVATE KEY-----")
+	s = urlPasswordPattern.ReplaceAllString(s, "${1}[redacted]@")
+	return secretEnvPattern.ReplaceAllString(s, "${1}[redacted]")
+}
+
+// StageTiming is how long a stage of the provisioning of a machine took.
+// Depth is the number of stages it is nested in.
+type StageTiming struct {
+	Name     string
+	Depth    int
+	Duration time.Duration
+}
+
+// HostLog writes what is done to a machine to a file, one timestamped line
+// at a time: messages, the SSH commands run and their output, and when each
+// stage starts and ends.  All the text is redacted.  Each machine has its
+// own, passed along with its provisioner; the methods of a nil HostLog do
+// nothing, for machines which are not being logged.
+type HostLog struct {
+	mu     sync.Mutex
+	w      io.Writer
+	now    func() time.Time
+	depth  int
+	stages []StageTiming
+}
+
+func NewHostLog(w io.Writer) *HostLog {
+	return &HostLog{
+		w:   w,
+		now: time.Now,
+	}
+}
+
+func (l *HostLog) writeLines(prefix, text string) {
+	timestamp := l.now().Format(time.RFC3339)
+	indent := strings.Repeat("  ", l.depth)
+
+	for _, line := range strings.Split(strings.TrimRight(Redact(text), "\n"), "\n") {
+		fmt.Fprintf(l.w, "%s %s%s%s\n", timestamp, indent, prefix, line)
+	}
+}
+
+func (l *HostLog) Printf(fmtString string, args ...interface{}) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.writeLines("", fmt.Sprintf(fmtString, args...))
+}
+
+// Write logs the output of a command run on the machine, such as a
+// post-provision script.
+func (l *HostLog) Write(p []byte) (int, error) {
+	if l == nil {
+		return len(p), nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.writeLines("| ", string(p))
+
+	return len(p), nil
+}
+
+// LogCommand logs an SSH command run on the machine, with its output and
+// exit status.
+func (l *HostLog) LogCommand(command, output string, err error, duration time.Duration) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.writeLines("$ ", command)
+	if output != "" {
+		l.writeLines("| ", output)
+	}
+
+	if err != nil {
+		l.writeLines("", fmt.Sprintf("command failed after %s: %s", roundDuration(duration), err))
+	} else {
+		l.writeLines("", fmt.Sprintf("command succeeded after %s", roundDuration(duration)))
+	}
+}
+
+// Stage logs the start of a stage, and returns the function to call when
+// it ends, which logs and records how long it took.  Stages started before
+// the end of another one are nested in it.
+func (l *HostLog) Stage(name string) func() {
+	if l == nil {
+		return func() {}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.writeLines("", fmt.Sprintf("==> %s", name))
+
+	index := len(l.stages)
+	l.stages = append(l.stages, StageTiming{Name: name, Depth: l.depth})
+	l.depth++
+	start := l.now()
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		l.depth--
+		l.stages[index].Duration = l.now().Sub(start)
+		l.writeLines("", fmt.Sprintf("<== %s (%s)", name, roundDuration(l.stages[index].Duration)))
+	}
+}
+
+// Stages returns the stages recorded so far, in the order they started.
+func (l *HostLog) Stages() []StageTiming {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]StageTiming{}, l.stages...)
+}
+
+// Summary returns a table of how long each stage took.
+func (l *HostLog) Summary() string {
+	var buf bytes.Buffer
+
+	w := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	for _, stage := range l.Stages() {
+		fmt.Fprintf(w, "%s%s\t%s\n", strings.Repeat("  ", stage.Depth), stage.Name, roundDuration(stage.Duration))
+	}
+	w.Flush()
+
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	if d < time.Second {
+		return d - d%time.Millisecond
+	}
+	return d - d%(100*time.Millisecond)
+}
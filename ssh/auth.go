@@ -0,0 +1,141 @@
+package ssh
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/docker/machine/log"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var (
+	ErrPassphraseRequired = errors.New("SSH key is encrypted and no passphrase could be read, please add the key to ssh-agent")
+
+	// PassphrasePrompt is called to obtain the passphrase of an encrypted
+	// private key.  It reads from the terminal by default.
+	PassphrasePrompt = promptPassphrase
+
+	// Decrypting a key means asking the user, so remember the signers
+	// for the lifetime of the process instead of prompting per command.
+	signers   = make(map[string]ssh.Signer)
+	signersMu sync.Mutex
+
+	agentOnce  sync.Once
+	agentConn  agent.Agent
+	agentError error
+)
+
+func promptPassphrase(keyPath string) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return nil, ErrPassphraseRequired
+	}
+
+	fmt.Fprintf(os.Stderr, "Enter passphrase for key '%s': ", keyPath)
+	passphrase, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+
+	return passphrase, err
+}
+
+// loadSigner reads the private key at keyPath, asking for its passphrase
+// if it is encrypted.
+func loadSigner(keyPath string) (ssh.Signer, error) {
+	signersMu.Lock()
+	defer signersMu.Unlock()
+
+	if signer, ok := signers[keyPath]; ok {
+		return signer, nil
+	}
+
+	data, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := parseSigner(keyPath, data)
+	if err != nil {
+		return nil, err
+	}
+
+	signers[keyPath] = signer
+
+	return signer, nil
+}
+
+func parseSigner(keyPath string, data []byte) (ssh.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("No PEM encoded key found in %s", keyPath)
+	}
+
+	if block.Type == "OPENSSH PRIVATE KEY" {
+		return parseOpenSSHPrivateKey(block.Bytes)
+	}
+
+	if !x509.IsEncryptedPEMBlock(block) {
+		return ssh.ParsePrivateKey(data)
+	}
+
+	passphrase, err := PassphrasePrompt(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.DecryptPEMBlock(block, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decrypt %s: %s", keyPath, err)
+	}
+
+	var key interface{}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(der)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(der)
+	case "DSA PRIVATE KEY":
+		key, err = ssh.ParseDSAPrivateKey(der)
+	default:
+		return nil, fmt.Errorf("Unsupported key type %q in %s", block.Type, keyPath)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.NewSignerFromKey(key)
+}
+
+// getAgentSigners returns a callback listing the keys held by the
+// ssh-agent listening on SSH_AUTH_SOCK, or nil if there is no agent.
+func getAgentSigners() func() ([]ssh.Signer, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil
+	}
+
+	agentOnce.Do(func() {
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			agentError = err
+			return
+		}
+		agentConn = agent.NewClient(conn)
+	})
+
+	if agentError != nil {
+		log.Debugf("Unable to connect to ssh-agent at %s: %s", socket, agentError)
+		return nil
+	}
+
+	return agentConn.Signers
+}
@@ -2,9 +2,10 @@ package ssh
 
 import (
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
 	"os/exec"
+	"syscall"
 
 	"github.com/docker/docker/pkg/term"
 	"github.com/docker/machine/log"
@@ -15,6 +16,9 @@ import (
 
 type Client interface {
 	Output(command string) (string, error)
+	// OutputWithStdin runs the command with stdin connected to the given
+	// reader, e.g. to stream files to the remote host.
+	OutputWithStdin(command string, stdin io.Reader) (string, error)
 	Shell() error
 }
 
@@ -48,7 +52,6 @@ const (
 var (
 	baseSSHArgs = []string{
 		"-o", "PasswordAuthentication=no",
-		"-o", "IdentitiesOnly=yes",
 		"-o", "StrictHostKeyChecking=no",
 		"-o", "UserKnownHostsFile=/dev/null",
 		"-o", "LogLevel=quiet", // suppress "Warning: Permanently added '[localhost]:2022' (ECDSA) to the list of known hosts."
@@ -58,6 +61,23 @@ var (
 	defaultClientType SSHClientType = External
 )
 
+// ExitStatus returns the exit status of the remote command from the error
+// returned by Client.Output, or -1 if the command did not run to completion.
+func ExitStatus(err error) int {
+	switch e := err.(type) {
+	case nil:
+		return 0
+	case *ssh.ExitError:
+		return e.ExitStatus()
+	case *exec.ExitError:
+		if status, ok := e.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus()
+		}
+	}
+
+	return -1
+}
+
 func SetDefaultClient(clientType SSHClientType) {
 	// Allow over-riding of default client type, so that even if ssh binary
 	// is found in PATH we can still use the Go native implementation if
@@ -70,6 +90,11 @@ func SetDefaultClient(clientType SSHClientType) {
 	}
 }
 
+// GetDefaultClient returns the type of client NewClient creates.
+func GetDefaultClient() SSHClientType {
+	return defaultClientType
+}
+
 func NewClient(user string, host string, port int, auth *Auth) (Client, error) {
 	sshBinaryPath, err := exec.LookPath("ssh")
 	if err != nil {
@@ -104,18 +129,25 @@ func NewNativeConfig(user string, auth *Auth) (ssh.ClientConfig, error) {
 		authMethods []ssh.AuthMethod
 	)
 
-	for _, k := range auth.Keys {
-		key, err := ioutil.ReadFile(k)
-		if err != nil {
-			return ssh.ClientConfig{}, err
-		}
+	agentSigners := getAgentSigners()
+	if agentSigners != nil {
+		authMethods = append(authMethods, ssh.PublicKeysCallback(agentSigners))
+	}
 
-		privateKey, err := ssh.ParsePrivateKey(key)
+	for _, k := range auth.Keys {
+		signer, err := loadSigner(k)
 		if err != nil {
-			return ssh.ClientConfig{}, err
+			// keys which cannot be used are not fatal as long as the
+			// agent may be able to authenticate on their behalf
+			if agentSigners == nil {
+				return ssh.ClientConfig{}, err
+			}
+
+			log.Debugf("Skipping SSH key %s: %s", k, err)
+			continue
 		}
 
-		authMethods = append(authMethods, ssh.PublicKeys(privateKey))
+		authMethods = append(authMethods, ssh.PublicKeys(signer))
 	}
 
 	for _, p := range auth.Passwords {
@@ -161,6 +193,20 @@ func (client NativeClient) Output(command string) (string, error) {
 	return string(output), err
 }
 
+func (client NativeClient) OutputWithStdin(command string, stdin io.Reader) (string, error) {
+	session, err := client.session(command)
+	if err != nil {
+		return "", err
+	}
+
+	session.Stdin = stdin
+
+	output, err := session.CombinedOutput(command)
+	defer session.Close()
+
+	return string(output), err
+}
+
 func (client NativeClient) OutputWithPty(command string) (string, error) {
 	session, err := client.session(command)
 	if err != nil {
@@ -256,11 +302,23 @@ func NewExternalClient(sshBinaryPath, user, host string, port int, auth *Auth) (
 
 	// Base args take care of settings some options for us, e.g. don't use
 	// the authorized hosts file.
-	args := baseSSHArgs
+	args := append([]string{}, baseSSHArgs...)
 
-	// Specify which private keys to use to authorize the SSH request.
+	// Specify which private keys to use to authorize the SSH request.  Keys
+	// which do not exist are left out so that ssh can fall back to the
+	// identities held by ssh-agent.
+	identityArgs := []string{}
 	for _, privateKeyPath := range auth.Keys {
-		args = append(args, "-i", privateKeyPath)
+		if _, err := os.Stat(privateKeyPath); err != nil && os.Getenv("SSH_AUTH_SOCK") != "" {
+			log.Debugf("SSH key %s not found, relying on ssh-agent", privateKeyPath)
+			continue
+		}
+		identityArgs = append(identityArgs, "-i", privateKeyPath)
+	}
+
+	if len(identityArgs) > 0 {
+		args = append(args, "-o", "IdentitiesOnly=yes")
+		args = append(args, identityArgs...)
 	}
 
 	// Set which port to use for SSH.
@@ -287,6 +345,18 @@ func (client ExternalClient) Output(command string) (string, error) {
 	return string(output), err
 }
 
+func (client ExternalClient) OutputWithStdin(command string, stdin io.Reader) (string, error) {
+	args := append(client.BaseArgs, command)
+
+	cmd := exec.Command(client.BinaryPath, args...)
+	log.Debug(cmd)
+
+	cmd.Stdin = stdin
+
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
 func (client ExternalClient) Shell() error {
 	cmd := exec.Command(client.BinaryPath, client.BaseArgs...)
 	log.Debug(cmd)
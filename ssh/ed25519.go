@@ -0,0 +1,162 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/ed25519"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// The vendored ssh library predates ed25519 support, so the key type, the
+// signer and the OpenSSH private key container are implemented here.
+
+const (
+	keyAlgoED25519 = "ssh-ed25519"
+	opensshMagic   = "openssh-key-v1\x00"
+)
+
+var (
+	ErrEncryptedOpenSSHKey = errors.New("Encrypted OpenSSH private keys are not supported, please add the key to ssh-agent instead")
+)
+
+type ed25519PublicKey ed25519.PublicKey
+
+func (k ed25519PublicKey) Type() string {
+	return keyAlgoED25519
+}
+
+func (k ed25519PublicKey) Marshal() []byte {
+	return gossh.Marshal(struct {
+		Name     string
+		KeyBytes []byte
+	}{
+		keyAlgoED25519,
+		[]byte(k),
+	})
+}
+
+func (k ed25519PublicKey) Verify(data []byte, sig *gossh.Signature) error {
+	if sig.Format != keyAlgoED25519 {
+		return fmt.Errorf("ssh: signature type %s for key type %s", sig.Format, keyAlgoED25519)
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(k), data, sig.Blob) {
+		return errors.New("ssh: signature did not verify")
+	}
+
+	return nil
+}
+
+type ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+func (s *ed25519Signer) PublicKey() gossh.PublicKey {
+	return ed25519PublicKey(s.key.Public().(ed25519.PublicKey))
+}
+
+func (s *ed25519Signer) Sign(rand io.Reader, data []byte) (*gossh.Signature, error) {
+	return &gossh.Signature{
+		Format: keyAlgoED25519,
+		Blob:   ed25519.Sign(s.key, data),
+	}, nil
+}
+
+type opensshKey struct {
+	CipherName   string
+	KdfName      string
+	KdfOpts      string
+	NumKeys      uint32
+	PubKey       []byte
+	PrivKeyBlock []byte
+}
+
+type opensshED25519PrivateKey struct {
+	Check1  uint32
+	Check2  uint32
+	KeyType string
+	Pub     []byte
+	Priv    []byte
+	Comment string
+	Pad     []byte `ssh:"rest"`
+}
+
+// marshalED25519PrivateKey encodes the key in the unencrypted
+// "openssh-key-v1" format written by ssh-keygen.
+func marshalED25519PrivateKey(key ed25519.PrivateKey) []byte {
+	var check [4]byte
+	rand.Read(check[:])
+	checkInt := binary.BigEndian.Uint32(check[:])
+
+	pub := ed25519PublicKey(key.Public().(ed25519.PublicKey))
+
+	priv := opensshED25519PrivateKey{
+		Check1:  checkInt,
+		Check2:  checkInt,
+		KeyType: keyAlgoED25519,
+		Pub:     []byte(key.Public().(ed25519.PublicKey)),
+		Priv:    []byte(key),
+	}
+
+	// the private section is padded to the cipher block size, which is
+	// 8 for the "none" cipher
+	blockLen := len(gossh.Marshal(priv))
+	for i := 0; (blockLen+i)%8 != 0; i++ {
+		priv.Pad = append(priv.Pad, byte(i+1))
+	}
+
+	w := opensshKey{
+		CipherName:   "none",
+		KdfName:      "none",
+		NumKeys:      1,
+		PubKey:       pub.Marshal(),
+		PrivKeyBlock: gossh.Marshal(priv),
+	}
+
+	return append([]byte(opensshMagic), gossh.Marshal(w)...)
+}
+
+// parseOpenSSHPrivateKey decodes an unencrypted ed25519 key in the
+// "openssh-key-v1" format.
+func parseOpenSSHPrivateKey(der []byte) (gossh.Signer, error) {
+	if !bytes.HasPrefix(der, []byte(opensshMagic)) {
+		return nil, errors.New("ssh: invalid openssh private key format")
+	}
+
+	var w opensshKey
+	if err := gossh.Unmarshal(der[len(opensshMagic):], &w); err != nil {
+		return nil, err
+	}
+
+	if w.CipherName != "none" || w.KdfName != "none" {
+		return nil, ErrEncryptedOpenSSHKey
+	}
+
+	if w.NumKeys != 1 {
+		return nil, fmt.Errorf("ssh: expected 1 key in openssh private key, found %d", w.NumKeys)
+	}
+
+	var priv opensshED25519PrivateKey
+	if err := gossh.Unmarshal(w.PrivKeyBlock, &priv); err != nil {
+		return nil, err
+	}
+
+	if priv.Check1 != priv.Check2 {
+		return nil, errors.New("ssh: openssh private key check failed")
+	}
+
+	if priv.KeyType != keyAlgoED25519 {
+		return nil, fmt.Errorf("ssh: unsupported openssh private key type %q", priv.KeyType)
+	}
+
+	if len(priv.Priv) != ed25519.PrivateKeySize {
+		return nil, errors.New("ssh: invalid ed25519 private key length")
+	}
+
+	return &ed25519Signer{key: ed25519.PrivateKey(priv.Priv)}, nil
+}
@@ -1,18 +1,22 @@
 package ssh
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/md5"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"runtime"
+	"strings"
 
+	"golang.org/x/crypto/ed25519"
 	gossh "golang.org/x/crypto/ssh"
 )
 
@@ -23,36 +27,113 @@ var (
 	ErrUnableToWriteFile = errors.New("Unable to write file")
 )
 
+type KeyType string
+
+const (
+	RSA     KeyType = "rsa"
+	ECDSA   KeyType = "ecdsa"
+	ED25519 KeyType = "ed25519"
+)
+
+var (
+	keyTypes = []KeyType{RSA, ECDSA, ED25519}
+)
+
+// ParseKeyType returns the KeyType for its name, e.g. "ecdsa".
+func ParseKeyType(name string) (KeyType, error) {
+	for _, t := range keyTypes {
+		if strings.ToLower(name) == string(t) {
+			return t, nil
+		}
+	}
+
+	return "", fmt.Errorf("Unknown SSH key type %q, supported types are: %s", name, keyTypeNames())
+}
+
+func keyTypeNames() string {
+	names := []string{}
+	for _, t := range keyTypes {
+		names = append(names, string(t))
+	}
+	return strings.Join(names, ", ")
+}
+
 type KeyPair struct {
+	Type       KeyType
 	PrivateKey []byte
 	PublicKey  []byte
 }
 
-// Generate a new SSH keypair
+// Generate a new SSH keypair of the given type, RSA if it is empty
 // This will return a private & public key encoded as DER.
-func NewKeyPair() (keyPair *KeyPair, err error) {
-	priv, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return nil, ErrKeyGeneration
-	}
+func NewKeyPair(keyType KeyType) (*KeyPair, error) {
+	var (
+		privDer []byte
+		pubSSH  gossh.PublicKey
+	)
 
-	if err := priv.Validate(); err != nil {
-		return nil, ErrValidation
+	if keyType == "" {
+		keyType = RSA
 	}
 
-	privDer := x509.MarshalPKCS1PrivateKey(priv)
+	switch keyType {
+	case RSA:
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, ErrKeyGeneration
+		}
+
+		if err := priv.Validate(); err != nil {
+			return nil, ErrValidation
+		}
 
-	pubSSH, err := gossh.NewPublicKey(&priv.PublicKey)
-	if err != nil {
-		return nil, ErrPublicKey
+		privDer = x509.MarshalPKCS1PrivateKey(priv)
+
+		if pubSSH, err = gossh.NewPublicKey(&priv.PublicKey); err != nil {
+			return nil, ErrPublicKey
+		}
+	case ECDSA:
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, ErrKeyGeneration
+		}
+
+		if privDer, err = x509.MarshalECPrivateKey(priv); err != nil {
+			return nil, ErrValidation
+		}
+
+		if pubSSH, err = gossh.NewPublicKey(&priv.PublicKey); err != nil {
+			return nil, ErrPublicKey
+		}
+	case ED25519:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, ErrKeyGeneration
+		}
+
+		privDer = marshalED25519PrivateKey(priv)
+		pubSSH = ed25519PublicKey(pub)
+	default:
+		return nil, fmt.Errorf("Unknown SSH key type %q", keyType)
 	}
 
 	return &KeyPair{
+		Type:       keyType,
 		PrivateKey: privDer,
 		PublicKey:  gossh.MarshalAuthorizedKey(pubSSH),
 	}, nil
 }
 
+func (kp *KeyPair) pemType() string {
+	switch kp.Type {
+	case ECDSA:
+		return "EC PRIVATE KEY"
+	case ED25519:
+		return "OPENSSH PRIVATE KEY"
+	}
+	return "RSA PRIVATE KEY"
+}
+
 // Write keypair to files
 func (kp *KeyPair) WriteToFile(privateKeyPath string, publicKeyPath string) error {
 	files := []struct {
@@ -62,7 +143,7 @@ func (kp *KeyPair) WriteToFile(privateKeyPath string, publicKeyPath string) erro
 	}{
 		{
 			File:  privateKeyPath,
-			Value: pem.EncodeToMemory(&pem.Block{"RSA PRIVATE KEY", nil, kp.PrivateKey}),
+			Value: pem.EncodeToMemory(&pem.Block{Type: kp.pemType(), Bytes: kp.PrivateKey}),
 		},
 		{
 			File:  publicKeyPath,
@@ -92,25 +173,92 @@ func (kp *KeyPair) WriteToFile(privateKeyPath string, publicKeyPath string) erro
 	return nil
 }
 
-// Calculate the fingerprint of the public key
+// Calculate the MD5 fingerprint of the public key, as hex
 func (kp *KeyPair) Fingerprint() string {
-	b, _ := base64.StdEncoding.DecodeString(string(kp.PublicKey))
-	h := md5.New()
+	pub, err := parseAuthorizedKey(kp.PublicKey)
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%x", md5.Sum(pub))
+}
+
+// Calculate the SHA256 fingerprint of the public key in the format used by
+// OpenSSH, e.g. "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8"
+func (kp *KeyPair) FingerprintSHA256() string {
+	fingerprint, err := FingerprintSHA256(kp.PublicKey)
+	if err != nil {
+		return ""
+	}
 
-	io.WriteString(h, string(b))
+	return fingerprint
+}
+
+// FingerprintSHA256 calculates the SHA256 fingerprint of a public key in
+// authorized_keys format, such as the contents of an id_rsa.pub file.
+func FingerprintSHA256(authorizedKey []byte) (string, error) {
+	pub, err := parseAuthorizedKey(authorizedKey)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(pub)
+	return "SHA256:" + strings.TrimRight(base64.StdEncoding.EncodeToString(sum[:]), "="), nil
+}
+
+// parseAuthorizedKey returns the wire format of a public key in
+// authorized_keys format.  This is decoded by hand since the vendored
+// ssh library does not know about every key type we generate.
+func parseAuthorizedKey(authorizedKey []byte) ([]byte, error) {
+	fields := strings.Fields(string(authorizedKey))
+	if len(fields) < 2 {
+		return nil, ErrPublicKey
+	}
+
+	pub, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, ErrPublicKey
+	}
+
+	return pub, nil
+}
+
+// NormalizeAuthorizedKey checks that a public key in authorized_keys format
+// is a single key without options, and returns it as one line of the key
+// type, the key and its comment, if any.
+func NormalizeAuthorizedKey(authorizedKey []byte) (string, error) {
+	fields := strings.Fields(string(authorizedKey))
+	if len(fields) < 2 || strings.Count(strings.TrimSpace(string(authorizedKey)), "\n") != 0 {
+		return "", ErrPublicKey
+	}
+
+	pub, err := parseAuthorizedKey(authorizedKey)
+	if err != nil {
+		return "", err
+	}
+
+	// the wire format starts with the key type as a length prefixed string
+	if len(pub) < 4 {
+		return "", ErrPublicKey
+	}
+
+	n := int(pub[0])<<24 | int(pub[1])<<16 | int(pub[2])<<8 | int(pub[3])
+	if n != len(fields[0]) || len(pub) < 4+n || string(pub[4:4+n]) != fields[0] {
+		return "", ErrPublicKey
+	}
 
-	return fmt.Sprintf("%x", h.Sum(nil))
+	return strings.Join(fields, " "), nil
 }
 
-// Generate SSH keypair based on path of the private key
+// Generate SSH keypair of the given type based on path of the private key
 // The public key would be generated to the same path with ".pub" added
-func GenerateSSHKey(path string) error {
+func GenerateSSHKey(path string, keyType KeyType) error {
 	if _, err := os.Stat(path); err != nil {
 		if !os.IsNotExist(err) {
 			return err
 		}
 
-		kp, err := NewKeyPair()
+		kp, err := NewKeyPair(keyType)
 		if err != nil {
 			return err
 		}
@@ -1,17 +1,20 @@
 package ssh
 
 import (
+	"crypto/rand"
+	"crypto/x509"
 	"encoding/pem"
+	"strings"
 	"testing"
 )
 
-func TestNewKeyPair(t *testing.T) {
-	pair, err := NewKeyPair()
+func TestNewKeyPairDefault(t *testing.T) {
+	pair, err := NewKeyPair("")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if privPem := pem.EncodeToMemory(&pem.Block{"RSA PRIVATE KEY", nil, pair.PrivateKey}); len(privPem) == 0 {
+	if privPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: pair.PrivateKey}); len(privPem) == 0 {
 		t.Fatal("No PEM returned")
 	}
 
@@ -19,3 +22,124 @@ func TestNewKeyPair(t *testing.T) {
 		t.Fatal("Unable to generate fingerprint")
 	}
 }
+
+func TestNewKeyPairOfType(t *testing.T) {
+	for _, keyType := range keyTypes {
+		pair, err := NewKeyPair(keyType)
+		if err != nil {
+			t.Fatalf("%s: %s", keyType, err)
+		}
+
+		privPem := pem.EncodeToMemory(&pem.Block{Type: pair.pemType(), Bytes: pair.PrivateKey})
+
+		signer, err := parseSigner("test", privPem)
+		if err != nil {
+			t.Fatalf("%s: unable to parse generated key: %s", keyType, err)
+		}
+
+		data := []byte("machine")
+		sig, err := signer.Sign(rand.Reader, data)
+		if err != nil {
+			t.Fatalf("%s: %s", keyType, err)
+		}
+
+		if err := signer.PublicKey().Verify(data, sig); err != nil {
+			t.Fatalf("%s: signature did not verify: %s", keyType, err)
+		}
+
+		if !strings.HasPrefix(string(pair.PublicKey), signer.PublicKey().Type()+" ") {
+			t.Fatalf("%s: unexpected public key %q", keyType, pair.PublicKey)
+		}
+	}
+}
+
+func TestParseKeyType(t *testing.T) {
+	if keyType, err := ParseKeyType("ECDSA"); err != nil || keyType != ECDSA {
+		t.Fatalf("expected ecdsa; received %q %v", keyType, err)
+	}
+
+	if _, err := ParseKeyType("dsa"); err == nil {
+		t.Fatal("expected error for unsupported key type")
+	}
+}
+
+func TestFingerprintSHA256(t *testing.T) {
+	// generated with ssh-keygen -l -E sha256
+	pub := []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIJiZpumql7KL5JXDwWQCp4XOam1iWBqUd4Awaei58UHN machine@test\n")
+	expected := "SHA256:iU8oThg99hGWE6hWoXCLht68zgrLsiN44Cz4wn5gCkE"
+
+	fingerprint, err := FingerprintSHA256(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fingerprint != expected {
+		t.Fatalf("expected fingerprint %s; received %s", expected, fingerprint)
+	}
+}
+
+func TestNormalizeAuthorizedKey(t *testing.T) {
+	key := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIJiZpumql7KL5JXDwWQCp4XOam1iWBqUd4Awaei58UHN"
+
+	normalized, err := NormalizeAuthorizedKey([]byte("  " + key + "\tmachine  test\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := key + " machine test"; normalized != expected {
+		t.Fatalf("expected %q; received %q", expected, normalized)
+	}
+
+	invalid := []string{
+		"",
+		"ssh-ed25519",
+		"ssh-rsa AAAAC3NzaC1lZDI1NTE5AAAAIJiZpumql7KL5JXDwWQCp4XOam1iWBqUd4Awaei58UHN",
+		`from="10.0.0.1" ` + key,
+		key + "\n" + key,
+		"ssh-ed25519 not-base64",
+	}
+
+	for _, k := range invalid {
+		if _, err := NormalizeAuthorizedKey([]byte(k)); err == nil {
+			t.Fatalf("%q: expected an error", k)
+		}
+	}
+}
+
+func TestParseEncryptedKey(t *testing.T) {
+	pair, err := NewKeyPair(RSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", pair.PrivateKey, []byte("secret"), x509.PEMCipherAES128)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(prompt func(string) ([]byte, error)) {
+		PassphrasePrompt = prompt
+	}(PassphrasePrompt)
+
+	prompted := ""
+	PassphrasePrompt = func(keyPath string) ([]byte, error) {
+		prompted = keyPath
+		return []byte("secret"), nil
+	}
+
+	if _, err := parseSigner("/test/id_rsa", pem.EncodeToMemory(block)); err != nil {
+		t.Fatal(err)
+	}
+
+	if prompted != "/test/id_rsa" {
+		t.Fatalf("expected passphrase prompt for /test/id_rsa; received %q", prompted)
+	}
+
+	PassphrasePrompt = func(keyPath string) ([]byte, error) {
+		return []byte("wrong"), nil
+	}
+
+	if _, err := parseSigner("/test/id_rsa", pem.EncodeToMemory(block)); err == nil {
+		t.Fatal("expected error decrypting with the wrong passphrase")
+	}
+}
@@ -15,7 +15,7 @@ func TestGenerateSSHKey(t *testing.T) {
 
 	filename := filepath.Join(tmpDir, "sshkey")
 
-	if err := GenerateSSHKey(filename); err != nil {
+	if err := GenerateSSHKey(filename, RSA); err != nil {
 		t.Fatal(err)
 	}
 
@@ -0,0 +1,61 @@
+package sshtest
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/state"
+)
+
+// Driver is a fake driver whose machine is the test server.
+type Driver struct {
+	fakedriver.FakeDriver
+	Server      *Server
+	MachineName string
+}
+
+func NewDriver(server *Server, machineName string) *Driver {
+	return &Driver{
+		FakeDriver: fakedriver.FakeDriver{
+			MockState: state.Running,
+		},
+		Server:      server,
+		MachineName: machineName,
+	}
+}
+
+func (d *Driver) GetMachineName() string {
+	return d.MachineName
+}
+
+func (d *Driver) GetIP() (string, error) {
+	host, _, err := net.SplitHostPort(d.Server.Addr)
+	return host, err
+}
+
+func (d *Driver) GetURL() (string, error) {
+	return fmt.Sprintf("tcp://%s", d.Server.DockerAddr), nil
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+func (d *Driver) GetSSHPort() (int, error) {
+	_, port, err := net.SplitHostPort(d.Server.Addr)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(port)
+}
+
+func (d *Driver) GetSSHKeyPath() string {
+	return d.Server.KeyPath
+}
+
+func (d *Driver) GetSSHUsername() string {
+	return "docker"
+}
@@ -0,0 +1,337 @@
+// Package sshtest provides an in-process SSH server and a driver connected
+// to it, so that code which provisions machines over SSH can be tested
+// without a real host.
+package sshtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	machinessh "github.com/docker/machine/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+// Command is a command run on the server.
+type Command struct {
+	Command string
+	Stdin   []byte
+}
+
+// HandlerFunc returns the output and the exit status of a command.
+type HandlerFunc func(cmd Command) (string, int)
+
+type handler struct {
+	pattern *regexp.Regexp
+	fn      HandlerFunc
+}
+
+// Server is an SSH server which accepts any key and answers exec requests
+// with scripted outputs.  Commands without a matching handler succeed
+// without output.
+type Server struct {
+	// Addr is the address the SSH server listens on.
+	Addr string
+
+	// DockerAddr is the address of a listener standing in for the Docker
+	// daemon, which accepts and immediately closes connections.
+	DockerAddr string
+
+	// KeyPath is the path of a private key which may be used to log in.
+	KeyPath string
+
+	config   *ssh.ServerConfig
+	listener net.Listener
+	docker   net.Listener
+	dir      string
+	native   bool
+
+	mu       sync.Mutex
+	handlers []handler
+	commands []Command
+}
+
+var (
+	clientMu       sync.Mutex
+	servers        int
+	previousClient machinessh.SSHClientType
+)
+
+// NewServer starts a server listening on a random local port.  Since the
+// external ssh binary cannot be pointed at the server without a
+// known_hosts entry, it also switches the default client to the native one
+// until every server is closed.
+func NewServer() (*Server, error) {
+	dir, err := ioutil.TempDir("", "machine-sshtest-")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		KeyPath: filepath.Join(dir, "id_ecdsa"),
+		dir:     dir,
+	}
+
+	if err := s.start(); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	useNativeClient()
+	s.native = true
+
+	return s, nil
+}
+
+func (s *Server) start() error {
+	kp, err := machinessh.NewKeyPair(machinessh.ECDSA)
+	if err != nil {
+		return err
+	}
+
+	if err := kp.WriteToFile(s.KeyPath, s.KeyPath+".pub"); err != nil {
+		return err
+	}
+
+	hostKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		return err
+	}
+
+	s.config = &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	s.config.AddHostKey(signer)
+
+	if s.listener, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		return err
+	}
+	s.Addr = s.listener.Addr().String()
+
+	if s.docker, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		return err
+	}
+	s.DockerAddr = s.docker.Addr().String()
+
+	go s.serve()
+	go s.serveDocker()
+
+	return nil
+}
+
+func useNativeClient() {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+
+	if servers == 0 {
+		previousClient = machinessh.GetDefaultClient()
+		machinessh.SetDefaultClient(machinessh.Native)
+	}
+	servers++
+}
+
+func restoreClient() {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+
+	servers--
+	if servers == 0 {
+		machinessh.SetDefaultClient(previousClient)
+	}
+}
+
+// Close stops the server, removes its key and, once no other server is
+// running, restores the default client.
+func (s *Server) Close() {
+	if s.native {
+		s.native = false
+		restoreClient()
+	}
+
+	if s.listener != nil {
+		s.listener.Close()
+	}
+
+	if s.docker != nil {
+		s.docker.Close()
+	}
+
+	os.RemoveAll(s.dir)
+}
+
+// Handle makes commands matching the regular expression print output and
+// exit with status.  Handlers added later take precedence.
+func (s *Server) Handle(pattern, output string, status int) {
+	s.HandleFunc(pattern, func(cmd Command) (string, int) {
+		return output, status
+	})
+}
+
+func (s *Server) HandleFunc(pattern string, fn HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers = append(s.handlers, handler{
+		pattern: regexp.MustCompile(pattern),
+		fn:      fn,
+	})
+}
+
+// Commands returns the commands run so far, in order.
+func (s *Server) Commands() []Command {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Command{}, s.commands...)
+}
+
+// Find returns the commands run so far which match the regular expression.
+func (s *Server) Find(pattern string) []Command {
+	re := regexp.MustCompile(pattern)
+
+	found := []Command{}
+	for _, cmd := range s.Commands() {
+		if re.MatchString(cmd.Command) {
+			found = append(found, cmd)
+		}
+	}
+
+	return found
+}
+
+// Reset forgets the commands run so far.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commands = nil
+}
+
+// String lists the commands run so far, which is useful in test failures.
+func (s *Server) String() string {
+	lines := []string{}
+	for _, cmd := range s.Commands() {
+		lines = append(lines, cmd.Command)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (s *Server) run(cmd Command) (string, int) {
+	s.mu.Lock()
+	s.commands = append(s.commands, cmd)
+
+	var fn HandlerFunc
+	for i := len(s.handlers) - 1; i >= 0; i-- {
+		if s.handlers[i].pattern.MatchString(cmd.Command) {
+			fn = s.handlers[i].fn
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if fn == nil {
+		return "", 0
+	}
+
+	return fn(cmd)
+}
+
+func (s *Server) serveDocker() {
+	for {
+		conn, err := s.docker.Accept()
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	serverConn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer serverConn.Close()
+
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, fmt.Sprintf("unsupported channel type %s", newChannel.ChannelType()))
+			continue
+		}
+
+		ch, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handleSession(ch, requests)
+	}
+}
+
+type execMsg struct {
+	Command string
+}
+
+type exitStatusMsg struct {
+	Status uint32
+}
+
+func (s *Server) handleSession(ch ssh.Channel, requests <-chan *ssh.Request) {
+	defer ch.Close()
+
+	for req := range requests {
+		switch req.Type {
+		case "exec":
+			var msg execMsg
+			if err := ssh.Unmarshal(req.Payload, &msg); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+
+			stdin, _ := ioutil.ReadAll(ch)
+
+			output, status := s.run(Command{
+				Command: msg.Command,
+				Stdin:   stdin,
+			})
+
+			ch.Write([]byte(output))
+			ch.SendRequest("exit-status", false, ssh.Marshal(&exitStatusMsg{uint32(status)}))
+			return
+		case "pty-req", "env":
+			req.Reply(true, nil)
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
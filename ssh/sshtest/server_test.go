@@ -0,0 +1,94 @@
+package sshtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers"
+	"github.com/docker/machine/ssh"
+)
+
+func TestServer(t *testing.T) {
+	server, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	server.Handle(`^cat /etc/os-release$`, "ID=ubuntu\n", 0)
+	server.Handle(`^false$`, "nope\n", 1)
+	server.HandleFunc(`^tee `, func(cmd Command) (string, int) {
+		return string(cmd.Stdin), 0
+	})
+
+	d := NewDriver(server, "test")
+
+	output, err := drivers.RunSSHCommandFromDriver(d, "cat /etc/os-release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "ID=ubuntu\n" {
+		t.Fatalf("unexpected output %q", output)
+	}
+
+	output, err = drivers.RunSSHCommandFromDriver(d, "false")
+	if status := ssh.ExitStatus(err); status != 1 {
+		t.Fatalf("expected exit status 1; received %d (%v)", status, err)
+	}
+	if output != "nope\n" {
+		t.Fatalf("unexpected output %q", output)
+	}
+
+	if _, err := drivers.RunSSHCommandFromDriver(d, "unknown"); err != nil {
+		t.Fatalf("expected unhandled commands to succeed; received %s", err)
+	}
+
+	client, err := drivers.GetSSHClientFromDriver(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err = client.OutputWithStdin("tee /tmp/foo", strings.NewReader("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != "data" {
+		t.Fatalf("unexpected output %q", output)
+	}
+
+	commands := server.Commands()
+	if len(commands) != 4 {
+		t.Fatalf("expected 4 commands; received:\n%s", server)
+	}
+
+	if found := server.Find(`^tee`); len(found) != 1 || string(found[0].Stdin) != "data" {
+		t.Fatalf("expected tee to receive data; received %+v", found)
+	}
+}
+
+func TestServerRestoresDefaultClient(t *testing.T) {
+	previous := ssh.GetDefaultClient()
+	ssh.SetDefaultClient(ssh.External)
+	defer ssh.SetDefaultClient(previous)
+
+	first, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewServer()
+	if err != nil {
+		first.Close()
+		t.Fatal(err)
+	}
+
+	first.Close()
+	if client := ssh.GetDefaultClient(); client != ssh.Native {
+		t.Fatalf("expected the native client while a server is running; received %q", client)
+	}
+
+	second.Close()
+	second.Close()
+	if client := ssh.GetDefaultClient(); client != ssh.External {
+		t.Fatalf("expected the external client to be restored; received %q", client)
+	}
+}
@@ -0,0 +1,289 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/machine/log"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	DockerSocketPath = "/var/run/docker.sock"
+)
+
+// Forward describes a connection forwarded from a local listener to an
+// address on the remote host.  Networks are either "tcp" or "unix".
+type Forward struct {
+	LocalNetwork  string
+	LocalAddr     string
+	RemoteNetwork string
+	RemoteAddr    string
+}
+
+func (f Forward) String() string {
+	return fmt.Sprintf("%s:%s -> %s:%s", f.LocalNetwork, f.LocalAddr, f.RemoteNetwork, f.RemoteAddr)
+}
+
+// ParseForward parses a forward specification in a form similar to the one
+// used by ssh -L, where paths starting with a slash denote unix sockets:
+//
+//	8080:localhost:80
+//	0.0.0.0:8080:localhost:80
+//	2375:/var/run/docker.sock
+//	/tmp/docker.sock:/var/run/docker.sock
+func ParseForward(spec string) (Forward, error) {
+	var (
+		forward Forward
+		local   string
+	)
+
+	parts := strings.Split(spec, ":")
+
+	if strings.HasPrefix(parts[len(parts)-1], "/") {
+		forward.RemoteNetwork = "unix"
+		forward.RemoteAddr = parts[len(parts)-1]
+		local = strings.Join(parts[:len(parts)-1], ":")
+	} else {
+		if len(parts) < 3 {
+			return forward, fmt.Errorf("Invalid forward %q, expected [bind_address:]port:host:hostport", spec)
+		}
+		forward.RemoteNetwork = "tcp"
+		forward.RemoteAddr = net.JoinHostPort(parts[len(parts)-2], parts[len(parts)-1])
+		local = strings.Join(parts[:len(parts)-2], ":")
+
+		if _, err := strconv.ParseUint(parts[len(parts)-1], 10, 16); err != nil {
+			return forward, fmt.Errorf("Invalid remote port in forward %q", spec)
+		}
+	}
+
+	localNetwork, localAddr, err := parseLocalAddr(local)
+	if err != nil {
+		return forward, fmt.Errorf("Invalid local address in forward %q: %s", spec, err)
+	}
+
+	forward.LocalNetwork, forward.LocalAddr = localNetwork, localAddr
+
+	return forward, nil
+}
+
+// parseLocalAddr parses the local end of a forward: a port, an
+// address:port pair or a unix socket path.
+func parseLocalAddr(local string) (string, string, error) {
+	if strings.HasPrefix(local, "/") || strings.HasPrefix(local, ".") {
+		return "unix", local, nil
+	}
+
+	host := "127.0.0.1"
+	port := local
+	if i := strings.LastIndex(local, ":"); i != -1 {
+		host, port = local[:i], local[i+1:]
+	}
+
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil || host == "" {
+		return "", "", fmt.Errorf("expected [bind_address:]port or a socket path, got %q", local)
+	}
+
+	return "tcp", net.JoinHostPort(host, port), nil
+}
+
+// NewDockerForward returns the forward of the remote docker socket to the
+// given local socket path or TCP port.
+func NewDockerForward(local string) (Forward, error) {
+	return ParseForward(fmt.Sprintf("%s:%s", local, DockerSocketPath))
+}
+
+// Tunnel forwards connections over a single native SSH connection.
+type Tunnel struct {
+	Forwards []Forward
+
+	config    ssh.ClientConfig
+	addr      string
+	client    *ssh.Client
+	listeners []net.Listener
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+func NewTunnel(user, host string, port int, auth *Auth, forwards []Forward) (*Tunnel, error) {
+	config, err := NewNativeConfig(user, auth)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting config for native Go SSH: %s", err)
+	}
+
+	return &Tunnel{
+		Forwards: forwards,
+		config:   config,
+		addr:     fmt.Sprintf("%s:%d", host, port),
+		closing:  make(chan struct{}),
+	}, nil
+}
+
+// Listen connects to the remote host and opens the local end of every
+// forward.  Connections are not accepted until Serve is called.
+func (t *Tunnel) Listen() error {
+	client, err := ssh.Dial("tcp", t.addr, &t.config)
+	if err != nil {
+		return fmt.Errorf("Error dialing SSH for tunnel: %s", err)
+	}
+	t.client = client
+
+	for _, f := range t.Forwards {
+		if f.LocalNetwork == "unix" {
+			// a socket left behind by a tunnel which did not exit cleanly
+			// would make the listen fail
+			os.Remove(f.LocalAddr)
+		}
+
+		l, err := net.Listen(f.LocalNetwork, f.LocalAddr)
+		if err != nil {
+			t.Close()
+			return fmt.Errorf("Error listening on %s: %s", f.LocalAddr, err)
+		}
+
+		if f.LocalNetwork == "unix" {
+			if err := os.Chmod(f.LocalAddr, 0600); err != nil {
+				t.Close()
+				return err
+			}
+		}
+
+		t.listeners = append(t.listeners, l)
+	}
+
+	return nil
+}
+
+// Serve accepts connections on every forward until the tunnel is closed or
+// the SSH connection is lost.
+func (t *Tunnel) Serve() error {
+	errChan := make(chan error, len(t.listeners)+1)
+
+	for i, l := range t.listeners {
+		go t.accept(l, t.Forwards[i], errChan)
+	}
+
+	go func() {
+		errChan <- t.client.Wait()
+	}()
+
+	select {
+	case err := <-errChan:
+		t.Close()
+		if err == nil {
+			err = io.EOF
+		}
+		return fmt.Errorf("Tunnel closed: %s", err)
+	case <-t.closing:
+		return nil
+	}
+}
+
+// Run is a shorthand for Listen followed by Serve.
+func (t *Tunnel) Run() error {
+	if err := t.Listen(); err != nil {
+		return err
+	}
+
+	return t.Serve()
+}
+
+func (t *Tunnel) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.closing)
+
+		for i, l := range t.listeners {
+			l.Close()
+			if t.Forwards[i].LocalNetwork == "unix" {
+				os.Remove(t.Forwards[i].LocalAddr)
+			}
+		}
+
+		if t.client != nil {
+			t.client.Close()
+		}
+	})
+
+	return nil
+}
+
+func (t *Tunnel) accept(l net.Listener, f Forward, errChan chan<- error) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		go t.forward(conn, f)
+	}
+}
+
+func (t *Tunnel) forward(local net.Conn, f Forward) {
+	defer local.Close()
+
+	remote, err := t.dialRemote(f)
+	if err != nil {
+		log.Errorf("Error forwarding %s: %s", f, err)
+		return
+	}
+	defer remote.Close()
+
+	log.Debugf("Forwarding connection %s", f)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, local)
+		remote.CloseWrite()
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+
+	<-done
+	<-done
+}
+
+type closeWriter interface {
+	io.ReadWriteCloser
+	CloseWrite() error
+}
+
+// streamLocalChannelOpenDirectMsg is the payload of the OpenSSH extension
+// used to connect to a unix socket on the remote host, see PROTOCOL section
+// 2.4 in the OpenSSH sources.
+type streamLocalChannelOpenDirectMsg struct {
+	SocketPath string
+	Reserved0  string
+	Reserved1  uint32
+}
+
+func (t *Tunnel) dialRemote(f Forward) (closeWriter, error) {
+	if f.RemoteNetwork == "unix" {
+		msg := streamLocalChannelOpenDirectMsg{
+			SocketPath: f.RemoteAddr,
+		}
+
+		ch, reqs, err := t.client.OpenChannel("direct-streamlocal@openssh.com", ssh.Marshal(&msg))
+		if err != nil {
+			return nil, err
+		}
+		go ssh.DiscardRequests(reqs)
+
+		return ch, nil
+	}
+
+	conn, err := t.client.Dial("tcp", f.RemoteAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.(closeWriter), nil
+}
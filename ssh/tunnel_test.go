@@ -0,0 +1,59 @@
+package ssh
+
+import (
+	"testing"
+)
+
+func TestParseForward(t *testing.T) {
+	cases := []struct {
+		spec     string
+		expected Forward
+	}{
+		{
+			"8080:localhost:80",
+			Forward{"tcp", "127.0.0.1:8080", "tcp", "localhost:80"},
+		},
+		{
+			"0.0.0.0:8080:10.0.0.5:80",
+			Forward{"tcp", "0.0.0.0:8080", "tcp", "10.0.0.5:80"},
+		},
+		{
+			"2375:/var/run/docker.sock",
+			Forward{"tcp", "127.0.0.1:2375", "unix", "/var/run/docker.sock"},
+		},
+		{
+			"/tmp/docker.sock:/var/run/docker.sock",
+			Forward{"unix", "/tmp/docker.sock", "unix", "/var/run/docker.sock"},
+		},
+	}
+
+	for _, c := range cases {
+		f, err := ParseForward(c.spec)
+		if err != nil {
+			t.Fatalf("%s: %s", c.spec, err)
+		}
+
+		if f != c.expected {
+			t.Fatalf("%s: expected %s; received %s", c.spec, c.expected, f)
+		}
+	}
+}
+
+func TestParseForwardInvalid(t *testing.T) {
+	for _, spec := range []string{"8080", "8080:localhost", "foo:localhost:80", "8080:localhost:http", ":/var/run/docker.sock"} {
+		if _, err := ParseForward(spec); err == nil {
+			t.Fatalf("expected error parsing %q", spec)
+		}
+	}
+}
+
+func TestNewDockerForward(t *testing.T) {
+	f, err := NewDockerForward("2375")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.RemoteNetwork != "unix" || f.RemoteAddr != DockerSocketPath {
+		t.Fatalf("expected forward to the docker socket; received %s", f)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/docker/machine/log"
@@ -94,6 +95,12 @@ func (b *B2dUtils) GetLatestBoot2DockerReleaseURL() (string, error) {
 	return isoUrl, nil
 }
 
+// GetBoot2DockerReleaseURL returns the URL of the boot2docker ISO released
+// for the given docker version, e.g. "1.8.2".
+func (b *B2dUtils) GetBoot2DockerReleaseURL(version string) string {
+	return fmt.Sprintf("%s/boot2docker/boot2docker/releases/download/v%s/boot2docker.iso", b.githubBaseUrl, strings.TrimPrefix(version, "v"))
+}
+
 func removeFileIfExists(name string) error {
 	if _, err := os.Stat(name); err == nil {
 		if err := os.Remove(name); err != nil {
@@ -223,3 +230,25 @@ func (b *B2dUtils) copyDefaultIsoToMachine(machineIsoPath string) error {
 
 	return nil
 }
+
+func (b *B2dUtils) machineIsoPath(machineName string) string {
+	return filepath.Join(GetMachineDir(), machineName, b.isoFilename)
+}
+
+// BackupMachineIso keeps a copy of the ISO of the machine, which
+// RestoreMachineIso puts back, e.g. when an upgrade fails.
+func (b *B2dUtils) BackupMachineIso(machineName string) error {
+	isoPath := b.machineIsoPath(machineName)
+	return CopyFile(isoPath, isoPath+".previous")
+}
+
+// RestoreMachineIso replaces the ISO of the machine with the copy kept by
+// BackupMachineIso.
+func (b *B2dUtils) RestoreMachineIso(machineName string) error {
+	isoPath := b.machineIsoPath(machineName)
+	if _, err := os.Stat(isoPath + ".previous"); err != nil {
+		return fmt.Errorf("no previous ISO to restore: %s", err)
+	}
+
+	return os.Rename(isoPath+".previous", isoPath)
+}
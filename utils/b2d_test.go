@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"testing"
 )
@@ -28,6 +29,17 @@ func TestGetLatestBoot2DockerReleaseUrl(t *testing.T) {
 	}
 }
 
+func TestGetBoot2DockerReleaseURL(t *testing.T) {
+	b := NewB2dUtils("", "https://example.com")
+
+	expectedUrl := "https://example.com/boot2docker/boot2docker/releases/download/v1.8.2/boot2docker.iso"
+	for _, version := range []string{"1.8.2", "v1.8.2"} {
+		if isoUrl := b.GetBoot2DockerReleaseURL(version); isoUrl != expectedUrl {
+			t.Fatalf("expected url %s; received %s", expectedUrl, isoUrl)
+		}
+	}
+}
+
 func TestDownloadIso(t *testing.T) {
 	testData := "test-download"
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -56,3 +68,49 @@ func TestDownloadIso(t *testing.T) {
 		t.Fatalf("expected data \"%s\"; received \"%s\"", testData, string(data))
 	}
 }
+
+func TestBackupRestoreMachineIso(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	defer os.Setenv("MACHINE_STORAGE_PATH", os.Getenv("MACHINE_STORAGE_PATH"))
+	os.Setenv("MACHINE_STORAGE_PATH", tmpDir)
+
+	isoPath := filepath.Join(GetMachineDir(), "dev", "boot2docker.iso")
+	if err := os.MkdirAll(filepath.Dir(isoPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(isoPath, []byte("previous"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewB2dUtils("", "")
+	if err := b.RestoreMachineIso("dev"); err == nil {
+		t.Fatal("expected an error without a backup")
+	}
+
+	if err := b.BackupMachineIso("dev"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(isoPath, []byte("upgraded"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.RestoreMachineIso("dev"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(isoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "previous" {
+		t.Fatalf("expected the previous ISO to be restored; received %q", data)
+	}
+}